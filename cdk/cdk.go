@@ -2,12 +2,14 @@ package main
 
 import (
 	"os"
+	"strings"
 
 	"github.com/aws/aws-cdk-go/awscdk/v2"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsevents"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awseventstargets"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awslogs"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awss3"
 	"github.com/aws/constructs-go/constructs/v10"
 	"github.com/aws/jsii-runtime-go"
 )
@@ -30,8 +32,37 @@ func NewCdkStack(scope constructs.Construct, id string, props *CdkStackProps) aw
 	nodepingToken := os.Getenv("NODEPING_TOKEN")
 	contactGroupName := os.Getenv("CONTACT_GROUP_NAME")
 	countLimit := os.Getenv("COUNT_LIMIT")
+	precision := os.Getenv("PRECISION")
+	uptimeTolerance := os.Getenv("UPTIME_TOLERANCE")
+	uptimeThresholds := os.Getenv("UPTIME_THRESHOLDS")
+	daily := os.Getenv("DAILY")
+	charts := os.Getenv("CHARTS")
+	worstChecks := os.Getenv("WORST_CHECKS")
+	services := os.Getenv("SERVICES")
+	slos := os.Getenv("SLOS")
 	period := os.Getenv("PERIOD")
+	retiredRows := os.Getenv("RETIRED_ROWS")
 	spreadsheetID := os.Getenv("SPREADSHEET_ID")
+	createSpreadsheet := os.Getenv("CREATE_SPREADSHEET")
+	spreadsheetTitle := os.Getenv("SPREADSHEET_TITLE")
+	folderID := os.Getenv("DRIVE_FOLDER_ID")
+	shareWith := os.Getenv("SHARE_WITH")
+	protectMonths := os.Getenv("PROTECT_MONTHS")
+	admins := os.Getenv("ADMINS")
+	notes := os.Getenv("NOTES")
+	layout := os.Getenv("LAYOUT")
+	tabTemplate := os.Getenv("TAB_TEMPLATE")
+	monthLayout := os.Getenv("MONTH_LAYOUT")
+	monthLocale := os.Getenv("MONTH_LOCALE")
+	dryRun := os.Getenv("DRY_RUN")
+	snapshotDir := os.Getenv("SNAPSHOT_DIR")
+	snapshotsKept := os.Getenv("SNAPSHOTS_KEPT")
+	lockWait := os.Getenv("LOCK_WAIT")
+	lockLease := os.Getenv("LOCK_LEASE")
+	checkpointDir := os.Getenv("CHECKPOINT_DIR")
+	resume := os.Getenv("RESUME")
+	upsert := os.Getenv("UPSERT")
+	allTime := os.Getenv("ALL_TIME")
 
 	googleAuthClientEmail := os.Getenv("GOOGLE_AUTH_CLIENT_EMAIL")
 	googleAuthPrivateKeyID := os.Getenv("GOOGLE_AUTH_PRIVATE_KEY_ID")
@@ -67,6 +98,18 @@ func NewCdkStack(scope constructs.Construct, id string, props *CdkStackProps) aw
 		Timeout:       awscdk.Duration_Seconds(jsii.Number(600)),
 	})
 
+	// Snapshots and checkpoints are kept in a bucket, since the function's file system doesn't last from
+	// one invocation to the next. SNAPSHOT_DIR and CHECKPOINT_DIR refer to it as "s3://{bucket}/...".
+	bucket := awss3.NewBucket(stack, jsii.String("StoreBucket"), &awss3.BucketProps{
+		BlockPublicAccess: awss3.BlockPublicAccess_BLOCK_ALL(),
+		Encryption:        awss3.BucketEncryption_S3_MANAGED,
+		EnforceSSL:        jsii.Bool(true),
+		RemovalPolicy:     awscdk.RemovalPolicy_RETAIN,
+	})
+	bucket.GrantReadWrite(function, nil)
+	snapshotDir = strings.ReplaceAll(snapshotDir, "{bucket}", *bucket.BucketName())
+	checkpointDir = strings.ReplaceAll(checkpointDir, "{bucket}", *bucket.BucketName())
+
 	rule := awsevents.NewRule(stack, jsii.String("ScheduleRule"), &awsevents.RuleProps{
 		Schedule: awsevents.Schedule_Cron(&awsevents.CronOptions{
 			Minute: jsii.String("30"),
@@ -79,10 +122,39 @@ func NewCdkStack(scope constructs.Construct, id string, props *CdkStackProps) aw
 	rule.AddTarget(awseventstargets.NewLambdaFunction(function, &awseventstargets.LambdaFunctionProps{
 		RetryAttempts: jsii.Number(0),
 		Event: awsevents.RuleTargetInput_FromObject(&map[string]*string{
+			"Admins":           &admins,
+			"AllTime":          &allTime,
+			"Charts":           &charts,
+			"CheckpointDir":    &checkpointDir,
 			"ContactGroupName": &contactGroupName,
 			"CountLimit":       &countLimit,
+			"Create":           &createSpreadsheet,
+			"Daily":            &daily,
+			"DryRun":           &dryRun,
+			"FolderID":         &folderID,
+			"Layout":           &layout,
+			"LockLease":        &lockLease,
+			"LockWait":         &lockWait,
+			"MonthLayout":      &monthLayout,
+			"MonthLocale":      &monthLocale,
+			"Notes":            &notes,
 			"Period":           &period,
+			"Precision":        &precision,
+			"ProtectMonths":    &protectMonths,
+			"Resume":           &resume,
+			"RetiredRows":      &retiredRows,
+			"Services":         &services,
+			"ShareWith":        &shareWith,
+			"SLOs":             &slos,
+			"SnapshotDir":      &snapshotDir,
+			"SnapshotsKept":    &snapshotsKept,
 			"SpreadSheetID":    &spreadsheetID,
+			"SpreadsheetTitle": &spreadsheetTitle,
+			"TabTemplate":      &tabTemplate,
+			"Thresholds":       &uptimeThresholds,
+			"Upsert":           &upsert,
+			"UptimeTolerance":  &uptimeTolerance,
+			"WorstChecks":      &worstChecks,
 		}),
 	}))
 
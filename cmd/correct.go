@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/googlesheets"
+)
+
+var (
+	checkName      string
+	correctedValue float64
+)
+
+var correctCmd = &cobra.Command{
+	Use:   "correct",
+	Short: "Correct an archived uptime",
+	Long:  "Overwrite a check's uptime for an archived month, even if the month column is protected. Who made the change, why, and the previous value are noted on the cell.",
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		requireCorrectionFlags()
+		if checkName == "" {
+			slog.Error("required flag is missing", "flag", "check")
+			os.Exit(1)
+		}
+		if !cmd.Flags().Changed("value") {
+			slog.Error("required flag is missing", "flag", "value")
+			os.Exit(1)
+		}
+
+		monthHeader, year, sheetsData := openMonthSheet()
+		lock, err := googlesheets.AcquireLock(googlesheets.LockOwner(), 0, lockWait, sheetsData)
+		if err != nil {
+			slog.Error("unable to lock the spreadsheet", "error", err)
+			os.Exit(1)
+		}
+
+		err = googlesheets.CorrectUptime(
+			monthHeader, checkName, correctedValue, precision, changedBy, reason, time.Now().UTC(), sheetsData,
+		)
+		if releaseErr := lock.Release(); releaseErr != nil {
+			slog.Error("unable to release the lock on the spreadsheet", "error", releaseErr)
+		}
+		if err != nil {
+			slog.Error("correction failed", "error", err, "sheet", sheetsData.GetSheetName(year))
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(correctCmd)
+	addCorrectionFlags(correctCmd)
+	correctCmd.Flags().StringVar(
+		&checkName,
+		"check",
+		"",
+		`The NodePing ID of the check to correct, or its name as in column A if no other row has it`,
+	)
+	correctCmd.Flags().Float64Var(
+		&correctedValue,
+		"value",
+		0,
+		`The corrected uptime percentage, e.g. 99.95`,
+	)
+	correctCmd.Flags().IntVarP(
+		&precision,
+		"precision",
+		"p",
+		googlesheets.DefaultPrecision,
+		`(Optional) The number of decimal places to write`,
+	)
+}
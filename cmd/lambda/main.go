@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -12,13 +13,43 @@ import (
 
 	"github.com/sil-org/app-monitoring-archiver/cmd"
 	"github.com/sil-org/app-monitoring-archiver/lib/googlesheets"
+	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
 )
 
 type ArchiveToGoogleSheetsConfig struct {
 	ContactGroupName string
 	Period           string
 	SpreadSheetID    string
+	Create           string // Whether to create the spreadsheet if it's missing
+	SpreadsheetTitle string
+	FolderID         string
+	ShareWith        string // Comma-separated email addresses, each with an optional "user:" or "group:" prefix
+	ProtectMonths    string
+	Admins           string // Who can edit protected months, in the same form as ShareWith
+	Notes            string
+	Layout           string // "wide", "transposed" or "long"
+	TabTemplate      string // e.g. "{group} {year}"
+	MonthLayout      string // The Go time layout of the month headings, e.g. "2006-01"
+	MonthLocale      string // The language of the month names in the headings, e.g. "fr"
+	DryRun           string // Whether to log the planned changes as JSON instead of making them (wide layout year tabs only)
+	SnapshotDir      string // Where to save snapshots of the tabs before changing them, an S3 location like "s3://my-bucket/snapshots"
+	SnapshotsKept    string
+	LockWait         string // How long to wait for another run's lock on the spreadsheet, e.g. "5m"
+	LockLease        string // How long the lock lasts if the run dies without releasing it, e.g. "30m"
+	CheckpointDir    string // Where to save checkpoints after each check is written, an S3 location like "s3://my-bucket/checkpoints"
+	Resume           string // Whether to resume a run of the same period that didn't finish
+	Upsert           string // Whether to mark the column of a month that hasn't ended as provisional
+	AllTime          string // Whether to rebuild the "All" tab with every month of every year
 	CountLimit       string
+	Precision        string
+	UptimeTolerance  string
+	Daily            string
+	Charts           string
+	WorstChecks      string
+	Services         string // JSON list of service definitions
+	SLOs             string // JSON list of SLO definitions
+	RetiredRows      string
+	Thresholds       string // Green, amber and red uptime thresholds, e.g. "99.9,99,99"
 	SentryDSN        string
 }
 
@@ -30,7 +61,7 @@ func main() {
 	lambda.Start(handler)
 }
 
-func handler(config ArchiveToGoogleSheetsConfig) error {
+func handler(config ArchiveToGoogleSheetsConfig) (googlesheets.RunReport, error) {
 	defer sentry.Flush(2 * time.Second)
 
 	if config.Period == "" {
@@ -40,28 +71,264 @@ func handler(config ArchiveToGoogleSheetsConfig) error {
 	nodePingToken, err := getRequiredEnv(cmd.NodePingTokenKey)
 	if err != nil {
 		sentry.CaptureException(err)
-		return err
+		return googlesheets.RunReport{}, err
 	}
 
 	intCountLimit, err := strconv.Atoi(config.CountLimit)
 	if err != nil {
 		err = fmt.Errorf("error converting CountLimit '%s' to integer: %w", config.CountLimit, err)
 		sentry.CaptureException(err)
-		return err
+		return googlesheets.RunReport{}, err
 	}
 
-	err = googlesheets.ArchiveResultsForMonth(
+	options := googlesheets.ArchiveOptions{CountLimit: intCountLimit}
+
+	if config.Precision != "" {
+		precision, err := strconv.Atoi(config.Precision)
+		if err != nil {
+			err = fmt.Errorf("error converting Precision '%s' to integer: %w", config.Precision, err)
+			sentry.CaptureException(err)
+			return googlesheets.RunReport{}, err
+		}
+		options.Precision = &precision
+	}
+
+	if config.UptimeTolerance != "" {
+		tolerance, err := strconv.ParseFloat(config.UptimeTolerance, 64)
+		if err != nil {
+			err = fmt.Errorf("error converting UptimeTolerance '%s' to float: %w", config.UptimeTolerance, err)
+			sentry.CaptureException(err)
+			return googlesheets.RunReport{}, err
+		}
+		options.UptimeTolerance = &tolerance
+	}
+
+	if config.Daily != "" {
+		options.Daily, err = strconv.ParseBool(config.Daily)
+		if err != nil {
+			err = fmt.Errorf("error converting Daily '%s' to boolean: %w", config.Daily, err)
+			sentry.CaptureException(err)
+			return googlesheets.RunReport{}, err
+		}
+	}
+
+	if config.Charts != "" {
+		options.Charts, err = strconv.ParseBool(config.Charts)
+		if err != nil {
+			err = fmt.Errorf("error converting Charts '%s' to boolean: %w", config.Charts, err)
+			sentry.CaptureException(err)
+			return googlesheets.RunReport{}, err
+		}
+	}
+
+	if config.WorstChecks != "" {
+		options.WorstChecks, err = strconv.Atoi(config.WorstChecks)
+		if err != nil {
+			err = fmt.Errorf("error converting WorstChecks '%s' to integer: %w", config.WorstChecks, err)
+			sentry.CaptureException(err)
+			return googlesheets.RunReport{}, err
+		}
+	}
+
+	if config.Services != "" {
+		options.Services, err = nodeping.ParseServices([]byte(config.Services))
+		if err != nil {
+			sentry.CaptureException(err)
+			return googlesheets.RunReport{}, err
+		}
+	}
+
+	if config.SLOs != "" {
+		options.SLOs, err = nodeping.ParseSLOs([]byte(config.SLOs))
+		if err != nil {
+			sentry.CaptureException(err)
+			return googlesheets.RunReport{}, err
+		}
+	}
+
+	options.RetiredTreatment, err = googlesheets.ParseRetiredTreatment(config.RetiredRows)
+	if err != nil {
+		sentry.CaptureException(err)
+		return googlesheets.RunReport{}, err
+	}
+
+	options.Thresholds, err = googlesheets.ParseUptimeThresholds(config.Thresholds)
+	if err != nil {
+		sentry.CaptureException(err)
+		return googlesheets.RunReport{}, err
+	}
+
+	if config.Create != "" {
+		options.Spreadsheet.Create, err = strconv.ParseBool(config.Create)
+		if err != nil {
+			err = fmt.Errorf("error converting Create '%s' to boolean: %w", config.Create, err)
+			sentry.CaptureException(err)
+			return googlesheets.RunReport{}, err
+		}
+	}
+
+	options.Spreadsheet.Title = config.SpreadsheetTitle
+	options.Spreadsheet.FolderID = config.FolderID
+	options.Spreadsheet.ShareWith, err = googlesheets.ParseGrantees(config.ShareWith)
+	if err != nil {
+		sentry.CaptureException(err)
+		return googlesheets.RunReport{}, err
+	}
+
+	if config.ProtectMonths != "" {
+		options.ProtectMonths, err = strconv.ParseBool(config.ProtectMonths)
+		if err != nil {
+			err = fmt.Errorf("error converting ProtectMonths '%s' to boolean: %w", config.ProtectMonths, err)
+			sentry.CaptureException(err)
+			return googlesheets.RunReport{}, err
+		}
+	}
+
+	options.Admins, err = googlesheets.ParseGrantees(config.Admins)
+	if err != nil {
+		sentry.CaptureException(err)
+		return googlesheets.RunReport{}, err
+	}
+
+	if config.Notes != "" {
+		options.Notes, err = strconv.ParseBool(config.Notes)
+		if err != nil {
+			err = fmt.Errorf("error converting Notes '%s' to boolean: %w", config.Notes, err)
+			sentry.CaptureException(err)
+			return googlesheets.RunReport{}, err
+		}
+	}
+
+	options.Layout, err = googlesheets.ParseLayout(config.Layout)
+	if err != nil {
+		sentry.CaptureException(err)
+		return googlesheets.RunReport{}, err
+	}
+
+	if err := googlesheets.ValidateTabTemplate(config.TabTemplate); err != nil {
+		sentry.CaptureException(err)
+		return googlesheets.RunReport{}, err
+	}
+	options.TabTemplate = config.TabTemplate
+
+	options.MonthFormat, err = googlesheets.ParseMonthFormat(config.MonthLayout, config.MonthLocale)
+	if err != nil {
+		sentry.CaptureException(err)
+		return googlesheets.RunReport{}, err
+	}
+
+	if config.DryRun != "" {
+		options.DryRun, err = strconv.ParseBool(config.DryRun)
+		if err != nil {
+			err = fmt.Errorf("error converting DryRun '%s' to boolean: %w", config.DryRun, err)
+			sentry.CaptureException(err)
+			return googlesheets.RunReport{}, err
+		}
+		options.PlanFormat = googlesheets.PlanFormatJSON
+	}
+
+	if config.SnapshotDir != "" {
+		options.Snapshots, err = openDurableStore(config.SnapshotDir)
+		if err != nil {
+			err = fmt.Errorf("invalid SnapshotDir: %w", err)
+			sentry.CaptureException(err)
+			return googlesheets.RunReport{}, err
+		}
+	}
+
+	if config.SnapshotsKept != "" {
+		options.SnapshotsKept, err = strconv.Atoi(config.SnapshotsKept)
+		if err != nil {
+			err = fmt.Errorf("error converting SnapshotsKept '%s' to integer: %w", config.SnapshotsKept, err)
+			sentry.CaptureException(err)
+			return googlesheets.RunReport{}, err
+		}
+	}
+
+	if config.LockWait != "" {
+		options.LockWait, err = time.ParseDuration(config.LockWait)
+		if err != nil {
+			err = fmt.Errorf("error converting LockWait '%s' to duration: %w", config.LockWait, err)
+			sentry.CaptureException(err)
+			return googlesheets.RunReport{}, err
+		}
+	}
+
+	if config.LockLease != "" {
+		options.LockLease, err = time.ParseDuration(config.LockLease)
+		if err != nil {
+			err = fmt.Errorf("error converting LockLease '%s' to duration: %w", config.LockLease, err)
+			sentry.CaptureException(err)
+			return googlesheets.RunReport{}, err
+		}
+	}
+
+	if config.CheckpointDir != "" {
+		options.Checkpoints, err = openDurableStore(config.CheckpointDir)
+		if err != nil {
+			err = fmt.Errorf("invalid CheckpointDir: %w", err)
+			sentry.CaptureException(err)
+			return googlesheets.RunReport{}, err
+		}
+	}
+
+	if config.Resume != "" {
+		options.Resume, err = strconv.ParseBool(config.Resume)
+		if err != nil {
+			err = fmt.Errorf("error converting Resume '%s' to boolean: %w", config.Resume, err)
+			sentry.CaptureException(err)
+			return googlesheets.RunReport{}, err
+		}
+		if options.Resume && options.Checkpoints == nil {
+			err = errors.New(`a CheckpointDir in S3, like "s3://my-bucket/checkpoints", is needed to resume a run`)
+			sentry.CaptureException(err)
+			return googlesheets.RunReport{}, err
+		}
+	}
+
+	if config.Upsert != "" {
+		options.Upsert, err = strconv.ParseBool(config.Upsert)
+		if err != nil {
+			err = fmt.Errorf("error converting Upsert '%s' to boolean: %w", config.Upsert, err)
+			sentry.CaptureException(err)
+			return googlesheets.RunReport{}, err
+		}
+	}
+
+	if config.AllTime != "" {
+		options.AllTime, err = strconv.ParseBool(config.AllTime)
+		if err != nil {
+			err = fmt.Errorf("error converting AllTime '%s' to boolean: %w", config.AllTime, err)
+			sentry.CaptureException(err)
+			return googlesheets.RunReport{}, err
+		}
+	}
+
+	report, err := googlesheets.ArchiveResultsForMonth(
 		config.ContactGroupName,
 		config.Period,
 		config.SpreadSheetID,
 		nodePingToken,
-		intCountLimit,
+		options,
 	)
 	if err != nil {
 		sentry.CaptureException(err)
-		return err
 	}
-	return nil
+	return report, err
+}
+
+// openDurableStore returns the store at the location, which must be an S3 bucket and prefix like
+// "s3://my-bucket/snapshots", since the function's own file system doesn't last from one invocation to
+// the next
+func openDurableStore(location string) (googlesheets.Store, error) {
+	store, err := googlesheets.OpenStore(location)
+	if err != nil {
+		return nil, err
+	}
+	if !googlesheets.IsDurable(store) {
+		return nil, fmt.Errorf("%q must be an S3 location like \"s3://my-bucket/snapshots\", since the files in a Lambda function's directories don't last", location)
+	}
+	return store, nil
 }
 
 func initSentry(dsn string) {
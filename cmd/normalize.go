@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/googlesheets"
+	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
+)
+
+var normalizeSort string
+
+var normalizeCmd = &cobra.Command{
+	Use:   "normalize",
+	Short: "Re-sort and repair a year tab",
+	Long:  "Sort the month columns of a year tab into calendar order and its check rows by name (or by average uptime), merge the columns of the same month and the rows of the same check, and remove the empty rows between checks, keeping each row's values, formats, notes and check ID. The summary is rebuilt afterwards.",
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, flag := range []struct{ name, value string }{
+			{"spreadsheetID", spreadsheetID},
+			{"year", tabYear},
+		} {
+			if flag.value == "" {
+				slog.Error("required flag is missing", "flag", flag.name)
+				os.Exit(1)
+			}
+		}
+
+		sortBy, err := googlesheets.ParseNormalizeSort(normalizeSort)
+		if err != nil {
+			slog.Error("invalid sort flag", "error", err)
+			os.Exit(1)
+		}
+
+		var slos []nodeping.SLO
+		if slosFile != "" {
+			slos, err = nodeping.LoadSLOs(slosFile)
+			if err != nil {
+				slog.Error("invalid SLOs file", "error", err)
+				os.Exit(1)
+			}
+		}
+
+		uptimeThresholds, err := googlesheets.ParseUptimeThresholds(thresholds)
+		if err != nil {
+			slog.Error("invalid thresholds flag", "error", err)
+			os.Exit(1)
+		}
+
+		name := sheetName
+		if name == "" {
+			name = tabYear
+		}
+
+		sheetsData, err := googlesheets.OpenSheet(spreadsheetID, name)
+		if err != nil {
+			slog.Error("unable to open sheet", "error", err)
+			os.Exit(1)
+		}
+		sheetsData.MonthFormat = parseMonthFormat()
+
+		options := googlesheets.NormalizeOptions{
+			SortBy:        sortBy,
+			SLOs:          slos,
+			DefaultTarget: uptimeThresholds.Green,
+			Precision:     precision,
+			SnapshotsKept: snapshotsKept,
+		}
+		if snapshotDir != "" {
+			options.Snapshots = openStore(snapshotDir, "snapshot-dir")
+		}
+
+		lock, err := googlesheets.AcquireLock(googlesheets.LockOwner(), 0, lockWait, sheetsData)
+		if err != nil {
+			slog.Error("unable to lock the spreadsheet", "error", err)
+			os.Exit(1)
+		}
+
+		result, err := googlesheets.NormalizeYearTab(options, sheetsData)
+		if releaseErr := lock.Release(); releaseErr != nil {
+			slog.Error("unable to release the lock on the spreadsheet", "error", releaseErr)
+		}
+		if err != nil {
+			slog.Error("normalize failed", "error", err, "sheet", name)
+			os.Exit(1)
+		}
+		fmt.Print(result)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(normalizeCmd)
+	normalizeCmd.Flags().StringVarP(
+		&spreadsheetID,
+		"spreadsheetID",
+		"s",
+		"",
+		`The ID of the spreadsheet as found in its url.`,
+	)
+	normalizeCmd.Flags().StringVar(
+		&tabYear,
+		"year",
+		"",
+		`The year of the tab to normalize, e.g. "2024"`,
+	)
+	normalizeCmd.Flags().StringVar(
+		&sheetName,
+		"sheet",
+		"",
+		`(Optional) The name of the tab, if it isn't just the year`,
+	)
+	normalizeCmd.Flags().StringVar(
+		&normalizeSort,
+		"sort",
+		googlesheets.NormalizeSortLabel,
+		`(Optional) The order of the check rows: "label" (alphabetical, the order new checks are added in) or "average" (lowest average uptime first)`,
+	)
+	normalizeCmd.Flags().StringVar(
+		&slosFile,
+		"slos",
+		"",
+		`(Optional) A JSON file of SLO targets for the summary's "Months below SLO" column`,
+	)
+	normalizeCmd.Flags().StringVar(
+		&thresholds,
+		"thresholds",
+		"",
+		`(Optional) The green, amber and red uptime thresholds, the first of which is the SLO target of checks without one (default "99.9,99,99")`,
+	)
+	normalizeCmd.Flags().IntVarP(
+		&precision,
+		"precision",
+		"p",
+		googlesheets.DefaultPrecision,
+		`(Optional) The number of decimal places of the summary`,
+	)
+	addSnapshotDirFlag(normalizeCmd)
+	normalizeCmd.Flags().IntVar(
+		&snapshotsKept,
+		"snapshots-kept",
+		googlesheets.DefaultSnapshotsKept,
+		`(Optional) The number of snapshots of each tab to keep`,
+	)
+	addMonthFormatFlags(normalizeCmd)
+	addLockFlags(normalizeCmd)
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/googlesheets"
+)
+
+var (
+	snapshotName  string
+	listSnapshots bool
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Restore a tab from a snapshot",
+	Long:  "Put a tab back the way it was in a snapshot taken before a run, by default the latest one. The tab's current state is saved as a snapshot first, so that the restore can be undone.",
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, flag := range []struct{ name, value string }{
+			{"spreadsheetID", spreadsheetID},
+			{"sheet", sheetName},
+			{"snapshot-dir", snapshotDir},
+		} {
+			if flag.value == "" {
+				slog.Error("required flag is missing", "flag", flag.name)
+				os.Exit(1)
+			}
+		}
+
+		store := openStore(snapshotDir, "snapshot-dir")
+		if listSnapshots {
+			names, err := googlesheets.ListSnapshots(spreadsheetID, sheetName, store)
+			if err != nil {
+				slog.Error("unable to list snapshots", "error", err)
+				os.Exit(1)
+			}
+			for _, name := range names {
+				fmt.Println(name)
+			}
+			return
+		}
+
+		snapshot, err := googlesheets.LoadSnapshot(snapshotName, spreadsheetID, sheetName, store)
+		if err != nil {
+			slog.Error("unable to load snapshot", "error", err)
+			os.Exit(1)
+		}
+
+		sheetsData, err := googlesheets.OpenSheet(spreadsheetID, sheetName)
+		if err != nil {
+			slog.Error("unable to open sheet", "error", err)
+			os.Exit(1)
+		}
+		sheetsData.MonthFormat = parseMonthFormat()
+
+		lock, err := googlesheets.AcquireLock(googlesheets.LockOwner(), 0, lockWait, sheetsData)
+		if err != nil {
+			slog.Error("unable to lock the spreadsheet", "error", err)
+			os.Exit(1)
+		}
+
+		err = restoreSnapshot(snapshot, store, sheetsData)
+		if releaseErr := lock.Release(); releaseErr != nil {
+			slog.Error("unable to release the lock on the spreadsheet", "error", releaseErr)
+		}
+		if err != nil {
+			slog.Error("restore failed", "error", err, "sheet", sheetName)
+			os.Exit(1)
+		}
+		fmt.Printf("Restored %s to the snapshot taken at %s\n", sheetName, snapshot.TakenAt.Format(time.RFC3339))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+	restoreCmd.Flags().StringVarP(
+		&spreadsheetID,
+		"spreadsheetID",
+		"s",
+		"",
+		`The ID of the spreadsheet as found in its url.`,
+	)
+	restoreCmd.Flags().StringVar(
+		&sheetName,
+		"sheet",
+		"",
+		`The name of the tab to restore, e.g. "2024"`,
+	)
+	restoreCmd.Flags().StringVar(
+		&snapshotName,
+		"snapshot",
+		"",
+		`(Optional) The name of the snapshot to restore, as listed by --list (defaults to the latest)`,
+	)
+	restoreCmd.Flags().BoolVar(
+		&listSnapshots,
+		"list",
+		false,
+		`(Optional) List the snapshots of the tab, oldest first, instead of restoring one`,
+	)
+	addSnapshotDirFlag(restoreCmd)
+	addMonthFormatFlags(restoreCmd)
+	addLockFlags(restoreCmd)
+}
+
+// addSnapshotDirFlag adds the flag for the directory that holds the snapshots
+func addSnapshotDirFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(
+		&snapshotDir,
+		"snapshot-dir",
+		"",
+		`(Optional) The directory, or S3 location like "s3://my-bucket/snapshots", for snapshots of the tabs, which are saved before each run changes them`,
+	)
+}
+
+// restoreSnapshot saves a snapshot of the tab's current state, so that the restore can be undone, and
+// then restores the tab from the snapshot
+func restoreSnapshot(snapshot *googlesheets.Snapshot, store googlesheets.Store, sheetsData googlesheets.SheetsData) error {
+	current, err := googlesheets.TakeSnapshot(snapshot.SheetName, time.Now().UTC(), sheetsData)
+	if err == nil && current != nil {
+		_, err = googlesheets.SaveSnapshot(current, 0, store)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to save a snapshot before restoring: %w", err)
+	}
+
+	return googlesheets.RestoreSnapshot(snapshot, sheetsData)
+}
@@ -1,18 +1,52 @@
 package cmd
 
 import (
+	"fmt"
 	"log/slog"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/sil-org/app-monitoring-archiver/lib/googlesheets"
+	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
 )
 
 var (
 	contactGroupName string
 	spreadsheetID    string
+	period           string
 	countLimit       int
+	precision        int
+	uptimeTolerance  float64
+	daily            bool
+	charts           bool
+	worstChecks      int
+	servicesFile     string
+	slosFile         string
+	retiredRows      string
+	thresholds       string
+	create           bool
+	title            string
+	folderID         string
+	shareWith        string
+	protectMonths    bool
+	admins           string
+	notes            bool
+	layout           string
+	tabTemplate      string
+	monthLayout      string
+	monthLocale      string
+	dryRun           bool
+	planFormat       string
+	snapshotDir      string
+	snapshotsKept    int
+	lockWait         time.Duration
+	lockLease        time.Duration
+	checkpointDir    string
+	resume           bool
+	upsert           bool
+	allTime          bool
 )
 
 var runCmd = &cobra.Command{
@@ -26,8 +60,13 @@ var runCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		if spreadsheetID == "" {
-			slog.Error("required flag is missing", "flag", "spreadsheetID")
+		if spreadsheetID == "" && !create {
+			slog.Error("required flag is missing", "flag", "spreadsheetID", "alternative", "--create")
+			os.Exit(1)
+		}
+
+		if resume && checkpointDir == "" {
+			slog.Error("required flag is missing", "flag", "checkpoint-dir", "for", "--resume")
 			os.Exit(1)
 		}
 
@@ -51,6 +90,12 @@ func init() {
 		"",
 		`The ID of the spreadsheet as found in its url.`,
 	)
+	runCmd.Flags().StringVar(
+		&period,
+		"period",
+		"LastMonth",
+		`(Optional) The period to archive, e.g. "LastMonth", "LastYear" or a range of months like "2024-01:2024-06"`,
+	)
 	runCmd.Flags().IntVarP(
 		&countLimit,
 		"count-limit",
@@ -58,10 +103,326 @@ func init() {
 		0,
 		`(Optional) The maximum number of results to write to Google Sheets`,
 	)
+	runCmd.Flags().IntVarP(
+		&precision,
+		"precision",
+		"p",
+		googlesheets.DefaultPrecision,
+		`(Optional) The number of decimal places to write for each uptime value`,
+	)
+	runCmd.Flags().Float64Var(
+		&uptimeTolerance,
+		"tolerance",
+		nodeping.DefaultUptimeTolerance,
+		`(Optional) Warn when NodePing's uptime and the computed uptime differ by more than this`,
+	)
+	runCmd.Flags().BoolVar(
+		&daily,
+		"daily",
+		false,
+		`(Optional) Also write each day's uptime to the "<year> daily" tab`,
+	)
+	runCmd.Flags().BoolVar(
+		&charts,
+		"charts",
+		false,
+		`(Optional) Also create or refresh the charts on the "<year> charts" tab`,
+	)
+	runCmd.Flags().IntVar(
+		&worstChecks,
+		"worst-checks",
+		googlesheets.DefaultWorstChecks,
+		`(Optional) The number of checks in the chart of the worst checks`,
+	)
+	runCmd.Flags().StringVar(
+		&servicesFile,
+		"services",
+		"",
+		`(Optional) A JSON file of services whose composite uptime is written to the "<year> services" tab`,
+	)
+	runCmd.Flags().StringVar(
+		&slosFile,
+		"slos",
+		"",
+		`(Optional) A JSON file of SLO targets whose error budgets are written to the "SLO" tab`,
+	)
+	runCmd.Flags().StringVar(
+		&retiredRows,
+		"retired",
+		googlesheets.RetiredNone,
+		`(Optional) How to mark the rows of checks that have left the contact group: "none", "grey", "note" or "move"`,
+	)
+	runCmd.Flags().StringVar(
+		&thresholds,
+		"thresholds",
+		"",
+		`(Optional) The green, amber and red uptime thresholds for coloring new tabs (default "99.9,99,99")`,
+	)
+	runCmd.Flags().BoolVar(
+		&create,
+		"create",
+		false,
+		`(Optional) Create the spreadsheet if no ID is given or there's no spreadsheet with the ID`,
+	)
+	runCmd.Flags().StringVar(
+		&title,
+		"title",
+		googlesheets.DefaultSpreadsheetTitle,
+		`(Optional) The title of a new spreadsheet`,
+	)
+	runCmd.Flags().StringVar(
+		&folderID,
+		"folder",
+		"",
+		`(Optional) The ID of the Google Drive folder for a new spreadsheet`,
+	)
+	runCmd.Flags().StringVar(
+		&shareWith,
+		"share",
+		"",
+		`(Optional) Email addresses to share the spreadsheet with, e.g. "someone@example.org,group:team@example.org"`,
+	)
+	runCmd.Flags().BoolVar(
+		&protectMonths,
+		"protect",
+		false,
+		`(Optional) Protect the columns of months that have ended, so that only the admins can edit them`,
+	)
+	runCmd.Flags().StringVar(
+		&admins,
+		"admins",
+		"",
+		`(Optional) Email addresses who can edit protected months, e.g. "someone@example.org,group:team@example.org"`,
+	)
+	runCmd.Flags().BoolVar(
+		&notes,
+		"notes",
+		false,
+		`(Optional) Add notes with each check's details and NodePing's down and enabled time to the cells`,
+	)
+	runCmd.Flags().StringVar(
+		&layout,
+		"layout",
+		googlesheets.LayoutWide,
+		`(Optional) How to lay out the uptimes: "wide", "transposed" or "long"`,
+	)
+	runCmd.Flags().StringVar(
+		&tabTemplate,
+		"tab-template",
+		googlesheets.DefaultTabTemplate,
+		`(Optional) The names of the tabs, e.g. "{group} {year}"`,
+	)
+	addMonthFormatFlags(runCmd)
+	runCmd.Flags().BoolVar(
+		&dryRun,
+		"dry-run",
+		false,
+		`(Optional) Print the changes that would be made to the year tabs, without making them. `+
+			`Can't be used with --layout transposed or long, --retired, --notes, --upsert, --protect, --charts, `+
+			`--daily, --services, --slos or --all-time`,
+	)
+	runCmd.Flags().StringVar(
+		&planFormat,
+		"plan-format",
+		googlesheets.PlanFormatText,
+		`(Optional) The format of the dry run's changes: "text" or "json"`,
+	)
+	addSnapshotDirFlag(runCmd)
+	runCmd.Flags().IntVar(
+		&snapshotsKept,
+		"snapshots-kept",
+		googlesheets.DefaultSnapshotsKept,
+		`(Optional) The number of snapshots of each tab to keep`,
+	)
+	addLockFlags(runCmd)
+	runCmd.Flags().DurationVar(
+		&lockLease,
+		"lock-lease",
+		googlesheets.DefaultLockLease,
+		`(Optional) How long the run's lock on the spreadsheet lasts if the run dies without releasing it`,
+	)
+	runCmd.Flags().StringVar(
+		&checkpointDir,
+		"checkpoint-dir",
+		"",
+		`(Optional) The directory, or S3 location like "s3://my-bucket/checkpoints", for checkpoints, which are saved after each check is written`,
+	)
+	runCmd.Flags().BoolVar(
+		&resume,
+		"resume",
+		false,
+		`(Optional) Resume an earlier run of the same period that didn't finish, from its checkpoint`,
+	)
+	runCmd.Flags().BoolVar(
+		&upsert,
+		"upsert",
+		false,
+		`(Optional) Mark the column of a month that hasn't ended (e.g. with --period ThisMonth) as provisional until it is final`,
+	)
+	runCmd.Flags().BoolVar(
+		&allTime,
+		"all-time",
+		false,
+		`(Optional) Also rebuild the "All" tab, with every month of every year, from the year tabs`,
+	)
+}
+
+// addMonthFormatFlags adds the flags for how the month headings are written
+func addMonthFormatFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(
+		&monthLayout,
+		"month-layout",
+		googlesheets.DefaultMonthLayout,
+		`(Optional) The Go time layout of the month headings, e.g. "2006-01"`,
+	)
+	cmd.Flags().StringVar(
+		&monthLocale,
+		"month-locale",
+		googlesheets.DefaultMonthLocale,
+		`(Optional) The language of the month names in the headings: "en", "fr", "es", "pt" or "de"`,
+	)
+}
+
+// parseMonthFormat returns the format of the month headings from the flags
+func parseMonthFormat() googlesheets.MonthFormat {
+	format, err := googlesheets.ParseMonthFormat(monthLayout, monthLocale)
+	if err != nil {
+		slog.Error("invalid month format flags", "error", err)
+		os.Exit(1)
+	}
+	return format
+}
+
+// openStore returns the store at the location given by the flag, which is a directory or an S3 bucket
+// and prefix like "s3://my-bucket/snapshots"
+func openStore(location, flag string) googlesheets.Store {
+	store, err := googlesheets.OpenStore(location)
+	if err != nil {
+		slog.Error("invalid "+flag+" flag", "error", err)
+		os.Exit(1)
+	}
+	return store
+}
+
+// addLockFlags adds the flag for how long to wait for another run's lock on the spreadsheet
+func addLockFlags(cmd *cobra.Command) {
+	cmd.Flags().DurationVar(
+		&lockWait,
+		"lock-wait",
+		0,
+		`(Optional) How long to wait for another run to finish changing the spreadsheet, e.g. "5m" (default is not to wait)`,
+	)
 }
 
 func runArchive() {
-	err := googlesheets.ArchiveResultsForMonth(contactGroupName, "LastMonth", spreadsheetID, nodePingToken, countLimit)
+	var services []nodeping.Service
+	if servicesFile != "" {
+		var err error
+		services, err = nodeping.LoadServices(servicesFile)
+		if err != nil {
+			slog.Error("invalid services file", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var slos []nodeping.SLO
+	if slosFile != "" {
+		var err error
+		slos, err = nodeping.LoadSLOs(slosFile)
+		if err != nil {
+			slog.Error("invalid SLOs file", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	retiredTreatment, err := googlesheets.ParseRetiredTreatment(retiredRows)
+	if err != nil {
+		slog.Error("invalid retired flag", "error", err)
+		os.Exit(1)
+	}
+
+	uptimeThresholds, err := googlesheets.ParseUptimeThresholds(thresholds)
+	if err != nil {
+		slog.Error("invalid thresholds flag", "error", err)
+		os.Exit(1)
+	}
+
+	grantees, err := googlesheets.ParseGrantees(shareWith)
+	if err != nil {
+		slog.Error("invalid share flag", "error", err)
+		os.Exit(1)
+	}
+
+	adminGrantees, err := googlesheets.ParseGrantees(admins)
+	if err != nil {
+		slog.Error("invalid admins flag", "error", err)
+		os.Exit(1)
+	}
+
+	sheetLayout, err := googlesheets.ParseLayout(layout)
+	if err != nil {
+		slog.Error("invalid layout flag", "error", err)
+		os.Exit(1)
+	}
+
+	if err := googlesheets.ValidateTabTemplate(tabTemplate); err != nil {
+		slog.Error("invalid tab-template flag", "error", err)
+		os.Exit(1)
+	}
+
+	format, err := googlesheets.ParsePlanFormat(planFormat)
+	if err != nil {
+		slog.Error("invalid plan-format flag", "error", err)
+		os.Exit(1)
+	}
+
+	monthFormat := parseMonthFormat()
+
+	options := googlesheets.ArchiveOptions{
+		CountLimit:      countLimit,
+		Precision:       &precision,
+		UptimeTolerance: &uptimeTolerance,
+		Daily:           daily,
+		Charts:          charts,
+		WorstChecks:     worstChecks,
+		Services:        services,
+		SLOs:            slos,
+
+		RetiredTreatment: retiredTreatment,
+		Thresholds:       uptimeThresholds,
+
+		Spreadsheet: googlesheets.SpreadsheetConfig{
+			Create:    create,
+			Title:     title,
+			FolderID:  folderID,
+			ShareWith: grantees,
+		},
+		ProtectMonths: protectMonths,
+		Admins:        adminGrantees,
+		Notes:         notes,
+		Layout:        sheetLayout,
+		TabTemplate:   tabTemplate,
+		MonthFormat:   monthFormat,
+		DryRun:        dryRun,
+		PlanFormat:    format,
+		SnapshotsKept: snapshotsKept,
+		LockWait:      lockWait,
+		LockLease:     lockLease,
+		Upsert:        upsert,
+		AllTime:       allTime,
+	}
+	if snapshotDir != "" {
+		options.Snapshots = openStore(snapshotDir, "snapshot-dir")
+	}
+	if checkpointDir != "" {
+		options.Checkpoints = openStore(checkpointDir, "checkpoint-dir")
+		options.Resume = resume
+	}
+
+	report, err := googlesheets.ArchiveResultsForMonth(contactGroupName, period, spreadsheetID, nodePingToken, options)
+	if !dryRun {
+		fmt.Print(report)
+	}
 	if err != nil {
 		slog.Error("archive failed", "error", err)
 		os.Exit(1)
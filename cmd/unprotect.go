@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/googlesheets"
+)
+
+var (
+	month     string
+	sheetName string
+	changedBy string
+	reason    string
+)
+
+var unprotectCmd = &cobra.Command{
+	Use:   "unprotect",
+	Short: "Unprotect an archived month",
+	Long:  "Remove the protection from an archived month column, so that it can be corrected by hand. Who did it and why is noted on the month heading.",
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		requireCorrectionFlags()
+
+		monthHeader, year, sheetsData := openMonthSheet()
+		lock, err := googlesheets.AcquireLock(googlesheets.LockOwner(), 0, lockWait, sheetsData)
+		if err != nil {
+			slog.Error("unable to lock the spreadsheet", "error", err)
+			os.Exit(1)
+		}
+
+		err = googlesheets.UnprotectMonthColumn(monthHeader, changedBy, reason, time.Now().UTC(), sheetsData)
+		if releaseErr := lock.Release(); releaseErr != nil {
+			slog.Error("unable to release the lock on the spreadsheet", "error", releaseErr)
+		}
+		if err != nil {
+			slog.Error("unprotect failed", "error", err, "sheet", sheetsData.GetSheetName(year))
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(unprotectCmd)
+	addCorrectionFlags(unprotectCmd)
+}
+
+// addCorrectionFlags adds the flags that pick the month column and say who is changing it and why
+func addCorrectionFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(
+		&spreadsheetID,
+		"spreadsheetID",
+		"s",
+		"",
+		`The ID of the spreadsheet as found in its url.`,
+	)
+	cmd.Flags().StringVar(
+		&month,
+		"month",
+		"",
+		`The month to change, e.g. "2024-03"`,
+	)
+	cmd.Flags().StringVar(
+		&sheetName,
+		"sheet",
+		"",
+		`(Optional) The name of the tab, if it isn't just the year`,
+	)
+	cmd.Flags().StringVar(
+		&changedBy,
+		"by",
+		"",
+		`The name or email address of the person making the change`,
+	)
+	cmd.Flags().StringVar(
+		&reason,
+		"reason",
+		"",
+		`Why the change is being made`,
+	)
+	addMonthFormatFlags(cmd)
+	addLockFlags(cmd)
+}
+
+func requireCorrectionFlags() {
+	required := []struct{ name, value string }{
+		{"spreadsheetID", spreadsheetID},
+		{"month", month},
+		{"by", changedBy},
+		{"reason", reason},
+	}
+	for _, flag := range required {
+		if flag.value == "" {
+			slog.Error("required flag is missing", "flag", flag.name)
+			os.Exit(1)
+		}
+	}
+}
+
+// openMonthSheet returns the heading of the month column and the year, and opens the tab that has it
+func openMonthSheet() (string, string, googlesheets.SheetsData) {
+	monthHeader, year, err := googlesheets.MonthHeader(month)
+	if err != nil {
+		slog.Error("invalid month flag", "error", err)
+		os.Exit(1)
+	}
+
+	name := sheetName
+	if name == "" {
+		name = year
+	}
+
+	sheetsData, err := googlesheets.OpenSheet(spreadsheetID, name)
+	if err != nil {
+		slog.Error("unable to open sheet", "error", err)
+		os.Exit(1)
+	}
+	sheetsData.MonthFormat = parseMonthFormat()
+
+	return monthHeader, year, sheetsData
+}
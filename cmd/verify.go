@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/googlesheets"
+	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
+)
+
+var (
+	tabYear         string
+	verifyTolerance float64
+	fix             bool
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Compare a year tab with NodePing",
+	Long:  "Read back the uptimes in a year tab, get the uptimes for the same months from NodePing, and list the cells that are missing, that differ, or that belong to checks that aren't in the contact group. With --fix, the cells that differ are rewritten.",
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, flag := range []struct{ name, value string }{
+			{"spreadsheetID", spreadsheetID},
+			{"contact-group", contactGroupName},
+			{"year", tabYear},
+		} {
+			if flag.value == "" {
+				slog.Error("required flag is missing", "flag", flag.name)
+				os.Exit(1)
+			}
+		}
+
+		name := sheetName
+		if name == "" {
+			name = tabYear
+		}
+
+		sheetsData, err := googlesheets.OpenSheet(spreadsheetID, name)
+		if err != nil {
+			slog.Error("unable to open sheet", "error", err)
+			os.Exit(1)
+		}
+		sheetsData.MonthFormat = parseMonthFormat()
+
+		npConfig := nodeping.ClientConfig{Token: nodePingToken}
+		verification, err := googlesheets.VerifyYearTab(contactGroupName, npConfig, precision, verifyTolerance, sheetsData)
+		if err != nil {
+			slog.Error("verify failed", "error", err, "sheet", name)
+			os.Exit(1)
+		}
+		fmt.Print(verification)
+
+		remaining := len(verification.Discrepancies)
+		if fix && remaining > 0 {
+			lock, err := googlesheets.AcquireLock(googlesheets.LockOwner(), 0, lockWait, sheetsData)
+			if err != nil {
+				slog.Error("unable to lock the spreadsheet", "error", err)
+				os.Exit(1)
+			}
+
+			fixed, err := googlesheets.FixDiscrepancies(verification.Discrepancies, precision, sheetsData)
+			if releaseErr := lock.Release(); releaseErr != nil {
+				slog.Error("unable to release the lock on the spreadsheet", "error", releaseErr)
+			}
+			if err != nil {
+				slog.Error("fix failed", "error", err, "sheet", name)
+				os.Exit(1)
+			}
+			fmt.Printf("Fixed %d cells\n", fixed)
+			remaining -= fixed
+		}
+
+		if remaining > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+	verifyCmd.Flags().StringVarP(
+		&spreadsheetID,
+		"spreadsheetID",
+		"s",
+		"",
+		`The ID of the spreadsheet as found in its url.`,
+	)
+	verifyCmd.Flags().StringVarP(
+		&contactGroupName,
+		"contact-group",
+		"g",
+		"",
+		`Name of the NodePing Contact Group whose uptimes are in the tab.`,
+	)
+	verifyCmd.Flags().StringVar(
+		&tabYear,
+		"year",
+		"",
+		`The year of the tab to verify, e.g. "2024"`,
+	)
+	verifyCmd.Flags().StringVar(
+		&sheetName,
+		"sheet",
+		"",
+		`(Optional) The name of the tab, if it isn't just the year`,
+	)
+	verifyCmd.Flags().IntVarP(
+		&precision,
+		"precision",
+		"p",
+		googlesheets.DefaultPrecision,
+		`(Optional) The number of decimal places that the uptimes were written with`,
+	)
+	verifyCmd.Flags().Float64Var(
+		&verifyTolerance,
+		"tolerance",
+		googlesheets.DefaultVerifyTolerance,
+		`(Optional) The largest difference allowed between an archived uptime and NodePing's`,
+	)
+	verifyCmd.Flags().BoolVar(
+		&fix,
+		"fix",
+		false,
+		`(Optional) Rewrite the cells that are missing or differ from NodePing`,
+	)
+	addMonthFormatFlags(verifyCmd)
+	addLockFlags(verifyCmd)
+}
@@ -0,0 +1,263 @@
+package googlesheets
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/api/sheets/v4"
+)
+
+// AllTimeSheetName is the name of the tab with every month of every year, in place of the year
+const AllTimeSheetName = "All"
+
+// yearDigits finds the candidates for the year in the name of a tab
+var yearDigits = regexp.MustCompile(`\d{4}`)
+
+// yearTab holds the values of a year tab of the wide layout, from A1, and the check IDs of its rows
+type yearTab struct {
+	year      string
+	cells     [][]any
+	checkRows map[int]string // Keyed by row (1-based)
+}
+
+// allTimeCheck is a check's row in the "All" tab, with its uptime for each month
+type allTimeCheck struct {
+	id      string
+	label   string
+	uptimes map[time.Time]float64
+}
+
+// UpdateAllTimeTab rebuilds the "All" tab (see AllTimeSheetName) from the year tabs of the wide layout.
+// It has a row for every check in any of the year tabs, including the retired ones, in alphabetical
+// order, with a column for every month of every year, in calendar order, followed by an average
+// column for each year, and a totals row. A check's rows in the year tabs are matched by their check
+// ID, like EnsureCheckRowExists, or else by their names, and its row in the "All" tab gets the same
+// check ID and its latest name. The averages and totals are formulas, so that they follow any change
+// to the tab.
+func UpdateAllTimeTab(precision int, thresholds UptimeThresholds, sheetsData SheetsData) error {
+	tabs, err := readYearTabs(sheetsData)
+	if err != nil {
+		return err
+	}
+	if len(tabs) == 0 {
+		return nil
+	}
+
+	sheetName := sheetsData.GetSheetName(AllTimeSheetName)
+	sheetID, err := EnsureSheetExists(sheetName, thresholds, sheetsData)
+	if err != nil {
+		return err
+	}
+	sheetsData.SheetID = sheetID
+
+	properties, err := GetGridProperties(sheetName, sheetsData)
+	if err != nil {
+		return err
+	}
+
+	rows, metadata := buildAllTimeRows(tabs, precision, sheetsData.MonthFormat)
+	tab := &Snapshot{
+		SpreadsheetID: sheetsData.SpreadsheetID,
+		SheetName:     sheetName,
+		RowCount:      int64(len(rows)),
+		ColumnCount:   int64(len(rows[MonthHeaderRow-1].Values)),
+		Rows:          rows,
+		Metadata:      metadata,
+	}
+	if properties.GridProperties != nil {
+		tab.RowCount = max(tab.RowCount, properties.GridProperties.RowCount)
+		tab.ColumnCount = max(tab.ColumnCount, properties.GridProperties.ColumnCount)
+	}
+
+	if err := writeSnapshot(tab, sheetsData); err != nil {
+		return err
+	}
+
+	slog.Info("updated all-time tab", "sheet", sheetName, "years", len(tabs), "rows", len(rows))
+	return nil
+}
+
+// readYearTabs reads the values of the year tabs, i.e. those whose names are the tab template filled
+// in with a year, and the check IDs of their rows, in the order of their years
+func readYearTabs(sheetsData SheetsData) ([]yearTab, error) {
+	resp, err := sheetsData.Service.Spreadsheets.Get(sheetsData.SpreadsheetID).Fields("sheets.properties(sheetId,title)").Do()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the tabs of spreadsheet %s: %w", sheetsData.SpreadsheetID, err)
+	}
+
+	var tabs []yearTab
+	for _, sheet := range resp.Sheets {
+		title := sheet.Properties.Title
+		candidates := yearDigits.FindAllString(title, -1)
+		i := slices.IndexFunc(candidates, func(year string) bool { return sheetsData.GetSheetName(year) == title })
+		if i < 0 {
+			continue
+		}
+
+		tab := yearTab{year: candidates[i]}
+		values, err := sheetsData.Service.Spreadsheets.Values.Get(sheetsData.SpreadsheetID, SheetRange(title, "")).
+			ValueRenderOption("UNFORMATTED_VALUE").
+			Do()
+		if err != nil {
+			return nil, fmt.Errorf("error getting the values of %s: %w", title, err)
+		}
+		tab.cells = values.Values
+
+		sheetsData.SheetID = sheet.Properties.SheetId
+		tab.checkRows, err = GetCheckRowsFromMetadata(sheetsData)
+		if err != nil {
+			return nil, err
+		}
+		tabs = append(tabs, tab)
+	}
+
+	slices.SortFunc(tabs, func(a, b yearTab) int { return strings.Compare(a.year, b.year) })
+	return tabs, nil
+}
+
+// buildAllTimeRows returns the rows of the "All" tab described by UpdateAllTimeTab, given the year tabs
+// in the order of their years, and the developer metadata with the check IDs of its rows
+func buildAllTimeRows(tabs []yearTab, precision int, format MonthFormat) ([]*sheets.RowData, []SnapshotMetadata) {
+	checks := map[string]*allTimeCheck{}
+	byLabel := map[string]string{}
+
+	// The rows with check IDs come first, so that the rows of the same checks without them (e.g. those
+	// written by earlier versions) can be matched by their names
+	for _, withID := range []bool{true, false} {
+		for _, tab := range tabs {
+			columns := monthColumns(tab.cells, format)
+			for i := FirstCheckRow - 1; i < len(tab.cells); i++ {
+				label := strings.TrimSpace(cellLabel(tab.cells, i))
+				id := tab.checkRows[i+1]
+				if label == "" || isSectionLabel(label) || (id != "") != withID {
+					continue
+				}
+
+				key := id
+				if key == "" {
+					key = byLabel[strings.ToLower(label)]
+				}
+				if key == "" {
+					key = "label:" + strings.ToLower(label)
+				}
+
+				check, ok := checks[key]
+				if !ok {
+					check = &allTimeCheck{id: id, uptimes: map[time.Time]float64{}}
+					checks[key] = check
+				}
+				if withID || check.label == "" {
+					check.label = label
+				}
+				byLabel[strings.ToLower(label)] = key
+
+				for _, column := range columns {
+					if column.index >= len(tab.cells[i]) {
+						continue
+					}
+					if _, ok := check.uptimes[column.month]; ok {
+						continue
+					}
+					if uptime, ok := parseUptimeCell(fmt.Sprintf("%v", tab.cells[i][column.index])); ok {
+						check.uptimes[column.month] = uptime
+					}
+				}
+			}
+		}
+	}
+
+	var months []time.Time
+	for _, tab := range tabs {
+		for _, column := range monthColumns(tab.cells, format) {
+			if !slices.ContainsFunc(months, column.month.Equal) {
+				months = append(months, column.month)
+			}
+		}
+	}
+	slices.SortFunc(months, time.Time.Compare)
+
+	// The first and last (0-based) month column of each year, for its average
+	var years []string
+	firstColumns, lastColumns := map[string]int{}, map[string]int{}
+	for i, month := range months {
+		year := month.Format("2006")
+		if _, ok := firstColumns[year]; !ok {
+			years = append(years, year)
+			firstColumns[year] = i + 1
+		}
+		lastColumns[year] = i + 1
+	}
+
+	sorted := make([]*allTimeCheck, 0, len(checks))
+	for _, check := range checks {
+		sorted = append(sorted, check)
+	}
+	slices.SortFunc(sorted, func(a, b *allTimeCheck) int {
+		if c := strings.Compare(strings.ToLower(a.label), strings.ToLower(b.label)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.id, b.id)
+	})
+
+	numberFormat := &sheets.CellFormat{NumberFormat: UptimeNumberFormat(precision)}
+	textCell := func(text string) *sheets.CellData {
+		return &sheets.CellData{UserEnteredValue: &sheets.ExtendedValue{StringValue: &text}}
+	}
+	formulaCell := func(formula string) *sheets.CellData {
+		return &sheets.CellData{UserEnteredValue: &sheets.ExtendedValue{FormulaValue: &formula}, UserEnteredFormat: numberFormat}
+	}
+	averageCell := func(firstColumn, firstRow, lastColumn, lastRow int) *sheets.CellData {
+		first, _ := ConvertColumnIndexToLetter(int64(firstColumn))
+		last, _ := ConvertColumnIndexToLetter(int64(lastColumn))
+		return formulaCell(fmt.Sprintf(`=IFERROR(AVERAGE(%s%d:%s%d),"")`, first, firstRow, last, lastRow))
+	}
+
+	headings := &sheets.RowData{Values: []*sheets.CellData{textCell("Checks")}}
+	for _, month := range months {
+		headings.Values = append(headings.Values, textCell(format.Header(month)))
+	}
+	for _, year := range years {
+		headings.Values = append(headings.Values, textCell(year+" avg"))
+	}
+
+	rows := []*sheets.RowData{{Values: []*sheets.CellData{nil, textCell("Uptime Percent")}}, headings}
+	var metadata []SnapshotMetadata
+	for _, check := range sorted {
+		row := len(rows) + 1
+		data := &sheets.RowData{Values: []*sheets.CellData{textCell(check.label)}}
+		for _, month := range months {
+			uptime, ok := check.uptimes[month]
+			if !ok {
+				data.Values = append(data.Values, nil)
+				continue
+			}
+			value := roundToPrecision(uptime, precision)
+			data.Values = append(data.Values, &sheets.CellData{
+				UserEnteredValue:  &sheets.ExtendedValue{NumberValue: &value},
+				UserEnteredFormat: numberFormat,
+			})
+		}
+		for _, year := range years {
+			data.Values = append(data.Values, averageCell(firstColumns[year], row, lastColumns[year], row))
+		}
+
+		if check.id != "" {
+			metadata = append(metadata, SnapshotMetadata{Key: CheckIDMetadataKey, Value: check.id, Dimension: "ROWS", Index: row - 1})
+		}
+		rows = append(rows, data)
+	}
+
+	if len(sorted) > 0 {
+		totals := &sheets.RowData{Values: []*sheets.CellData{textCell(SummaryTotalsLabel)}}
+		for column := 1; column < len(headings.Values); column++ {
+			totals.Values = append(totals.Values, averageCell(column, FirstCheckRow, column, len(rows)))
+		}
+		rows = append(rows, totals)
+	}
+
+	return rows, metadata
+}
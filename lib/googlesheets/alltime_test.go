@@ -0,0 +1,64 @@
+package googlesheets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_buildAllTimeRows(t *testing.T) {
+	tabs := []yearTab{
+		{
+			year: "2023",
+			cells: [][]any{
+				{"", "Uptime Percent"},
+				{"Checks", "November 2023", "December 2023", "Year avg"},
+				{"Website", 99.5, 99.6, 99.55},
+				{"API", 98.0, "", 98.0},
+				{SummaryTotalsLabel, 98.75, 99.6},
+				{RetiredSectionLabel},
+				{"Old", 90.0},
+			},
+		},
+		{
+			year: "2024",
+			cells: [][]any{
+				{"", "Uptime Percent"},
+				{"Checks", "February 2024", "2024-01", "February 2024"},
+				{"api", 97.0, 96.0, 95.0},
+				{"Website (new)", 99.9},
+			},
+			checkRows: map[int]string{3: "api-id", 4: "web-id"},
+		},
+	}
+
+	rows, metadata := buildAllTimeRows(tabs, 3, MonthFormat{})
+
+	assert.Equal(t, [][]string{
+		{"", "Uptime Percent"},
+		{"Checks", "November 2023", "December 2023", "January 2024", "February 2024", "2023 avg", "2024 avg"},
+		{"api", "98", "", "96", "97", `=IFERROR(AVERAGE(B3:C3),"")`, `=IFERROR(AVERAGE(D3:E3),"")`},
+		{"Old", "90", "", "", "", `=IFERROR(AVERAGE(B4:C4),"")`, `=IFERROR(AVERAGE(D4:E4),"")`},
+		{"Website", "99.5", "99.6", "", "", `=IFERROR(AVERAGE(B5:C5),"")`, `=IFERROR(AVERAGE(D5:E5),"")`},
+		{"Website (new)", "", "", "", "99.9", `=IFERROR(AVERAGE(B6:C6),"")`, `=IFERROR(AVERAGE(D6:E6),"")`},
+		{
+			SummaryTotalsLabel,
+			`=IFERROR(AVERAGE(B3:B6),"")`, `=IFERROR(AVERAGE(C3:C6),"")`, `=IFERROR(AVERAGE(D3:D6),"")`,
+			`=IFERROR(AVERAGE(E3:E6),"")`, `=IFERROR(AVERAGE(F3:F6),"")`, `=IFERROR(AVERAGE(G3:G6),"")`,
+		},
+	}, rowTexts(rows), "rows without check IDs should be matched by name, and the first column of a month should win")
+
+	assert.Equal(t, []SnapshotMetadata{
+		{Key: CheckIDMetadataKey, Value: "api-id", Dimension: "ROWS", Index: 2},
+		{Key: CheckIDMetadataKey, Value: "web-id", Dimension: "ROWS", Index: 5},
+	}, metadata)
+}
+
+func Test_buildAllTimeRows_noChecks(t *testing.T) {
+	tabs := []yearTab{{year: "2024", cells: [][]any{{"", "Uptime Percent"}, {"Checks", "Heute"}}}}
+
+	rows, metadata := buildAllTimeRows(tabs, 3, MonthFormat{Locale: "fr"})
+
+	assert.Equal(t, [][]string{{"", "Uptime Percent"}, {"Checks"}}, rowTexts(rows), "there should be no totals row without checks")
+	assert.Empty(t, metadata)
+}
@@ -1,8 +1,11 @@
 package googlesheets
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,15 +16,168 @@ import (
 	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
 )
 
-const MonthHeaderRow = 2
+const (
+	MonthHeaderRow   = 2
+	FirstCheckRow    = 3
+	DefaultPrecision = 3
+)
+
+// ArchiveOptions holds the optional settings for ArchiveResultsForMonth
+type ArchiveOptions struct {
+	CountLimit      int      // The maximum number of results to write (defaults to 1000)
+	Precision       *int     // The number of decimal places written for each uptime value (DefaultPrecision if nil)
+	UptimeTolerance *float64 // The allowed difference between NodePing's and the computed uptime (see nodeping.ClientConfig)
+	Daily           bool     // Whether to also write each day's uptime to the "<year> daily" tab
+
+	// Services whose composite uptime is written to the "<year> services" tab
+	Services []nodeping.Service
+
+	// SLOs whose error budgets are written to the "SLO" tab
+	SLOs []nodeping.SLO
+
+	// How to mark the rows of checks that have left the contact group (see RetiredNone etc.)
+	RetiredTreatment string
+
+	// The thresholds for coloring the uptime cells of new tabs (defaults to DefaultUptimeThresholds)
+	Thresholds UptimeThresholds
+
+	Charts      bool // Whether to create or refresh the charts on the "<year> charts" tab
+	WorstChecks int  // The number of checks in the worst checks chart (defaults to 10)
+
+	// How to create and share the spreadsheet, if needed
+	Spreadsheet SpreadsheetConfig
+
+	// Whether to protect the columns of months that have ended, so that only the service account
+	// and the Admins can edit them. A column is only protected once all its checks have been written.
+	ProtectMonths bool
+	Admins        []Grantee
+
+	// Whether to add notes with the check's details to its name, and with NodePing's down and
+	// enabled time to each uptime
+	Notes bool
+
+	// How the uptimes are laid out (see LayoutWide etc.), and the template for the names of the tabs,
+	// e.g. "{group} {year}" (defaults to DefaultTabTemplate). The retired checks, summary, charts and
+	// protected months are only for the wide layout.
+	Layout      string
+	TabTemplate string
+
+	// How the month headings of the wide and transposed layouts are written (defaults to e.g. "March 2024")
+	MonthFormat MonthFormat
+
+	// Whether to print the changes that the run would make to the wide layout's year tabs (in the
+	// PlanFormat, see PlanFormatText etc.) instead of making any changes
+	DryRun     bool
+	PlanFormat string
+
+	// Where to save a snapshot of each tab before the run changes it (none if nil), and how many
+	// snapshots of each tab to keep (defaults to DefaultSnapshotsKept)
+	Snapshots     Store
+	SnapshotsKept int
+
+	// How long to wait for another run to finish changing the spreadsheet before giving up, and how
+	// long this run's lock on it lasts if it isn't released (defaults to DefaultLockLease)
+	LockWait  time.Duration
+	LockLease time.Duration
+
+	// Whether to mark the column of a month that hasn't ended, such as that of a "ThisMonth" run, as
+	// provisional (see MarkMonthProvisional). Its uptimes are overwritten by each run, and the marking is
+	// removed by the first run after the month ends, e.g. a "LastMonth" run, whether or not it upserts.
+	// This is only for the wide layout.
+	Upsert bool
+
+	// Whether to rebuild the "All" tab, with every month of every year side by side, from the year tabs
+	// after they are written (see UpdateAllTimeTab). This is only for the wide layout.
+	AllTime bool
+
+	// Where to save a checkpoint after each check is written (none if nil), and whether to resume
+	// from the checkpoint of an earlier run of the same period that didn't finish. The long layout
+	// writes all its rows at once, so it has no checkpoints.
+	Checkpoints Store
+	Resume      bool
+}
 
 type SheetsData struct {
-	SpreadsheetID string // The ID of the whole Google Sheets file
-	SheetID       int64  // The index of the individual sheet
+	SpreadsheetID string      // The ID of the whole Google Sheets file
+	SheetID       int64       // The index of the individual sheet
+	SheetName     string      // The title of the individual sheet, if it isn't from the TabTemplate
+	TabTemplate   string      // The template for the titles of the tabs (see TabName), which defaults to the year
+	Group         string      // The contact group, for the TabTemplate
+	MonthFormat   MonthFormat // How the month headings are written
 	Service       *sheets.Service
 }
 
-func EnsureSheetExists(sheetName string, sheetsData SheetsData) (int64, error) {
+// GetSheetName returns the title of the individual sheet, which defaults to the TabTemplate filled in
+// with the year. Tabs that aren't for a year, such as the SLO tab, pass their name instead.
+func (s SheetsData) GetSheetName(year string) string {
+	if s.SheetName != "" {
+		return s.SheetName
+	}
+	return TabName(s.TabTemplate, s.Group, year)
+}
+
+// getMonthHeaders returns the cells of the month heading row, from column B to the end of the sheet's grid
+func getMonthHeaders(sheetName string, properties *sheets.SheetProperties, sheetsData SheetsData) ([]any, error) {
+	lastColumn, err := ConvertColumnIndexToLetter(max(properties.GridProperties.ColumnCount-1, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	monthsRange := SheetRange(sheetName, fmt.Sprintf("B%d:%s%d", MonthHeaderRow, lastColumn, MonthHeaderRow))
+	resp, err := sheetsData.Service.Spreadsheets.Values.Get(sheetsData.SpreadsheetID, monthsRange).Do()
+	if err != nil {
+		return nil, fmt.Errorf("error getting month headings for %s: %w", monthsRange, err)
+	}
+
+	if len(resp.Values) == 0 {
+		return nil, nil
+	}
+	return resp.Values[0], nil
+}
+
+// getCheckNames returns the cells of the check name column, from FirstCheckRow to the end of the sheet's grid
+func getCheckNames(sheetName string, properties *sheets.SheetProperties, sheetsData SheetsData) ([][]any, error) {
+	rowCount := max(properties.GridProperties.RowCount, FirstCheckRow)
+	checksRange := SheetRange(sheetName, fmt.Sprintf("A%d:A%d", FirstCheckRow, rowCount))
+	resp, err := sheetsData.Service.Spreadsheets.Values.Get(sheetsData.SpreadsheetID, checksRange).Do()
+	if err != nil {
+		return nil, fmt.Errorf("error getting NodePing Check names from %s: %w", sheetName, err)
+	}
+
+	return resp.Values, nil
+}
+
+// OpenSheet returns the SheetsData of an existing tab in the spreadsheet
+func OpenSheet(spreadsheetID, sheetName string) (SheetsData, error) {
+	config := GetAuthConfig()
+	client := newGoogleClient(config)
+
+	srv, err := sheets.New(client)
+	if err != nil {
+		return SheetsData{}, fmt.Errorf("unable to retrieve Sheets client: %w", err)
+	}
+
+	sheetsData := SheetsData{SpreadsheetID: spreadsheetID, SheetName: sheetName, Service: srv}
+	exists, sheetID, err := GetSheetIDFromTitle(sheetName, sheetsData)
+	if err != nil {
+		return SheetsData{}, err
+	}
+	if !exists {
+		return SheetsData{}, fmt.Errorf("there is no sheet %s in spreadsheet %s", sheetName, spreadsheetID)
+	}
+
+	sheetsData.SheetID = sheetID
+	return sheetsData, nil
+}
+
+// EnsureSheetExists creates the tab if it doesn't already exist, and returns its sheet ID. A new tab
+// gets the headings and the conditional formatting rules that color its uptime cells.
+func EnsureSheetExists(sheetName string, thresholds UptimeThresholds, sheetsData SheetsData) (int64, error) {
+	return ensureUptimeSheetExists(sheetName, "Checks", thresholds, sheetsData)
+}
+
+// ensureUptimeSheetExists is like EnsureSheetExists, with the heading of column A (in row 2) given
+func ensureUptimeSheetExists(sheetName, columnHeading string, thresholds UptimeThresholds, sheetsData SheetsData) (int64, error) {
 	doesSheetExist, sheetID, err := GetSheetIDFromTitle(sheetName, sheetsData)
 	if err != nil {
 		return 0, err
@@ -42,13 +198,20 @@ func EnsureSheetExists(sheetName string, sheetsData SheetsData) (int64, error) {
 
 		spreadsheetID := sheetsData.SpreadsheetID
 		srv := sheetsData.Service
-		_, err := srv.Spreadsheets.BatchUpdate(spreadsheetID, rbb).Context(context.Background()).Do()
+		resp, err := srv.Spreadsheets.BatchUpdate(spreadsheetID, rbb).Context(context.Background()).Do()
 		if err != nil {
 			return 0, fmt.Errorf("unable to create new sheet %s. %s", sheetName, err)
 		}
 
 		_ = WriteToCellWithColumnLetter(1, "B", "Uptime Percent", sheetName, spreadsheetID, srv)
-		_ = WriteToCellWithColumnLetter(2, "A", "Checks", sheetName, spreadsheetID, srv)
+		_ = WriteToCellWithColumnLetter(2, "A", columnHeading, sheetName, spreadsheetID, srv)
+
+		formatting := &sheets.BatchUpdateSpreadsheetRequest{
+			Requests: uptimeFormatRequests(resp.Replies[0].AddSheet.Properties.SheetId, thresholds),
+		}
+		if _, err := srv.Spreadsheets.BatchUpdate(spreadsheetID, formatting).Context(context.Background()).Do(); err != nil {
+			return 0, fmt.Errorf("unable to add formatting to sheet %s: %w", sheetName, err)
+		}
 	}
 
 	doesSheetExist, sheetID, err = GetSheetIDFromTitle(sheetName, sheetsData)
@@ -64,61 +227,75 @@ func EnsureSheetExists(sheetName string, sheetsData SheetsData) (int64, error) {
 }
 
 func EnsureMonthColumnExists(month, year string, sheetsData SheetsData) (int, error) {
-	desiredMonthPosition, err := GetMonthPosition(month)
-	monthHeader := fmt.Sprintf("%s %s", month, year)
+	monthHeader := sheetsData.MonthFormat.HeaderFor(month, year)
+	sheetName := sheetsData.GetSheetName(year)
 
-	monthsRange := fmt.Sprintf("%s!B2:Z2", year)
 	srv := sheetsData.Service
 	spreadsheetID := sheetsData.SpreadsheetID
 	sheetID := sheetsData.SheetID
 
-	resp, err := srv.Spreadsheets.Values.Get(spreadsheetID, monthsRange).Do()
+	properties, err := GetGridProperties(sheetName, sheetsData)
 	if err != nil {
-		return 0, fmt.Errorf("error getting month headings for %s: %w", monthsRange, err)
+		return 0, err
 	}
 
+	headers, err := getMonthHeaders(sheetName, properties, sheetsData)
+	if err != nil {
+		return 0, err
+	}
+
+	chosenColumn, insertColumn, addColumn := findMonthColumnPosition(month, year, headers, sheetsData.MonthFormat)
+	if insertColumn {
+		if err := InsertColumn(int64(chosenColumn), sheetID, spreadsheetID, srv); err != nil {
+			return 0, fmt.Errorf("error inserting column in Google Sheets. %w", err)
+		}
+	}
+	if addColumn {
+		if err := AddColumn(sheetID, spreadsheetID, srv); err != nil {
+			return 0, err
+		}
+	}
+
+	err = WriteToCellWithColumnIndex(MonthHeaderRow, int64(chosenColumn), monthHeader, sheetName, spreadsheetID, srv)
+	return chosenColumn, err
+}
+
+// findMonthColumnPosition returns the (0-based) column for the month, given the month heading row from
+// column B, and whether a column has to be inserted there or added at the right of the sheet first.
+// The month goes in the column that already has its heading in any format, or else the first empty
+// heading, or else before the first later month or the summary columns, or else after all the other
+// columns.
+func findMonthColumnPosition(month, year string, headers []any, format MonthFormat) (int, bool, bool) {
+	desiredMonth, _ := time.Parse(DefaultMonthLayout, month+" "+year)
 	indexOfFirstMonth := 1
 
 	// No Month Heading in first results column, so just use that column
-	if len(resp.Values) < 1 {
-		err := WriteToCellWithColumnIndex(MonthHeaderRow, int64(indexOfFirstMonth), monthHeader, year, spreadsheetID, srv)
-		return indexOfFirstMonth, err
+	if len(headers) < 1 {
+		return indexOfFirstMonth, false, false
 	}
 
-	chosenColumn := 0
-	lastIndex := 0
-	for index, value := range resp.Values[0] {
-		lastIndex = index
+	for index, value := range headers {
 		columnHeader := fmt.Sprintf("%v", value)
 
 		if columnHeader == "" {
-			chosenColumn = index + indexOfFirstMonth
-			break
+			return index + indexOfFirstMonth, false, false
 		}
 
-		colMonthPosition, err := GetMonthPosition(columnHeader)
-		if err != nil {
+		// The month goes before the summary columns, if it comes after all the other months
+		isSummary := isSummaryHeader(columnHeader)
+		colMonth, err := format.Parse(columnHeader)
+		if err != nil && !isSummary {
 			continue
 		}
-		if desiredMonthPosition < colMonthPosition {
-			chosenColumn = index + indexOfFirstMonth
-			if err := InsertColumn(int64(chosenColumn), sheetID, spreadsheetID, srv); err != nil {
-				return 0, fmt.Errorf("error inserting column in Google Sheets. %w", err)
-			}
-			err := WriteToCellWithColumnIndex(MonthHeaderRow, int64(chosenColumn), monthHeader, year, spreadsheetID, srv)
-			return chosenColumn, err
+		if !isSummary && colMonth.Equal(desiredMonth) {
+			return index + indexOfFirstMonth, false, false
 		}
-	}
-
-	if chosenColumn == 0 {
-		if err := AddColumn(sheetID, spreadsheetID, srv); err != nil {
-			return 0, err
+		if isSummary || desiredMonth.Before(colMonth) {
+			return index + indexOfFirstMonth, true, false
 		}
-		chosenColumn = lastIndex + indexOfFirstMonth + 1
 	}
 
-	err = WriteToCellWithColumnIndex(MonthHeaderRow, int64(chosenColumn), monthHeader, year, spreadsheetID, srv)
-	return chosenColumn, err
+	return len(headers) + indexOfFirstMonth, false, true
 }
 
 // This returns a row number (0-indexed) and a boolean as to whether a row needs to be inserted.
@@ -130,6 +307,13 @@ func EnsureMonthColumnExists(month, year string, sheetsData SheetsData) (int, er
 //	If it comes to a cell that has a value greater (alphabetically) than the checkName, it
 //	  returns the corresponding row number and true (i.e. a row needs to be inserted)
 func findRowPositionAndWhetherToInsertARow(checkName string, rows [][]any) (int, bool) {
+	return findRowForCheck(checkName, rows, nil)
+}
+
+// findRowForCheck is like findRowPositionAndWhetherToInsertARow, except that a row whose check name
+// matches is skipped if it already belongs to a different check, i.e. its index (0-based, like the
+// rows) is a key of claimedRows. This gives checks with the same name rows of their own.
+func findRowForCheck(checkName string, rows [][]any, claimedRows map[int]string) (int, bool) {
 	if len(rows) == 0 || len(rows[0]) == 0 {
 		return 0, false
 	}
@@ -144,9 +328,14 @@ func findRowPositionAndWhetherToInsertARow(checkName string, rows [][]any) (int,
 		}
 
 		value := strings.ToLower(fmt.Sprintf("%v", cells[0]))
-		if value == "" || value == checkName {
+		if value == "" {
 			return i, false
 		}
+		if value == checkName {
+			if _, claimed := claimedRows[i]; !claimed {
+				return i, false
+			}
+		}
 		if value > checkName {
 			return i, true
 		}
@@ -156,39 +345,131 @@ func findRowPositionAndWhetherToInsertARow(checkName string, rows [][]any) (int,
 	return rowCount, false
 }
 
-// EnsureCheckRowExists looks for a match for the check name in the Sheet's A column (starting at row 3)
-// If it finds a match or a blank cell, it returns that row number.  Otherwise, it looks down the column
-// until it finds an existing check name that comes after it in terms of alphabetical order.
-// Once it finds such an existing check name, it inserts a row above the existing row and then
-// inserts the new check name into the first cell of the inserted row.
-func EnsureCheckRowExists(nodePingCheck, year string, sheetsData SheetsData) (int, error) {
-	const indexOfFirstCheck = 3
-	checksRange := fmt.Sprintf("%s!A%d:A100", year, indexOfFirstCheck)
+// EnsureCheckRowExists looks for the row of a NodePing check and returns its row number.
+//
+// If a checkID is given, it first looks for a row that has that ID in its developer metadata. If the
+// label in that row's A cell differs (i.e. the check has been renamed), the label is updated.
+//
+// Otherwise, it looks for a match for the check name in the Sheet's A column (starting at row 3),
+// ignoring rows that already belong to another check ID. If it finds a match or a blank cell, it
+// returns that row number.  Otherwise, it looks down the column until it finds an existing check name
+// that comes after it in terms of alphabetical order. Once it finds such an existing check name, it
+// inserts a row above the existing row and then inserts the new check name into the first cell of the
+// inserted row. The totals row (see UpdateSummary) and the section of retired checks (see MarkRetiredChecks)
+// are left out of the search, so that a new check goes above them. Either way, the checkID is then attached to the row.
+func EnsureCheckRowExists(checkID, nodePingCheck, year string, sheetsData SheetsData) (int, error) {
+	row, _, err := ensureCheckRow(checkID, nodePingCheck, year, sheetsData)
+	return row, err
+}
+
+// ensureCheckRow is EnsureCheckRowExists, which also reports whether a row was inserted for the check
+func ensureCheckRow(checkID, nodePingCheck, year string, sheetsData SheetsData) (int, bool, error) {
+	sheetName := sheetsData.GetSheetName(year)
 	srv := sheetsData.Service
 	spreadsheetID := sheetsData.SpreadsheetID
 	sheetID := sheetsData.SheetID
 
+	properties, err := GetGridProperties(sheetName, sheetsData)
+	if err != nil {
+		return 0, false, err
+	}
+
+	rowCount := max(properties.GridProperties.RowCount, FirstCheckRow)
+	checksRange := SheetRange(sheetName, fmt.Sprintf("A%d:A%d", FirstCheckRow, rowCount))
 	resp, err := srv.Spreadsheets.Values.Get(spreadsheetID, checksRange).Do()
 	if err != nil {
-		return 0, fmt.Errorf("error getting NodePing Check names for %s: %w", nodePingCheck, err)
+		return 0, false, fmt.Errorf("error getting NodePing Check names for %s: %w", nodePingCheck, err)
 	}
 
-	rowInRange, insertRow := findRowPositionAndWhetherToInsertARow(nodePingCheck, resp.Values)
-	chosenRow := rowInRange + indexOfFirstCheck
+	var checkRows map[int]string
+	if checkID != "" {
+		checkRows, err = GetCheckRowsFromMetadata(sheetsData)
+		if err != nil {
+			return 0, false, err
+		}
+	}
+
+	chosenRow, insertRow, found := findCheckRow(checkID, nodePingCheck, resp.Values, checkRows)
+	if found {
+		return chosenRow, false, updateRenamedCheck(chosenRow, nodePingCheck, resp.Values, sheetName, sheetsData)
+	}
 
 	if insertRow {
-		row := chosenRow - 1 // It must be doing an "insert below"
 		slog.Info("inserting row for NodePing check", "row", chosenRow, "check", nodePingCheck)
-		if err := InsertRow(int64(row), sheetID, spreadsheetID, srv); err != nil {
-			return 0, fmt.Errorf("error inserting a row in Google sheets: %w", err)
+		rbb := &sheets.BatchUpdateSpreadsheetRequest{Requests: newInsertRowRequests(chosenRow, sheetID)}
+		if _, err := srv.Spreadsheets.BatchUpdate(spreadsheetID, rbb).Context(context.Background()).Do(); err != nil {
+			return 0, false, fmt.Errorf("error inserting a row in Google sheets: %w", err)
+		}
+	} else if err := EnsureRowCount(int64(chosenRow), properties, spreadsheetID, srv); err != nil {
+		return 0, false, err
+	}
+
+	err = WriteToCellWithColumnLetter(int64(chosenRow), "A", nodePingCheck, sheetName, spreadsheetID, srv)
+	if err != nil || checkID == "" {
+		return chosenRow, insertRow, err
+	}
+
+	return chosenRow, insertRow, SetCheckRowMetadata(chosenRow, checkID, sheetsData)
+}
+
+// newInsertRowRequests returns the requests that insert a row of a year tab at the row (1-based), e.g.
+// for a new check. The row takes the formatting of the row above, such as the uptimes' number format,
+// except for the colors of a retired row (see RetiredGrey), so that a new check doesn't look retired.
+func newInsertRowRequests(row int, sheetID int64) []*sheets.Request {
+	return []*sheets.Request{
+		{
+			InsertDimension: &sheets.InsertDimensionRequest{
+				Range: &sheets.DimensionRange{
+					SheetId:    sheetID,
+					Dimension:  "ROWS",
+					StartIndex: int64(row - 1),
+					EndIndex:   int64(row),
+				},
+				InheritFromBefore: true,
+			},
+		},
+		newRowFormatRequest(row, &sheets.CellData{}, sheetID),
+	}
+}
+
+// findCheckRow returns the row (1-based) for the check, given the check names from FirstCheckRow and the
+// check IDs of the rows (see GetCheckRowsFromMetadata), and whether a row has to be inserted there.
+// It also returns whether the row was found by the check ID, rather than chosen by the check name.
+func findCheckRow(checkID, label string, rows [][]any, checkRows map[int]string) (int, bool, bool) {
+	claimedRows := map[int]string{}
+	if checkID != "" {
+		for row, id := range checkRows {
+			if id == checkID {
+				return row, false, true
+			}
+			claimedRows[row-FirstCheckRow] = id
 		}
 	}
 
-	err = WriteToCellWithColumnLetter(int64(chosenRow), "A", nodePingCheck, year, spreadsheetID, srv)
-	return chosenRow, err
+	// New checks go above the totals row and the section of retired checks, if there are any
+	checksEnd := findChecksEnd(rows)
+	rowInRange, insertRow := findRowForCheck(label, rows[:checksEnd], claimedRows)
+	if rowInRange == checksEnd && checksEnd < len(rows) {
+		insertRow = true
+	}
+
+	return rowInRange + FirstCheckRow, insertRow, false
+}
+
+// updateRenamedCheck writes the check's current label to the A cell of its row, if it has changed
+func updateRenamedCheck(row int, label string, rows [][]any, sheetName string, sheetsData SheetsData) error {
+	i := row - FirstCheckRow
+	if i >= 0 && i < len(rows) && len(rows[i]) > 0 && fmt.Sprintf("%v", rows[i][0]) == label {
+		return nil
+	}
+
+	slog.Info("updating label of renamed NodePing check", "row", row, "check", label)
+	return WriteToCellWithColumnLetter(int64(row), "A", label, sheetName, sheetsData.SpreadsheetID, sheetsData.Service)
 }
 
-func GetAuthConfig() *jwt.Config {
+// GetAuthConfig returns the service account's credentials from the environment, for the Sheets API and
+// any other scopes given, such as the SpreadsheetConfig's DriveScopes
+func GetAuthConfig(scopes ...string) *jwt.Config {
 	privateKey := GetRequiredEnvVar("GOOGLE_AUTH_PRIVATE_KEY")
 	privateKey = strings.Replace(privateKey, "\\n", "\n", -1)
 
@@ -197,83 +478,497 @@ func GetAuthConfig() *jwt.Config {
 		PrivateKeyID: GetRequiredEnvVar("GOOGLE_AUTH_PRIVATE_KEY_ID"),
 		PrivateKey:   []byte(privateKey),
 		TokenURL:     GetRequiredEnvVar("GOOGLE_AUTH_TOKEN_URI"),
-		Scopes:       []string{"https://www.googleapis.com/auth/spreadsheets"},
+		Scopes:       append([]string{sheets.SpreadsheetsScope}, scopes...),
 	}
 
 	return config
 }
 
-func ArchiveResultsForMonth(contactGroupName, period, spreadsheetID, nodePingToken string, countLimit int) error {
-	if countLimit < 1 {
-		countLimit = 1000
+// CheckUptime is the uptime to be written to the row of one check (or of one service)
+type CheckUptime struct {
+	CheckID string // The NodePing check ID, or empty if the row isn't for a single check
+	Label   string
+	Uptime  float64
+
+	// NodePing's enabled and down time behind the uptime, if it is for a single check
+	Response nodeping.UptimeResponse
+
+	// Why the check's uptime couldn't be retrieved from NodePing, in which case there is nothing to write
+	Error error
+}
+
+// MonthUptimes holds the uptimes to be written to one month column of a year tab
+type MonthUptimes struct {
+	Month   string        // e.g. "January"
+	Year    string        // e.g. "2024"
+	Uptimes []CheckUptime // In label order
+}
+
+// GetMonthUptimes splits the results into the month columns to be written. If the results cover a
+// single month, the totals are used for that month. Otherwise, NodePing's monthly entries are used
+// for each month of the period that has any enabled time, in chronological order. This way a whole
+// year can be filled in with one NodePing call per check. A check whose uptimes couldn't be retrieved
+// is in every month, with its error and no uptime, so that it is reported as failed rather than written.
+func GetMonthUptimes(results nodeping.UptimeResults) []MonthUptimes {
+	start := time.Unix(results.StartTime, 0).UTC()
+	end := time.Unix(results.EndTime, 0).UTC()
+
+	if start.Year() == end.Year() && start.Month() == end.Month() {
+		uptimes := make([]CheckUptime, 0, len(results.Checks))
+		for _, check := range results.Checks {
+			uptimes = append(uptimes, CheckUptime{
+				CheckID:  check.ID,
+				Label:    check.Label,
+				Uptime:   results.Uptimes[check.ID],
+				Response: results.Responses[check.ID],
+				Error:    results.Errors[check.ID],
+			})
+		}
+
+		// Get the human readable form of the month and year
+		monthTime := results.StartTime + 86400 // Add seconds per day to ensure time zone issues don't point to previous month
+		return []MonthUptimes{{
+			Month:   time.Unix(monthTime, 0).Format("January"),
+			Year:    time.Unix(monthTime, 0).Format("2006"),
+			Uptimes: uptimes,
+		}}
 	}
 
-	config := GetAuthConfig()
-	client := config.Client(context.Background())
+	var months []MonthUptimes
+	for month := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC); !month.After(end); month = month.AddDate(0, 1, 0) {
+		key := month.Format(nodeping.MonthLayout)
+		var uptimes []CheckUptime
+
+		found := false
+		for _, check := range results.Checks {
+			if err := results.Errors[check.ID]; err != nil {
+				uptimes = append(uptimes, CheckUptime{CheckID: check.ID, Label: check.Label, Error: err})
+				continue
+			}
+
+			entry, ok := results.MonthlyResponses[check.ID][key]
+			if !ok || entry.Enabled <= 0 {
+				continue
+			}
+			uptimes = append(uptimes, CheckUptime{
+				CheckID:  check.ID,
+				Label:    check.Label,
+				Uptime:   entry.ComputedUptime(),
+				Response: entry,
+			})
+			found = true
+		}
+
+		if !found {
+			continue
+		}
+
+		months = append(months, MonthUptimes{
+			Month:   month.Format("January"),
+			Year:    month.Format("2006"),
+			Uptimes: uptimes,
+		})
+	}
+
+	return months
+}
+
+// FormatUptime formats an uptime percentage with the given number of decimal places
+func FormatUptime(percentage float64, precision int) string {
+	return strconv.FormatFloat(percentage, 'f', precision, 64)
+}
+
+// ArchiveResultsForMonth gets the uptimes of the contact group's checks for the period from NodePing,
+// and writes them to the spreadsheet. It returns a report of what was written, even if the run fails
+// part way through. If any of the checks can't be written, the rest are still written, and the run
+// then fails with the error of the report (see RunReport.Err).
+func ArchiveResultsForMonth(contactGroupName, period, spreadsheetID, nodePingToken string, options ArchiveOptions) (report RunReport, err error) {
+	report = RunReport{
+		SpreadsheetID: spreadsheetID,
+		ContactGroup:  contactGroupName,
+		Period:        period,
+		Layout:        options.Layout,
+		StartedAt:     time.Now().UTC(),
+	}
+	if report.Layout == "" {
+		report.Layout = LayoutWide
+	}
+	defer func() { report.Seconds = secondsSince(report.StartedAt) }()
+
+	if options.CountLimit < 1 {
+		options.CountLimit = 1000
+	}
+	precision := DefaultPrecision
+	if options.Precision != nil {
+		precision = *options.Precision
+	}
+	if precision < 0 {
+		return report, fmt.Errorf("the precision can't be negative: %d", precision)
+	}
+	options.Precision = &precision
+	if options.Thresholds == (UptimeThresholds{}) {
+		options.Thresholds = DefaultUptimeThresholds
+	}
+
+	countLimit := options.CountLimit
+	thresholds := options.Thresholds
+
+	config := GetAuthConfig(options.Spreadsheet.DriveScopes()...)
+	client := newGoogleClient(config)
 
 	srv, err := sheets.New(client)
 	if err != nil {
-		return fmt.Errorf("unable to retrieve Sheets client: %w", err)
+		return report, fmt.Errorf("unable to retrieve Sheets client: %w", err)
+	}
+
+	if options.DryRun {
+		if spreadsheetID == "" {
+			return report, errors.New("a dry run needs the ID of an existing spreadsheet")
+		}
+		if unsupported := DryRunUnsupported(options); len(unsupported) > 0 {
+			return report, fmt.Errorf("a dry run can't plan the changes of %s, so leave them out", strings.Join(unsupported, ", "))
+		}
+	} else {
+		spreadsheetID, err = EnsureSpreadsheetExists(spreadsheetID, options.Spreadsheet, client, srv)
+		if err != nil {
+			return report, err
+		}
+		report.SpreadsheetID = spreadsheetID
 	}
 
 	p, err := nodeping.GetPeriod(period)
 	if err != nil {
-		return fmt.Errorf("error getting NodePing period: %w", err)
+		return report, fmt.Errorf("error getting NodePing period: %w", err)
 	}
 
-	uptimeResults, err := nodeping.GetUptimesForContactGroup(nodePingToken, contactGroupName, *p)
+	fetchStarted := time.Now()
+	npConfig := nodeping.ClientConfig{Token: nodePingToken, UptimeTolerance: options.UptimeTolerance}
+	uptimeResults, err := nodeping.GetUptimesForContactGroup(npConfig, contactGroupName, *p)
 	if err != nil {
-		return fmt.Errorf("error getting NodePing results: %w", err)
+		return report, fmt.Errorf("error getting NodePing results: %w", err)
 	}
-
-	// Get the human readable form of the month and year
-	monthTime := uptimeResults.StartTime + 86400 // Add seconds per day to ensure time zone issues don't point to previous month
-	month := time.Unix(monthTime, 0).Format("January")
-	year := time.Unix(monthTime, 0).Format("2006")
+	report.FetchSeconds = secondsSince(fetchStarted)
 
 	sheetsData := SheetsData{
 		SpreadsheetID: spreadsheetID,
+		TabTemplate:   options.TabTemplate,
+		Group:         contactGroupName,
+		MonthFormat:   options.MonthFormat,
 		Service:       srv,
 	}
 
-	sheetID, err := EnsureSheetExists(year, sheetsData)
+	monthUptimes := GetMonthUptimes(uptimeResults)
+	if len(monthUptimes) == 0 && len(uptimeResults.Errors) > 0 {
+		return report, fetchError(uptimeResults.Errors)
+	}
+
+	if options.DryRun {
+		plan, err := PlanWideResults(monthUptimes, precision, countLimit, time.Now().UTC(), sheetsData)
+		if err != nil {
+			return report, err
+		}
+
+		output, err := plan.Format(options.PlanFormat)
+		if err != nil {
+			return report, err
+		}
+		fmt.Print(output)
+		if len(uptimeResults.Errors) > 0 {
+			return report, fetchError(uptimeResults.Errors)
+		}
+		return report, nil
+	}
+
+	lock, err := AcquireLock(LockOwner(), options.LockLease, options.LockWait, sheetsData)
 	if err != nil {
-		return err
+		return report, err
 	}
+	defer func() {
+		if err := lock.Release(); err != nil {
+			slog.Error("unable to release the lock on the spreadsheet", "error", err)
+		}
+	}()
 
-	sheetsData.SheetID = sheetID
+	var checkpoints *checkpointer
+	if options.Checkpoints != nil && options.Layout != LayoutLong {
+		checkpoints, err = newCheckpointer(options.Checkpoints, options.Resume, spreadsheetID, contactGroupName, *p)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	if options.Snapshots != nil {
+		var sheetNames []string
+		if options.Layout == LayoutLong {
+			sheetNames = []string{sheetsData.GetSheetName(LongSheetName)}
+		} else {
+			for _, month := range monthUptimes {
+				if sheetName := sheetsData.GetSheetName(month.Year); !slices.Contains(sheetNames, sheetName) {
+					sheetNames = append(sheetNames, sheetName)
+				}
+			}
+		}
 
-	monthColumn, err := EnsureMonthColumnExists(month, year, sheetsData)
+		if err := snapshotSheets(sheetNames, options.SnapshotsKept, options.Snapshots, sheetsData); err != nil {
+			return report, err
+		}
+	}
+
+	var months []MonthReport
+	switch options.Layout {
+	case LayoutTransposed:
+		months, err = ArchiveTransposedResults(monthUptimes, uptimeResults.Checks, *p, precision, countLimit, options.Notes, thresholds, checkpoints, sheetsData)
+	case LayoutLong:
+		months, err = ArchiveLongResults(contactGroupName, monthUptimes, precision, countLimit, sheetsData)
+	default:
+		months, err = archiveWideResults(monthUptimes, uptimeResults.Checks, *p, config.Email, options, checkpoints, sheetsData)
+	}
+	report.Months = months
 	if err != nil {
-		return fmt.Errorf("error choosing column for '%s': %w", month, err)
+		return report, err
+	}
+
+	if options.Daily {
+		dailyResults, err := nodeping.GetDailyUptimesForContactGroup(npConfig, contactGroupName, *p)
+		if err != nil {
+			return report, fmt.Errorf("error getting NodePing daily results: %w", err)
+		}
+
+		if err := ArchiveDailyResults(dailyResults, precision, countLimit, sheetsData); err != nil {
+			return report, fmt.Errorf("error writing daily results: %w", err)
+		}
+	}
+
+	if len(options.Services) > 0 {
+		outages, err := nodeping.GetOutagesForServices(npConfig, options.Services, *p)
+		if err != nil {
+			return report, fmt.Errorf("error getting NodePing outages for services: %w", err)
+		}
+
+		serviceUptimes := GetServiceUptimes(options.Services, outages, *p, time.Now().UTC())
+		if err := ArchiveServiceUptimes(serviceUptimes, precision, thresholds, sheetsData); err != nil {
+			return report, fmt.Errorf("error writing service results: %w", err)
+		}
+	}
+
+	if len(options.SLOs) > 0 {
+		referenceTime := sloReferenceTime(*p, time.Now().UTC())
+		budgets, err := nodeping.GetErrorBudgetsForContactGroup(npConfig, contactGroupName, options.SLOs, referenceTime)
+		if err != nil {
+			return report, fmt.Errorf("error getting NodePing error budgets: %w", err)
+		}
+
+		if err := ArchiveErrorBudgets(budgets, precision, sheetsData); err != nil {
+			return report, fmt.Errorf("error writing error budgets: %w", err)
+		}
+	}
+
+	// The checkpoint is kept if any checks failed, so that a resumed run only writes those checks
+	if err := report.Err(); err != nil {
+		return report, err
+	}
+	return report, checkpoints.finish()
+}
+
+// fetchError returns an error for the checks whose uptimes couldn't be retrieved from NodePing, given
+// their errors by check ID
+func fetchError(errs map[string]error) error {
+	ids := make([]string, 0, len(errs))
+	for id := range errs {
+		ids = append(ids, id)
 	}
+	slices.Sort(ids)
+
+	joined := make([]error, 0, len(ids))
+	for _, id := range ids {
+		joined = append(joined, errs[id])
+	}
+	return fmt.Errorf("unable to get the uptimes of %d checks from NodePing: %w", len(ids), errors.Join(joined...))
+}
+
+// archiveWideResults writes the uptimes to the year tabs of the wide layout, i.e. a column for each month
+// and a row for each check, and then updates the retired checks, the summary and the charts of each year.
+// The options must have their defaults filled in. The service account can always edit protected months.
+// A check that can't be written is reported as failed, and the rest are still written. The checks
+// and month columns in the checkpoints are skipped and reused.
+func archiveWideResults(
+	months []MonthUptimes,
+	checks []nodeping.Check,
+	period nodeping.Period,
+	serviceAccount string,
+	options ArchiveOptions,
+	checkpoints *checkpointer,
+	sheetsData SheetsData,
+) ([]MonthReport, error) {
+	precision := *options.Precision
 
 	index := 1
 	const delay = time.Second * 22
 
-	for nodePingCheck, percentage := range uptimeResults.Uptimes {
-		if index > countLimit {
-			break
+	var years []string
+	sheetIDs := map[string]int64{}
+	var reports []MonthReport
+
+	checksByID := map[string]nodeping.Check{}
+	for _, check := range checks {
+		checksByID[check.ID] = check
+	}
+
+	for _, monthUptimes := range months {
+		month := monthUptimes.Month
+		year := monthUptimes.Year
+		started := time.Now()
+
+		sheetName := sheetsData.GetSheetName(year)
+		sheetID, err := EnsureSheetExists(sheetName, options.Thresholds, sheetsData)
+		if err != nil {
+			return reports, err
+		}
+
+		sheetsData.SheetID = sheetID
+		if _, ok := sheetIDs[year]; !ok {
+			years = append(years, year)
+			sheetIDs[year] = sheetID
+		}
+
+		monthCheckpoint := checkpoints.month(sheetName, month+" "+year)
+		monthColumn, err := monthCheckpoint.resumedColumn(sheetsData)
+		if err != nil {
+			return reports, err
+		}
+		if monthColumn == 0 {
+			monthColumn, err = EnsureMonthColumnExists(month, year, sheetsData)
+			if err != nil {
+				return reports, fmt.Errorf("error choosing column for '%s': %w", month, err)
+			}
+			if monthCheckpoint != nil {
+				monthCheckpoint.Column = monthColumn
+			}
+		}
+
+		columnLetter, err := ConvertColumnIndexToLetter(int64(monthColumn))
+		if err != nil {
+			return reports, err
+		}
+		report := MonthReport{Sheet: sheetName, Month: month + " " + year, Column: columnLetter}
+
+		// The column is marked before it is written, so that it is still marked if the run dies part way
+		monthEnded := monthHasEnded(month, year, time.Now().UTC())
+		if options.Upsert && !monthEnded {
+			if err := MarkMonthProvisional(sheetName, monthColumn, time.Now().UTC(), sheetsData); err != nil {
+				return reports, err
+			}
+			report.Provisional = true
+		}
+
+		span := monthSpan(month, year, period, time.Now().UTC())
+		monthCount := 0
+		for _, checkUptime := range monthUptimes.Uptimes {
+			if monthCount >= options.CountLimit {
+				break
+			}
+
+			// The quotas are per minute per user, so the checks are spread out. A request that is over
+			// a quota anyway is retried (see retryTransport).
+			if index%20 == 0 {
+				fmt.Printf("Waiting %v seconds at index %d to avoid Google Api rate limiting.\n", delay.Seconds(), index)
+				time.Sleep(delay)
+			}
+
+			nodePingCheck := checkUptime.Label
+			checkReport := CheckReport{
+				CheckID: checkUptime.CheckID,
+				Label:   nodePingCheck,
+				Column:  columnLetter,
+				Value:   roundToPrecision(checkUptime.Uptime, precision),
+			}
+
+			if monthCheckpoint.isDone(checkpointKey(checkUptime)) {
+				checkReport.Resumed = true
+				report.Checks = append(report.Checks, checkReport)
+				monthCount += 1
+				continue
+			}
+
+			if checkUptime.Error != nil {
+				checkReport.Error = checkUptime.Error.Error()
+				report.Checks = append(report.Checks, checkReport)
+				monthCount += 1
+				continue
+			}
+
+			checkRow, inserted, err := ensureCheckRow(checkUptime.CheckID, nodePingCheck, year, sheetsData)
+			if err != nil {
+				err = fmt.Errorf("error adding row for '%s': %w", nodePingCheck, err)
+			} else {
+				checkReport.Row = checkRow
+				checkReport.Inserted = inserted
+				if options.Notes {
+					err = WriteUptimeWithNotes(int64(checkRow), int64(monthColumn), checkUptime, checksByID[checkUptime.CheckID], span, precision, sheetsData)
+				} else {
+					err = WriteUptimeToCell(int64(checkRow), int64(monthColumn), checkUptime.Uptime, precision, sheetsData)
+				}
+			}
+			if err != nil {
+				slog.Error("unable to archive NodePing check", "check", nodePingCheck, "month", report.Month, "error", err)
+				checkReport.Error = err.Error()
+			} else if err := checkpoints.done(monthCheckpoint, checkpointKey(checkUptime)); err != nil {
+				return append(reports, report), err
+			}
+			report.Checks = append(report.Checks, checkReport)
+
+			index += 1
+			monthCount += 1
 		}
 
-		// The quota is 100 writes per 100 seconds per user
-		if index%20 == 0 {
-			fmt.Printf("Waiting %v seconds at index %d to avoid Google Api rate limiting.\n", delay.Seconds(), index)
-			time.Sleep(delay)
+		// A provisional column stays marked until all its checks have been written after the month ended
+		if monthEnded && !report.hasFailures() {
+			finalized, err := FinalizeMonthColumn(sheetName, monthColumn, sheetsData)
+			if err != nil {
+				return reports, err
+			}
+			if finalized {
+				slog.Info("finalized provisional month column", "sheet", sheetName, "month", report.Month)
+				report.Finalized = true
+			}
 		}
 
-		checkRow, err := EnsureCheckRowExists(nodePingCheck, year, sheetsData)
+		// Like finalizing, protecting waits for a run that writes all the checks, so that the next run
+		// can still fill in the ones that failed
+		if options.ProtectMonths && monthEnded && !report.hasFailures() {
+			editors := append([]Grantee{{Type: GranteeUser, Email: serviceAccount}}, options.Admins...)
+			if err := ProtectMonthColumn(month+" "+year, monthColumn, editors, sheetsData); err != nil {
+				return reports, err
+			}
+		}
+
+		report.Seconds = secondsSince(started)
+		reports = append(reports, report)
+	}
+
+	for _, year := range years {
+		sheetsData.SheetID = sheetIDs[year]
+		err := MarkRetiredChecks(checks, options.RetiredTreatment, year, time.Now().UTC(), sheetsData)
 		if err != nil {
-			return fmt.Errorf("error adding row for '%s'", nodePingCheck)
+			return reports, fmt.Errorf("error marking retired checks: %w", err)
 		}
 
-		err = WriteToCellWithColumnIndex(
-			int64(checkRow), int64(monthColumn),
-			fmt.Sprintf("%.3f", percentage), year,
-			spreadsheetID, srv,
-		)
+		if err := UpdateSummary(year, options.SLOs, options.Thresholds.Green, precision, sheetsData); err != nil {
+			return reports, fmt.Errorf("error updating summary: %w", err)
+		}
 
-		index += 1
+		if options.Charts {
+			if err := ArchiveCharts(year, options.WorstChecks, sheetsData); err != nil {
+				return reports, fmt.Errorf("error updating charts: %w", err)
+			}
+		}
 	}
-	return nil
+
+	if options.AllTime {
+		if err := UpdateAllTimeTab(precision, options.Thresholds, sheetsData); err != nil {
+			return reports, fmt.Errorf("error updating all-time tab: %w", err)
+		}
+	}
+
+	return reports, nil
 }
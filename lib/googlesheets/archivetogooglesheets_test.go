@@ -1,9 +1,13 @@
 package googlesheets
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
 )
 
 func Test_findRowPositionAndWhetherToInsertARow(t *testing.T) {
@@ -96,3 +100,132 @@ func Test_findRowPositionAndWhetherToInsertARow(t *testing.T) {
 		assert.Equal(t, tc.wantInsertRow, gotInsertRow, "incorrect insert row boolean in test: %s", tc.name)
 	}
 }
+
+func Test_findRowForCheck(t *testing.T) {
+	rows := [][]any{
+		{"Duplicate"},
+		{"Duplicate"},
+		{"Other"},
+	}
+
+	gotRow, gotInsertRow := findRowForCheck("Duplicate", rows, nil)
+	assert.Equal(t, 0, gotRow)
+	assert.False(t, gotInsertRow)
+
+	gotRow, gotInsertRow = findRowForCheck("Duplicate", rows, map[int]string{0: "id1"})
+	assert.Equal(t, 1, gotRow)
+	assert.False(t, gotInsertRow)
+
+	gotRow, gotInsertRow = findRowForCheck("Duplicate", rows, map[int]string{0: "id1", 1: "id2"})
+	assert.Equal(t, 2, gotRow, "a third check with the same name should get a new row")
+	assert.True(t, gotInsertRow)
+}
+
+func Test_newInsertRowRequests(t *testing.T) {
+	const sheetID = 7
+
+	// "Alpha" has been retired in grey, so it stays where it is, just above where "Beta" goes
+	rows := [][]any{{"Alpha"}, {"Gamma"}}
+	checkRows := map[int]string{3: "a", 4: "g"}
+	row, insertRow, found := findCheckRow("b", "Beta", rows, checkRows)
+	assert.Equal(t, 4, row)
+	assert.True(t, insertRow)
+	assert.False(t, found)
+
+	requests := newInsertRowRequests(row, sheetID)
+	assert.Len(t, requests, 2)
+
+	insert := requests[0].InsertDimension
+	assert.Equal(t, "ROWS", insert.Range.Dimension)
+	assert.Equal(t, []int64{3, 4}, []int64{insert.Range.StartIndex, insert.Range.EndIndex})
+
+	reset := requests[1].RepeatCell
+	assert.Equal(t, []int64{3, 4}, []int64{reset.Range.StartRowIndex, reset.Range.EndRowIndex})
+	assert.Nil(t, reset.Cell.UserEnteredFormat, "the retired row's colors should be cleared from the new row")
+	assert.Contains(t, reset.Fields, "userEnteredFormat.backgroundColor")
+	assert.Contains(t, reset.Fields, "userEnteredFormat.textFormat.foregroundColor")
+}
+
+func TestGetMonthUptimes(t *testing.T) {
+	checks := []nodeping.Check{
+		{ID: "id1", Label: "Example1"},
+		{ID: "id2", Label: "Example2"},
+	}
+
+	singleMonth := nodeping.GetLastMonthPeriod(time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC))
+	results := nodeping.UptimeResults{
+		Checks:    checks[:1],
+		Uptimes:   map[string]float64{"id1": 99.5},
+		StartTime: singleMonth.From.Unix(),
+		EndTime:   singleMonth.To.Unix(),
+	}
+
+	got := GetMonthUptimes(results)
+	want := []MonthUptimes{{Month: "February", Year: "2024", Uptimes: []CheckUptime{
+		{CheckID: "id1", Label: "Example1", Uptime: 99.5},
+	}}}
+	assert.Equal(t, want, got)
+
+	wholeYear := nodeping.GetLastYearPeriod(time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC))
+	results = nodeping.UptimeResults{
+		Checks:  checks,
+		Uptimes: map[string]float64{"id1": 99.5, "id2": 100},
+		MonthlyResponses: map[string]map[string]nodeping.UptimeResponse{
+			"id1": {
+				"2023-01": {Enabled: 1000, Down: 10},
+				"2023-02": {Enabled: 1000, Down: 0},
+				"2023-03": {Enabled: 0, Down: 0},
+			},
+			"id2": {
+				"2023-02": {Enabled: 2000, Down: 1},
+			},
+		},
+		StartTime: wholeYear.From.Unix(),
+		EndTime:   wholeYear.To.Unix(),
+	}
+
+	got = GetMonthUptimes(results)
+	want = []MonthUptimes{
+		{Month: "January", Year: "2023", Uptimes: []CheckUptime{
+			{CheckID: "id1", Label: "Example1", Uptime: 99, Response: nodeping.UptimeResponse{Enabled: 1000, Down: 10}},
+		}},
+		{Month: "February", Year: "2023", Uptimes: []CheckUptime{
+			{CheckID: "id1", Label: "Example1", Uptime: 100, Response: nodeping.UptimeResponse{Enabled: 1000}},
+			{CheckID: "id2", Label: "Example2", Uptime: 99.95, Response: nodeping.UptimeResponse{Enabled: 2000, Down: 1}},
+		}},
+	}
+	assert.Equal(t, want, got)
+
+	fetchErr := errors.New("unexpected status code 503")
+	results.Errors = map[string]error{"id1": fetchErr}
+	delete(results.MonthlyResponses, "id1")
+	got = GetMonthUptimes(results)
+	want = []MonthUptimes{
+		{Month: "February", Year: "2023", Uptimes: []CheckUptime{
+			{CheckID: "id1", Label: "Example1", Error: fetchErr},
+			{CheckID: "id2", Label: "Example2", Uptime: 99.95, Response: nodeping.UptimeResponse{Enabled: 2000, Down: 1}},
+		}},
+	}
+	assert.Equal(t, want, got, "a check that failed should be in each month, with its error")
+
+	results = nodeping.UptimeResults{
+		Checks:    checks[:1],
+		Errors:    map[string]error{"id1": fetchErr},
+		StartTime: singleMonth.From.Unix(),
+		EndTime:   singleMonth.To.Unix(),
+	}
+	got = GetMonthUptimes(results)
+	assert.Equal(t, []CheckUptime{{CheckID: "id1", Label: "Example1", Error: fetchErr}}, got[0].Uptimes,
+		"a check that failed should have its error, not an uptime of 0")
+}
+
+func Test_fetchError(t *testing.T) {
+	err := fetchError(map[string]error{"b": errors.New("second"), "a": errors.New("first")})
+	assert.EqualError(t, err, "unable to get the uptimes of 2 checks from NodePing: first\nsecond")
+}
+
+func TestArchiveResultsForMonth_negativePrecision(t *testing.T) {
+	precision := -1
+	_, err := ArchiveResultsForMonth("group", "2024-03", "", "token", ArchiveOptions{Precision: &precision})
+	assert.EqualError(t, err, "the precision can't be negative: -1")
+}
@@ -10,46 +10,50 @@ import (
 	"google.golang.org/api/sheets/v4"
 )
 
+// GetMonthPosition returns the number of the month (1 for January) that a month heading starts with,
+// e.g. "March 2024", in any of the supported locales (see MonthLocales)
 func GetMonthPosition(monthLabel string) (int, error) {
 	monthLabel = strings.Trim(monthLabel, " ")
 	monthParts := strings.Split(monthLabel, " ")
-	month := strings.ToLower(monthParts[0])
-
-	indexes := map[string]int{
-		"january":   1,
-		"february":  2,
-		"march":     3,
-		"april":     4,
-		"may":       5,
-		"june":      6,
-		"july":      7,
-		"august":    8,
-		"september": 9,
-		"october":   10,
-		"november":  11,
-		"december":  12,
-	}
-
-	index, ok := indexes[month]
+	month := strings.TrimSuffix(strings.ToLower(monthParts[0]), ".")
+
+	index, ok := monthIndexes[month]
 	if !ok {
 		return 0, fmt.Errorf("month %s not valid", monthLabel)
 	}
 
-	return index, nil
+	return index + 1, nil
 }
 
+// ConvertColumnIndexToLetter converts a 0-based column index to its A1 notation letters,
+// e.g. 0 => "A", 25 => "Z", 26 => "AA", 701 => "ZZ", 702 => "AAA"
 func ConvertColumnIndexToLetter(index int64) (string, error) {
-	if index > 25 {
-		return "", fmt.Errorf("not allowed to convert index if over 25. It was %d", index)
+	if index < 0 {
+		return "", fmt.Errorf("not allowed to convert a negative column index. It was %d", index)
 	}
 
 	runeA := int64([]rune("A")[0])
 
-	return fmt.Sprintf("%c", index+runeA), nil
+	letters := ""
+	for n := index + 1; n > 0; n = (n - 1) / 26 {
+		letters = fmt.Sprintf("%c", (n-1)%26+runeA) + letters
+	}
+
+	return letters, nil
+}
+
+// SheetRange returns an A1 notation range on the named sheet, quoting the sheet name so that
+// names with spaces work. If cells is empty, the range covers the whole sheet.
+func SheetRange(sheetName, cells string) string {
+	quoted := "'" + strings.ReplaceAll(sheetName, "'", "''") + "'"
+	if cells == "" {
+		return quoted
+	}
+	return quoted + "!" + cells
 }
 
 func WriteToCellWithColumnLetter(rowIndex int64, columnLetter, newValue, sheetName, spreadsheetID string, srv *sheets.Service) error {
-	cellRange := fmt.Sprintf("%s!%s%d", sheetName, columnLetter, rowIndex)
+	cellRange := SheetRange(sheetName, fmt.Sprintf("%s%d", columnLetter, rowIndex))
 	valueRange := &sheets.ValueRange{}
 
 	updateValue := []any{newValue}
@@ -129,18 +133,68 @@ func AddColumn(sheetID int64, spreadsheetID string, srv *sheets.Service) error {
 }
 
 func GetSheetIDFromTitle(title string, sheetsData SheetsData) (bool, int64, error) {
-	ssResp, err := sheetsData.Service.Spreadsheets.Get(sheetsData.SpreadsheetID).Do()
+	properties, err := GetSheetProperties(title, sheetsData)
+	if err != nil || properties == nil {
+		return false, 0, err
+	}
+
+	return true, properties.SheetId, nil
+}
+
+// GetSheetProperties returns the properties (including the grid size) of the sheet with the given title,
+// or nil if there is no such sheet
+func GetSheetProperties(title string, sheetsData SheetsData) (*sheets.SheetProperties, error) {
+	ssResp, err := sheetsData.Service.Spreadsheets.Get(sheetsData.SpreadsheetID).Fields("sheets.properties").Do()
 	if err != nil {
-		return false, 0, fmt.Errorf("error trying to find sheet %s: %w", title, err)
+		return nil, fmt.Errorf("error trying to find sheet %s: %w", title, err)
 	}
 
 	for _, next := range ssResp.Sheets {
 		if next.Properties.Title == title {
-			return true, next.Properties.SheetId, nil
+			return next.Properties, nil
 		}
 	}
 
-	return false, 0, nil
+	return nil, nil
+}
+
+// GetGridProperties returns the properties of the sheet with the given title, which must exist
+// and have a grid (i.e. not be a chart sheet)
+func GetGridProperties(title string, sheetsData SheetsData) (*sheets.SheetProperties, error) {
+	properties, err := GetSheetProperties(title, sheetsData)
+	if err != nil {
+		return nil, err
+	}
+
+	if properties == nil || properties.GridProperties == nil {
+		return nil, fmt.Errorf("unable to find the grid of sheet %s", title)
+	}
+
+	return properties, nil
+}
+
+// EnsureRowCount appends rows to the sheet if its grid has fewer than rowCount rows
+func EnsureRowCount(rowCount int64, properties *sheets.SheetProperties, spreadsheetID string, srv *sheets.Service) error {
+	if properties.GridProperties == nil || properties.GridProperties.RowCount >= rowCount {
+		return nil
+	}
+
+	request := sheets.Request{
+		AppendDimension: &sheets.AppendDimensionRequest{
+			Dimension: "ROWS",
+			Length:    rowCount - properties.GridProperties.RowCount,
+			SheetId:   properties.SheetId,
+		},
+	}
+
+	rbb := &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{&request},
+	}
+	_, err := srv.Spreadsheets.BatchUpdate(spreadsheetID, rbb).Context(context.Background()).Do()
+	if err != nil {
+		return fmt.Errorf("unable to add rows to sheet '%d': %w", properties.SheetId, err)
+	}
+	return nil
 }
 
 func GetRequiredEnvVar(envName string) string {
@@ -153,3 +207,27 @@ func GetRequiredEnvVar(envName string) string {
 
 	return envVar
 }
+
+// EnsureColumnCount appends columns to the sheet if its grid has fewer than columnCount columns
+func EnsureColumnCount(columnCount int64, properties *sheets.SheetProperties, spreadsheetID string, srv *sheets.Service) error {
+	if properties.GridProperties == nil || properties.GridProperties.ColumnCount >= columnCount {
+		return nil
+	}
+
+	request := sheets.Request{
+		AppendDimension: &sheets.AppendDimensionRequest{
+			Dimension: "COLUMNS",
+			Length:    columnCount - properties.GridProperties.ColumnCount,
+			SheetId:   properties.SheetId,
+		},
+	}
+
+	rbb := &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{&request},
+	}
+	_, err := srv.Spreadsheets.BatchUpdate(spreadsheetID, rbb).Context(context.Background()).Do()
+	if err != nil {
+		return fmt.Errorf("unable to add columns to sheet '%d': %w", properties.SheetId, err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package googlesheets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertColumnIndexToLetter(t *testing.T) {
+	tests := map[int64]string{
+		0:     "A",
+		1:     "B",
+		25:    "Z",
+		26:    "AA",
+		27:    "AB",
+		51:    "AZ",
+		52:    "BA",
+		701:   "ZZ",
+		702:   "AAA",
+		18277: "ZZZ",
+	}
+	for index, want := range tests {
+		got, err := ConvertColumnIndexToLetter(index)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got, "wrong letters for index %d", index)
+	}
+
+	_, err := ConvertColumnIndexToLetter(-1)
+	assert.Error(t, err)
+}
+
+func TestSheetRange(t *testing.T) {
+	assert.Equal(t, "'2024'!B2:Z2", SheetRange("2024", "B2:Z2"))
+	assert.Equal(t, "'2024 daily'", SheetRange("2024 daily", ""))
+	assert.Equal(t, "'Team''s 2024'!A1", SheetRange("Team's 2024", "A1"))
+}
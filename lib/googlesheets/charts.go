@@ -0,0 +1,253 @@
+package googlesheets
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/sheets/v4"
+)
+
+const (
+	ChartsSheetSuffix  = " charts"
+	DefaultWorstChecks = 10
+
+	// The charts are found by their titles when they are refreshed
+	UptimeChartTitle = "Monthly uptime per check"
+	WorstChartTitle  = "Worst checks in the latest month"
+)
+
+// ChartsSheetName returns the name of the tab that holds the charts of the year tab
+func ChartsSheetName(yearSheetName string) string {
+	return yearSheetName + ChartsSheetSuffix
+}
+
+// ArchiveCharts creates or refreshes the charts of the year tab, which are on the "<year> charts" tab:
+// a line chart of each check's monthly uptime, and a bar chart of the worst checks in the latest month.
+// A chart that already exists keeps its place and size, and just gets a new spec.
+// The worst checks are picked by a formula in the first columns of the charts tab.
+func ArchiveCharts(year string, worstCount int, sheetsData SheetsData) error {
+	if worstCount < 1 {
+		worstCount = DefaultWorstChecks
+	}
+
+	sheetName := sheetsData.GetSheetName(year)
+	srv := sheetsData.Service
+	spreadsheetID := sheetsData.SpreadsheetID
+
+	properties, err := GetGridProperties(sheetName, sheetsData)
+	if err != nil {
+		return err
+	}
+
+	headers, err := getMonthHeaders(sheetName, properties, sheetsData)
+	if err != nil {
+		return err
+	}
+
+	lastMonthColumn, _ := findSummaryColumns(headers, sheetsData.MonthFormat)
+	if lastMonthColumn < 0 {
+		return nil
+	}
+
+	rows, err := getCheckNames(sheetName, properties, sheetsData)
+	if err != nil {
+		return err
+	}
+
+	checkCount := findChecksEnd(rows)
+	if checkCount == 0 {
+		return nil
+	}
+
+	chartsSheetName := ChartsSheetName(sheetName)
+	chartsSheetID, err := ensureChartsSheetExists(chartsSheetName, sheetsData)
+	if err != nil {
+		return err
+	}
+
+	latestMonth := fmt.Sprintf("%v", headers[lastMonthColumn-1])
+	valueRange := &sheets.ValueRange{Values: [][]any{
+		{"Check", latestMonth},
+		{worstChecksFormula(sheetName, lastMonthColumn, checkCount, worstCount)},
+	}}
+	_, err = srv.Spreadsheets.Values.Update(spreadsheetID, SheetRange(chartsSheetName, "A1"), valueRange).ValueInputOption("USER_ENTERED").Do()
+	if err != nil {
+		return fmt.Errorf("unable to write the worst checks to %s: %w", chartsSheetName, err)
+	}
+
+	existing, err := getChartIDs(chartsSheetID, sheetsData)
+	if err != nil {
+		return err
+	}
+
+	charts := []*sheets.EmbeddedChart{
+		{
+			Spec:     uptimeChartSpec(sheetsData.SheetID, lastMonthColumn, checkCount),
+			Position: chartPosition(chartsSheetID, 0),
+		},
+		{
+			Spec:     worstChecksChartSpec(chartsSheetID, worstCount, latestMonth),
+			Position: chartPosition(chartsSheetID, 22),
+		},
+	}
+
+	rbb := &sheets.BatchUpdateSpreadsheetRequest{Requests: chartRequests(charts, existing)}
+	if _, err := srv.Spreadsheets.BatchUpdate(spreadsheetID, rbb).Context(context.Background()).Do(); err != nil {
+		return fmt.Errorf("unable to update the charts in %s: %w", chartsSheetName, err)
+	}
+
+	return nil
+}
+
+// ensureChartsSheetExists creates the charts tab if it doesn't already exist, and returns its sheet ID
+func ensureChartsSheetExists(sheetName string, sheetsData SheetsData) (int64, error) {
+	properties, err := GetSheetProperties(sheetName, sheetsData)
+	if err != nil {
+		return 0, err
+	}
+	if properties != nil {
+		return properties.SheetId, nil
+	}
+
+	addSheet := &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{{
+			AddSheet: &sheets.AddSheetRequest{
+				Properties: &sheets.SheetProperties{Title: sheetName},
+			},
+		}},
+	}
+
+	resp, err := sheetsData.Service.Spreadsheets.BatchUpdate(sheetsData.SpreadsheetID, addSheet).Context(context.Background()).Do()
+	if err != nil {
+		return 0, fmt.Errorf("unable to create new sheet %s. %s", sheetName, err)
+	}
+
+	return resp.Replies[0].AddSheet.Properties.SheetId, nil
+}
+
+// getChartIDs returns the IDs of the charts on the sheet, keyed by their titles
+func getChartIDs(sheetID int64, sheetsData SheetsData) (map[string]int64, error) {
+	resp, err := sheetsData.Service.Spreadsheets.Get(sheetsData.SpreadsheetID).
+		Fields("sheets(properties.sheetId,charts(chartId,spec.title))").Do()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the charts of sheet '%d': %w", sheetID, err)
+	}
+
+	ids := map[string]int64{}
+	for _, sheet := range resp.Sheets {
+		if sheet.Properties == nil || sheet.Properties.SheetId != sheetID {
+			continue
+		}
+		for _, chart := range sheet.Charts {
+			if chart.Spec != nil {
+				ids[chart.Spec.Title] = chart.ChartId
+			}
+		}
+	}
+
+	return ids, nil
+}
+
+// chartRequests returns the requests that add each chart, or update the spec of the existing chart
+// with the same title
+func chartRequests(charts []*sheets.EmbeddedChart, existing map[string]int64) []*sheets.Request {
+	var requests []*sheets.Request
+	for _, chart := range charts {
+		if chartID, ok := existing[chart.Spec.Title]; ok {
+			requests = append(requests, &sheets.Request{
+				UpdateChartSpec: &sheets.UpdateChartSpecRequest{ChartId: chartID, Spec: chart.Spec},
+			})
+			continue
+		}
+
+		requests = append(requests, &sheets.Request{AddChart: &sheets.AddChartRequest{Chart: chart}})
+	}
+
+	return requests
+}
+
+// chartPosition places a chart to the right of the worst checks, starting at the (0-based) row
+func chartPosition(sheetID, row int64) *sheets.EmbeddedObjectPosition {
+	return &sheets.EmbeddedObjectPosition{
+		OverlayPosition: &sheets.OverlayPosition{
+			AnchorCell:   &sheets.GridCoordinate{SheetId: sheetID, RowIndex: row, ColumnIndex: 3},
+			WidthPixels:  900,
+			HeightPixels: 420,
+		},
+	}
+}
+
+// worstChecksFormula returns a formula that lists the checks with the lowest uptime in the (0-based)
+// month column of the year tab, and their uptimes
+func worstChecksFormula(sheetName string, monthColumn, checkCount, worstCount int) string {
+	letter, _ := ConvertColumnIndexToLetter(int64(monthColumn))
+	lastRow := FirstCheckRow + checkCount - 1
+	names := SheetRange(sheetName, fmt.Sprintf("A%d:A%d", FirstCheckRow, lastRow))
+	uptimes := SheetRange(sheetName, fmt.Sprintf("%s%d:%s%d", letter, FirstCheckRow, letter, lastRow))
+
+	return fmt.Sprintf("=IFERROR(SORTN(FILTER({%s, %s}, ISNUMBER(%s)), %d, 0, 2, TRUE), \"\")",
+		names, uptimes, uptimes, worstCount)
+}
+
+// uptimeChartSpec returns a line chart with a line for each of the checks in the year tab, across
+// its month columns up to the (0-based) last one
+func uptimeChartSpec(sheetID int64, lastMonthColumn, checkCount int) *sheets.ChartSpec {
+	rowRange := func(row int) *sheets.ChartData {
+		return &sheets.ChartData{SourceRange: &sheets.ChartSourceRange{Sources: []*sheets.GridRange{{
+			SheetId:          sheetID,
+			StartRowIndex:    int64(row - 1),
+			EndRowIndex:      int64(row),
+			StartColumnIndex: 0,
+			EndColumnIndex:   int64(lastMonthColumn + 1),
+		}}}}
+	}
+
+	var series []*sheets.BasicChartSeries
+	for i := range checkCount {
+		series = append(series, &sheets.BasicChartSeries{Series: rowRange(FirstCheckRow + i), TargetAxis: "LEFT_AXIS"})
+	}
+
+	return &sheets.ChartSpec{
+		Title: UptimeChartTitle,
+		BasicChart: &sheets.BasicChartSpec{
+			ChartType:      "LINE",
+			LegendPosition: "RIGHT_LEGEND",
+			HeaderCount:    1,
+			Axis: []*sheets.BasicChartAxis{
+				{Position: "BOTTOM_AXIS", Title: "Month"},
+				{Position: "LEFT_AXIS", Title: "Uptime %"},
+			},
+			Domains: []*sheets.BasicChartDomain{{Domain: rowRange(MonthHeaderRow)}},
+			Series:  series,
+		},
+	}
+}
+
+// worstChecksChartSpec returns a bar chart of the worst checks, as listed by worstChecksFormula
+// on the charts tab
+func worstChecksChartSpec(chartsSheetID int64, worstCount int, latestMonth string) *sheets.ChartSpec {
+	columnRange := func(column int64) *sheets.ChartData {
+		return &sheets.ChartData{SourceRange: &sheets.ChartSourceRange{Sources: []*sheets.GridRange{{
+			SheetId:          chartsSheetID,
+			StartRowIndex:    0,
+			EndRowIndex:      int64(worstCount + 1),
+			StartColumnIndex: column,
+			EndColumnIndex:   column + 1,
+		}}}}
+	}
+
+	return &sheets.ChartSpec{
+		Title:    WorstChartTitle,
+		Subtitle: fmt.Sprintf("The %d checks with the lowest uptime in %s", worstCount, latestMonth),
+		BasicChart: &sheets.BasicChartSpec{
+			ChartType:      "BAR",
+			LegendPosition: "NO_LEGEND",
+			HeaderCount:    1,
+			Axis: []*sheets.BasicChartAxis{
+				{Position: "BOTTOM_AXIS", Title: "Uptime %"},
+			},
+			Domains: []*sheets.BasicChartDomain{{Domain: columnRange(0)}},
+			Series:  []*sheets.BasicChartSeries{{Series: columnRange(1), TargetAxis: "BOTTOM_AXIS"}},
+		},
+	}
+}
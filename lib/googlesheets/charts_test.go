@@ -0,0 +1,38 @@
+package googlesheets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/sheets/v4"
+)
+
+func Test_worstChecksFormula(t *testing.T) {
+	got := worstChecksFormula("2024", 3, 5, 10)
+	want := `=IFERROR(SORTN(FILTER({'2024'!A3:A7, '2024'!D3:D7}, ISNUMBER('2024'!D3:D7)), 10, 0, 2, TRUE), "")`
+	assert.Equal(t, want, got)
+}
+
+func Test_uptimeChartSpec(t *testing.T) {
+	spec := uptimeChartSpec(7, 3, 2)
+	assert.Equal(t, UptimeChartTitle, spec.Title)
+
+	domain := spec.BasicChart.Domains[0].Domain.SourceRange.Sources[0]
+	assert.Equal(t, &sheets.GridRange{SheetId: 7, StartRowIndex: 1, EndRowIndex: 2, EndColumnIndex: 4}, domain)
+
+	assert.Len(t, spec.BasicChart.Series, 2)
+	series := spec.BasicChart.Series[1].Series.SourceRange.Sources[0]
+	assert.Equal(t, &sheets.GridRange{SheetId: 7, StartRowIndex: 3, EndRowIndex: 4, EndColumnIndex: 4}, series)
+}
+
+func Test_chartRequests(t *testing.T) {
+	charts := []*sheets.EmbeddedChart{
+		{Spec: &sheets.ChartSpec{Title: UptimeChartTitle}},
+		{Spec: &sheets.ChartSpec{Title: WorstChartTitle}},
+	}
+
+	requests := chartRequests(charts, map[string]int64{WorstChartTitle: 42})
+	assert.Len(t, requests, 2)
+	assert.Equal(t, charts[0], requests[0].AddChart.Chart)
+	assert.Equal(t, int64(42), requests[1].UpdateChartSpec.ChartId, "an existing chart should be updated, not duplicated")
+}
@@ -0,0 +1,198 @@
+package googlesheets
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
+)
+
+const checkpointDateLayout = "20060102"
+
+// Checkpoint records how far a run has got, so that a run that dies part way through (e.g. when the
+// Lambda times out, or the Sheets quota runs out) can be resumed by the next one without writing the
+// same checks again or working out the month columns again
+type Checkpoint struct {
+	SpreadsheetID string             `json:"spreadsheetId"`
+	ContactGroup  string             `json:"contactGroup"`
+	Period        string             `json:"period"`
+	From          time.Time          `json:"from"`
+	To            time.Time          `json:"to"`
+	UpdatedAt     time.Time          `json:"updatedAt"`
+	Months        []*MonthCheckpoint `json:"months"`
+}
+
+// MonthCheckpoint records the writing of one month's uptimes
+type MonthCheckpoint struct {
+	Sheet  string   `json:"sheet"`
+	Month  string   `json:"month"`            // e.g. "March 2024"
+	Column int      `json:"column,omitempty"` // The month's (0-based) column in the wide layout
+	Row    int      `json:"row,omitempty"`    // The month's row in the transposed layout
+	Done   []string `json:"done"`             // The IDs (or names, if they have none) of the checks written
+}
+
+// checkpointer saves the checkpoint of a run to a store after each check is written. A nil
+// checkpointer does nothing, so that a run without a store needn't check for one.
+type checkpointer struct {
+	store      Store
+	name       string
+	checkpoint Checkpoint
+}
+
+// newCheckpointer returns the checkpointer for a run of the contact group's uptimes for the period
+// to the spreadsheet. If resume is set, it starts from the checkpoint of an earlier run of the same
+// period, if there is one. Otherwise, any such checkpoint is replaced.
+func newCheckpointer(store Store, resume bool, spreadsheetID, group string, period nodeping.Period) (*checkpointer, error) {
+	c := &checkpointer{
+		store: store,
+		name:  checkpointName(spreadsheetID, group, period),
+		checkpoint: Checkpoint{
+			SpreadsheetID: spreadsheetID,
+			ContactGroup:  group,
+			Period:        period.String(),
+			From:          period.From,
+			To:            period.To,
+		},
+	}
+	if !resume {
+		return c, nil
+	}
+
+	names, err := store.List(c.name)
+	if err != nil || !slices.Contains(names, c.name) {
+		return c, err
+	}
+
+	data, err := store.Load(c.name)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.checkpoint); err != nil {
+		return nil, fmt.Errorf("unable to decode checkpoint %s: %w", c.name, err)
+	}
+
+	slog.Info("resuming from checkpoint", "checkpoint", c.name, "updatedAt", c.checkpoint.UpdatedAt)
+	return c, nil
+}
+
+// checkpointName returns the name of the checkpoint in the store. It includes the dates of the period,
+// rather than its name, so that e.g. a "LastMonth" run doesn't resume the run of the month before.
+func checkpointName(spreadsheetID, group string, period nodeping.Period) string {
+	return "checkpoint." + snapshotPrefix(spreadsheetID, group) +
+		period.From.Format(checkpointDateLayout) + "-" + period.To.Format(checkpointDateLayout) + ".json"
+}
+
+// month returns the checkpoint of the month on the tab, adding it if there isn't one yet
+func (c *checkpointer) month(sheet, month string) *MonthCheckpoint {
+	if c == nil {
+		return nil
+	}
+
+	for _, m := range c.checkpoint.Months {
+		if m.Sheet == sheet && m.Month == month {
+			return m
+		}
+	}
+	m := &MonthCheckpoint{Sheet: sheet, Month: month}
+	c.checkpoint.Months = append(c.checkpoint.Months, m)
+	return m
+}
+
+// isDone reports whether the check has already been written for the month
+func (m *MonthCheckpoint) isDone(check string) bool {
+	return m != nil && slices.Contains(m.Done, check)
+}
+
+// save records the month's column or row, and the checks written so far
+func (c *checkpointer) save() error {
+	if c == nil {
+		return nil
+	}
+
+	c.checkpoint.UpdatedAt = time.Now().UTC()
+	data, err := json.Marshal(c.checkpoint)
+	if err != nil {
+		return fmt.Errorf("unable to encode checkpoint %s: %w", c.name, err)
+	}
+	if err := c.store.Save(c.name, data); err != nil {
+		return fmt.Errorf("unable to save checkpoint %s: %w", c.name, err)
+	}
+	return nil
+}
+
+// done records that the check has been written for the month, and saves the checkpoint
+func (c *checkpointer) done(m *MonthCheckpoint, check string) error {
+	if c == nil {
+		return nil
+	}
+	m.Done = append(m.Done, check)
+	return c.save()
+}
+
+// finish deletes the checkpoint, once the run has written everything
+func (c *checkpointer) finish() error {
+	if c == nil {
+		return nil
+	}
+	return c.store.Delete(c.name)
+}
+
+// checkpointKey returns how a check is recorded in a checkpoint: by its ID, or its name if it has none
+func checkpointKey(uptime CheckUptime) string {
+	if uptime.CheckID != "" {
+		return uptime.CheckID
+	}
+	return uptime.Label
+}
+
+// resumedColumn returns the month's (0-based) column in the wide layout from the checkpoint, or 0 if
+// there isn't one, or the column no longer has the month's heading
+func (m *MonthCheckpoint) resumedColumn(sheetsData SheetsData) (int, error) {
+	if m == nil || m.Column < 1 {
+		return 0, nil
+	}
+	if ok, err := m.hasHeading(MonthHeaderRow, m.Column, sheetsData); !ok {
+		return 0, err
+	}
+	return m.Column, nil
+}
+
+// resumedRow returns the month's row in the transposed layout from the checkpoint, or 0 if there
+// isn't one, or the row no longer has the month's heading
+func (m *MonthCheckpoint) resumedRow(sheetsData SheetsData) (int, error) {
+	if m == nil || m.Row < 1 {
+		return 0, nil
+	}
+	if ok, err := m.hasHeading(m.Row, 0, sheetsData); !ok {
+		return 0, err
+	}
+	return m.Row, nil
+}
+
+// hasHeading reports whether the cell at the row (1-based) and column (0-based) still has the month's
+// heading, so that the column or row from the checkpoint can be used again
+func (m *MonthCheckpoint) hasHeading(row, column int, sheetsData SheetsData) (bool, error) {
+	letter, err := ConvertColumnIndexToLetter(int64(column))
+	if err != nil {
+		return false, err
+	}
+
+	cell := SheetRange(m.Sheet, fmt.Sprintf("%s%d", letter, row))
+	resp, err := sheetsData.Service.Spreadsheets.Values.Get(sheetsData.SpreadsheetID, cell).Do()
+	if err != nil {
+		return false, fmt.Errorf("unable to read %s: %w", cell, err)
+	}
+
+	var heading time.Time
+	if len(resp.Values) > 0 && len(resp.Values[0]) > 0 {
+		heading, _ = sheetsData.MonthFormat.Parse(fmt.Sprintf("%v", resp.Values[0][0]))
+	}
+	if heading.Format(DefaultMonthLayout) != m.Month {
+		slog.Warn("not reusing the month's place from the checkpoint, since its heading has moved", "cell", cell, "month", m.Month)
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,62 @@
+package googlesheets
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
+)
+
+func Test_checkpointName(t *testing.T) {
+	period := nodeping.Period{
+		From: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2024, 3, 31, 23, 59, 59, 0, time.UTC),
+	}
+	assert.Equal(t, "checkpoint.abc_2F1.Team_20Alerts.20240301-20240331.json", checkpointName("abc/1", "Team Alerts", period))
+}
+
+func Test_checkpointer(t *testing.T) {
+	store := LocalStore{Dir: t.TempDir()}
+	period := nodeping.Period{
+		From: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2024, 3, 31, 23, 59, 59, 0, time.UTC),
+	}
+
+	c, err := newCheckpointer(store, true, "abc", "Ops", period)
+	assert.NoError(t, err)
+	march := c.month("2024", "March 2024")
+	assert.Same(t, march, c.month("2024", "March 2024"), "a month should only be added once")
+	march.Column = 3
+	assert.NoError(t, c.done(march, "id1"))
+	assert.NoError(t, c.done(march, "API"))
+
+	resumed, err := newCheckpointer(store, true, "abc", "Ops", period)
+	assert.NoError(t, err)
+	march = resumed.month("2024", "March 2024")
+	assert.Equal(t, 3, march.Column)
+	assert.True(t, march.isDone("id1"))
+	assert.True(t, march.isDone("API"))
+	assert.False(t, march.isDone("id2"))
+
+	fresh, err := newCheckpointer(store, false, "abc", "Ops", period)
+	assert.NoError(t, err)
+	assert.False(t, fresh.month("2024", "March 2024").isDone("id1"), "a run that doesn't resume should start again")
+
+	assert.NoError(t, resumed.finish())
+	names, err := store.List("checkpoint.")
+	assert.NoError(t, err)
+	assert.Empty(t, names, "a finished run should delete its checkpoint")
+
+	var none *checkpointer
+	assert.Nil(t, none.month("2024", "March 2024"))
+	assert.False(t, none.month("2024", "March 2024").isDone("id1"))
+	assert.NoError(t, none.done(nil, "id1"))
+	assert.NoError(t, none.finish())
+}
+
+func Test_checkpointKey(t *testing.T) {
+	assert.Equal(t, "id1", checkpointKey(CheckUptime{CheckID: "id1", Label: "Website"}))
+	assert.Equal(t, "Website", checkpointKey(CheckUptime{Label: "Website"}))
+}
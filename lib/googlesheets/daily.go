@@ -0,0 +1,350 @@
+package googlesheets
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/sheets/v4"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
+)
+
+const (
+	DailySheetSuffix = " daily"
+	DailyDateLayout  = "2006-01-02"
+
+	// The heatmap's color scale runs from red at HeatmapMinUptime, through amber at HeatmapMidUptime,
+	// to green at 100 percent. Values below HeatmapMinUptime are shown in the same red.
+	HeatmapMinUptime = 95.0
+	HeatmapMidUptime = 99.0
+
+	initialDailyRowCount = 100
+)
+
+var (
+	heatmapRed   = &sheets.Color{Red: 0.9, Green: 0.49, Blue: 0.45}
+	heatmapAmber = &sheets.Color{Red: 1, Green: 0.84, Blue: 0.4}
+	heatmapGreen = &sheets.Color{Red: 0.34, Green: 0.73, Blue: 0.54}
+)
+
+// DailySheetName returns the name of the tab that holds the daily uptimes for the year tab
+func DailySheetName(yearSheetName string) string {
+	return yearSheetName + DailySheetSuffix
+}
+
+// EnsureDailySheetExists creates the daily tab for the year if it doesn't already exist.
+// A new tab gets a header row with every day of the year (starting at B1), frozen header
+// row and column, and a color scale rule so that the uptime values read as a heatmap.
+func EnsureDailySheetExists(year int, sheetsData SheetsData) (*sheets.SheetProperties, error) {
+	sheetName := DailySheetName(sheetsData.GetSheetName(strconv.Itoa(year)))
+
+	properties, err := GetSheetProperties(sheetName, sheetsData)
+	if err != nil || properties != nil {
+		return properties, err
+	}
+
+	srv := sheetsData.Service
+	spreadsheetID := sheetsData.SpreadsheetID
+	days := DaysOfYear(year)
+
+	addSheet := &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{{
+			AddSheet: &sheets.AddSheetRequest{
+				Properties: &sheets.SheetProperties{
+					Title: sheetName,
+					GridProperties: &sheets.GridProperties{
+						RowCount:          initialDailyRowCount,
+						ColumnCount:       int64(len(days) + 1),
+						FrozenRowCount:    1,
+						FrozenColumnCount: 1,
+					},
+				},
+			},
+		}},
+	}
+
+	resp, err := srv.Spreadsheets.BatchUpdate(spreadsheetID, addSheet).Context(context.Background()).Do()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create new sheet %s. %s", sheetName, err)
+	}
+	properties = resp.Replies[0].AddSheet.Properties
+
+	colorScale := &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{{
+			AddConditionalFormatRule: &sheets.AddConditionalFormatRuleRequest{
+				Rule: &sheets.ConditionalFormatRule{
+					Ranges: []*sheets.GridRange{{
+						SheetId:          properties.SheetId,
+						StartRowIndex:    1,
+						StartColumnIndex: 1,
+					}},
+					GradientRule: &sheets.GradientRule{
+						Minpoint: &sheets.InterpolationPoint{
+							Type:  "NUMBER",
+							Value: FormatUptime(HeatmapMinUptime, 1),
+							Color: heatmapRed,
+						},
+						Midpoint: &sheets.InterpolationPoint{
+							Type:  "NUMBER",
+							Value: FormatUptime(HeatmapMidUptime, 1),
+							Color: heatmapAmber,
+						},
+						Maxpoint: &sheets.InterpolationPoint{
+							Type:  "NUMBER",
+							Value: "100",
+							Color: heatmapGreen,
+						},
+					},
+				},
+			},
+		}},
+	}
+
+	if _, err := srv.Spreadsheets.BatchUpdate(spreadsheetID, colorScale).Context(context.Background()).Do(); err != nil {
+		return nil, fmt.Errorf("unable to add color scale to sheet %s: %w", sheetName, err)
+	}
+
+	header := []any{"Checks"}
+	for _, day := range days {
+		header = append(header, day)
+	}
+
+	headerRange := &sheets.ValueRange{Values: [][]any{header}}
+	_, err = srv.Spreadsheets.Values.Update(spreadsheetID, SheetRange(sheetName, "A1"), headerRange).ValueInputOption("RAW").Do()
+	if err != nil {
+		return nil, fmt.Errorf("unable to write header row to sheet %s: %w", sheetName, err)
+	}
+
+	return properties, nil
+}
+
+// ArchiveDailyResults writes the daily uptimes into the daily tab for each year of the results,
+// with one row per check (sorted alphabetically) and one column per day of the year. Each row has
+// the check's ID as developer metadata, like the rows of a year tab, so that checks that share a
+// name keep their own rows. Existing rows and days that are not part of the results are kept as they are.
+// A check whose uptimes couldn't be retrieved is left as it is, and the error says which ones they were.
+func ArchiveDailyResults(results nodeping.DailyUptimeResults, precision, countLimit int, sheetsData SheetsData) error {
+	checks := results.Checks
+	if countLimit > 0 && len(checks) > countLimit {
+		checks = checks[:countLimit]
+	}
+	checks = slices.DeleteFunc(slices.Clone(checks), func(check nodeping.Check) bool { return results.Errors[check.ID] != nil })
+
+	for _, year := range dailyYears(results) {
+		if err := archiveDailyYear(year, checks, results.Uptimes, precision, sheetsData); err != nil {
+			return err
+		}
+	}
+
+	if len(results.Errors) > 0 {
+		return fetchError(results.Errors)
+	}
+	return nil
+}
+
+// dailyYears returns the years that the daily results cover, in order: the year they start in and
+// the year of every day that has an uptime, so that a period that spans a new year fills both tabs
+func dailyYears(results nodeping.DailyUptimeResults) []int {
+	// Add seconds per day to ensure time zone issues don't point to previous year
+	years := []int{time.Unix(results.StartTime+86400, 0).UTC().Year()}
+	for _, days := range results.Uptimes {
+		for day := range days {
+			if date, err := time.Parse(DailyDateLayout, day); err == nil && !slices.Contains(years, date.Year()) {
+				years = append(years, date.Year())
+			}
+		}
+	}
+	slices.Sort(years)
+	return years
+}
+
+// archiveDailyYear writes the uptimes of the checks on the days of the year into the year's daily tab
+func archiveDailyYear(year int, checks []nodeping.Check, uptimes map[string]map[string]float64, precision int, sheetsData SheetsData) error {
+	sheetName := DailySheetName(sheetsData.GetSheetName(strconv.Itoa(year)))
+
+	properties, err := EnsureDailySheetExists(year, sheetsData)
+	if err != nil {
+		return err
+	}
+
+	srv := sheetsData.Service
+	spreadsheetID := sheetsData.SpreadsheetID
+
+	resp, err := srv.Spreadsheets.Values.Get(spreadsheetID, SheetRange(sheetName, "")).ValueRenderOption("UNFORMATTED_VALUE").Do()
+	if err != nil {
+		return fmt.Errorf("error getting existing daily uptimes from %s: %w", sheetName, err)
+	}
+
+	var existing [][]any
+	if len(resp.Values) > 1 {
+		existing = resp.Values[1:]
+	}
+
+	dailyData := sheetsData
+	dailyData.SheetID = properties.SheetId
+	idMetadata, err := GetRowMetadata(CheckIDMetadataKey, dailyData)
+	if err != nil {
+		return err
+	}
+
+	// The check IDs of the existing rows, by their index in existing
+	existingIDs := map[int]string{}
+	for row, m := range idMetadata {
+		existingIDs[row-2] = m.MetadataValue
+	}
+
+	rows, rowIDs := mergeDailyUptimes(existing, existingIDs, checks, uptimes, year, precision)
+
+	if err := EnsureRowCount(int64(len(rows)+1), properties, spreadsheetID, srv); err != nil {
+		return err
+	}
+
+	valueRange := &sheets.ValueRange{Values: rows}
+	_, err = srv.Spreadsheets.Values.Update(spreadsheetID, SheetRange(sheetName, "A2"), valueRange).ValueInputOption("RAW").Do()
+	if err != nil {
+		return fmt.Errorf("unable to write daily uptimes to %s: %w", sheetName, err)
+	}
+
+	// The rows have been rewritten in a new order, so their check IDs are attached again
+	var requests []*sheets.Request
+	for _, m := range idMetadata {
+		requests = append(requests, newDeleteMetadataRequest(m.MetadataId))
+	}
+	for i, id := range rowIDs {
+		if id != "" {
+			requests = append(requests, newRowMetadataRequest(i+2, CheckIDMetadataKey, id, properties.SheetId))
+		}
+	}
+	if len(requests) > 0 {
+		rbb := &sheets.BatchUpdateSpreadsheetRequest{Requests: requests}
+		if _, err := srv.Spreadsheets.BatchUpdate(spreadsheetID, rbb).Context(context.Background()).Do(); err != nil {
+			return fmt.Errorf("unable to set the check IDs of the rows of %s: %w", sheetName, err)
+		}
+	}
+
+	return nil
+}
+
+// dailyRow is a row of a daily tab, with the ID of its check, if it is known
+type dailyRow struct {
+	checkID string
+	cells   []any
+}
+
+// mergeDailyUptimes combines the existing rows of a daily tab (without the header row), given the
+// check IDs of those rows by their index, with the checks' new daily uptimes, which are keyed by check
+// ID. It returns rows sorted by check name, each with the name in the first cell and one cell per day
+// of the year after that, and the check ID of each row. Rows with the same check ID are combined, and
+// so are rows without one that have the same name (ignoring case), as written by earlier versions.
+// A check without a row of its own takes a row without an ID that has its name.
+func mergeDailyUptimes(
+	existing [][]any,
+	existingIDs map[int]string,
+	checks []nodeping.Check,
+	uptimes map[string]map[string]float64,
+	year, precision int,
+) ([][]any, []string) {
+	rowLength := len(DaysOfYear(year)) + 1
+	var rows []*dailyRow
+	rowsByID := map[string]*dailyRow{}
+	rowsByName := map[string]*dailyRow{}
+
+	newRow := func(label, checkID string) *dailyRow {
+		row := &dailyRow{checkID: checkID, cells: make([]any, rowLength)}
+		row.cells[0] = label
+		for i := 1; i < rowLength; i++ {
+			row.cells[i] = ""
+		}
+		rows = append(rows, row)
+		return row
+	}
+
+	for i, cells := range existing {
+		if len(cells) == 0 {
+			continue
+		}
+		label := fmt.Sprintf("%v", cells[0])
+		if label == "" {
+			continue
+		}
+
+		id := existingIDs[i]
+		row := rowsByID[id]
+		if id == "" {
+			row = rowsByName[strings.ToLower(label)]
+		}
+		if row == nil {
+			row = newRow(label, id)
+			if id == "" {
+				rowsByName[strings.ToLower(label)] = row
+			} else {
+				rowsByID[id] = row
+			}
+		}
+
+		for day := 1; day < len(cells) && day < rowLength; day++ {
+			if cells[day] != "" && cells[day] != nil {
+				row.cells[day] = cells[day]
+			}
+		}
+	}
+
+	for _, check := range checks {
+		row := rowsByID[check.ID]
+		if row == nil {
+			key := strings.ToLower(check.Label)
+			row = rowsByName[key]
+			delete(rowsByName, key)
+		}
+		if row == nil {
+			row = newRow(check.Label, check.ID)
+		}
+		row.checkID = check.ID
+		row.cells[0] = check.Label
+		rowsByID[check.ID] = row
+
+		for day, uptime := range uptimes[check.ID] {
+			date, err := time.Parse(DailyDateLayout, day)
+			if err != nil || date.Year() != year {
+				continue
+			}
+			row.cells[date.YearDay()] = roundToPrecision(uptime, precision)
+		}
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := strings.ToLower(fmt.Sprintf("%v", rows[i].cells[0])), strings.ToLower(fmt.Sprintf("%v", rows[j].cells[0]))
+		if a != b {
+			return a < b
+		}
+		return rows[i].checkID < rows[j].checkID
+	})
+
+	cells := make([][]any, 0, len(rows))
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		cells = append(cells, row.cells)
+		ids = append(ids, row.checkID)
+	}
+	return cells, ids
+}
+
+// DaysOfYear returns every day of the year, formatted with DailyDateLayout
+func DaysOfYear(year int) []string {
+	var days []string
+	for day := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC); day.Year() == year; day = day.AddDate(0, 0, 1) {
+		days = append(days, day.Format(DailyDateLayout))
+	}
+	return days
+}
+
+func roundToPrecision(value float64, precision int) float64 {
+	factor := math.Pow(10, float64(precision))
+	return math.Round(value*factor) / factor
+}
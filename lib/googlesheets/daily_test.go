@@ -0,0 +1,70 @@
+package googlesheets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
+)
+
+func TestDaysOfYear(t *testing.T) {
+	days := DaysOfYear(2024)
+	assert.Len(t, days, 366)
+	assert.Equal(t, "2024-01-01", days[0])
+	assert.Equal(t, "2024-12-31", days[365])
+
+	assert.Len(t, DaysOfYear(2023), 365)
+}
+
+func Test_mergeDailyUptimes(t *testing.T) {
+	existing := [][]any{
+		{"beta", 99.5, 100.0},
+		{"Delta", "", 98.25},
+		{},
+		{""},
+		{"Gamma", 97.0},
+		{"gamma", "", 96.0},
+	}
+	existingIDs := map[int]string{4: "gamma-id"}
+	checks := []nodeping.Check{
+		{ID: "alpha-id", Label: "Alpha"},
+		{ID: "beta-id", Label: "Beta"},
+		{ID: "gamma-id", Label: "Gamma"},
+		{ID: "gamma2-id", Label: "Gamma"},
+	}
+	uptimes := map[string]map[string]float64{
+		"alpha-id":  {"2023-01-02": 99.12345},
+		"beta-id":   {"2023-01-03": 100, "2022-12-31": 50, "total": 1},
+		"gamma-id":  {"2023-01-03": 95},
+		"gamma2-id": {"2023-01-04": 94},
+	}
+
+	rows, ids := mergeDailyUptimes(existing, existingIDs, checks, uptimes, 2023, 3)
+
+	assert.Len(t, rows, 5)
+	for _, row := range rows {
+		assert.Len(t, row, 366)
+	}
+
+	assert.Equal(t, []any{"Alpha", "", 99.123, "", ""}, rows[0][:5])
+	assert.Equal(t, []any{"Beta", 99.5, 100.0, 100.0, ""}, rows[1][:5], "a row without an ID should be taken by the check with its name")
+	assert.Equal(t, []any{"Delta", "", 98.25, "", ""}, rows[2][:5])
+	assert.Equal(t, []any{"Gamma", 97.0, "", 95.0, ""}, rows[3][:5], "a row with an ID should be found by it")
+	assert.Equal(t, []any{"Gamma", "", 96.0, "", 94.0}, rows[4][:5],
+		"a check that shares its name with another should take the row without an ID, not the other check's row")
+	assert.Equal(t, []string{"alpha-id", "beta-id", "", "gamma-id", "gamma2-id"}, ids)
+}
+
+func Test_dailyYears(t *testing.T) {
+	results := nodeping.DailyUptimeResults{
+		StartTime: 1698796800, // 2023-11-01
+		Uptimes: map[string]map[string]float64{
+			"a": {"2023-11-01": 100, "2024-01-15": 99.5},
+			"b": {"2024-02-29": 98},
+		},
+	}
+	assert.Equal(t, []int{2023, 2024}, dailyYears(results))
+
+	assert.Equal(t, []int{2023}, dailyYears(nodeping.DailyUptimeResults{StartTime: 1698796800}))
+}
@@ -0,0 +1,208 @@
+package googlesheets
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	"google.golang.org/api/sheets/v4"
+)
+
+const (
+	DefaultSpreadsheetTitle = "NodePing uptime archive"
+
+	GranteeUser  = "user"
+	GranteeGroup = "group"
+
+	spreadsheetMimeType = "application/vnd.google-apps.spreadsheet"
+)
+
+// SpreadsheetConfig holds the settings for creating and sharing the spreadsheet
+type SpreadsheetConfig struct {
+	Create    bool      // Whether to create the spreadsheet if no ID is given, or there's no spreadsheet with the ID
+	Title     string    // The title of a new spreadsheet (defaults to DefaultSpreadsheetTitle)
+	FolderID  string    // The ID of the Drive folder for a new spreadsheet (defaults to the service account's own)
+	ShareWith []Grantee // The users and groups who can edit the spreadsheet
+}
+
+// DriveScopes returns the Drive scopes needed to create or share the spreadsheet, if the config asks
+// for either, and none otherwise. That is the full Drive scope, since drive.file only covers the files
+// that the app created itself: with it, a spreadsheet that someone else created and shared with the
+// service account couldn't be found (and a duplicate would be created) or shared. A service account
+// can still only reach the files that are shared with it.
+func (c SpreadsheetConfig) DriveScopes() []string {
+	if !c.Create && len(c.ShareWith) == 0 {
+		return nil
+	}
+	return []string{drive.DriveScope}
+}
+
+// Grantee is a user or group that the spreadsheet is shared with
+type Grantee struct {
+	Type  string // GranteeUser or GranteeGroup
+	Email string
+}
+
+// ParseGrantees parses a comma-separated list of email addresses, each of which can have a "user:"
+// or "group:" prefix, e.g. "someone@example.org, group:team@example.org". The default is "user".
+func ParseGrantees(value string) ([]Grantee, error) {
+	var grantees []Grantee
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		grantee := Grantee{Type: GranteeUser, Email: item}
+		if kind, email, found := strings.Cut(item, ":"); found {
+			grantee = Grantee{Type: strings.ToLower(kind), Email: email}
+		}
+
+		if grantee.Type != GranteeUser && grantee.Type != GranteeGroup {
+			return nil, fmt.Errorf(`%q must start with "%s:" or "%s:", if anything`, item, GranteeUser, GranteeGroup)
+		}
+		if !strings.Contains(grantee.Email, "@") {
+			return nil, fmt.Errorf("%q is not an email address", grantee.Email)
+		}
+
+		grantees = append(grantees, grantee)
+	}
+
+	return grantees, nil
+}
+
+// EnsureSpreadsheetExists returns the ID of the spreadsheet to write to. If config.Create is set
+// and no ID is given, it looks for a spreadsheet with the configured title and folder that the
+// service account can see, such as one created by an earlier run, and creates one if there isn't
+// one. A new spreadsheet is also created if there is no spreadsheet with the given ID. Either way,
+// the spreadsheet is then shared with anyone in config.ShareWith who doesn't already have access to
+// it. The client must have the config's DriveScopes.
+func EnsureSpreadsheetExists(spreadsheetID string, config SpreadsheetConfig, client *http.Client, srv *sheets.Service) (string, error) {
+	if !config.Create && spreadsheetID == "" {
+		return "", errors.New("no spreadsheet ID given")
+	}
+
+	if spreadsheetID != "" {
+		_, err := srv.Spreadsheets.Get(spreadsheetID).Fields("spreadsheetId").Do()
+		var apiErr *googleapi.Error
+		if err != nil && (!config.Create || !errors.As(err, &apiErr) || apiErr.Code != http.StatusNotFound) {
+			return "", fmt.Errorf("unable to open spreadsheet %s: %w", spreadsheetID, err)
+		}
+		if err == nil && len(config.ShareWith) == 0 {
+			return spreadsheetID, nil
+		}
+		if err != nil {
+			slog.Warn("spreadsheet not found, so a new one will be created", "spreadsheetID", spreadsheetID)
+			spreadsheetID = ""
+		}
+	}
+
+	driveService, err := drive.NewService(context.Background(), option.WithHTTPClient(client))
+	if err != nil {
+		return "", fmt.Errorf("unable to retrieve Drive client: %w", err)
+	}
+
+	if spreadsheetID == "" {
+		spreadsheetID, err = findOrCreateSpreadsheet(config, driveService)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if err := shareSpreadsheet(spreadsheetID, config.ShareWith, driveService); err != nil {
+		return "", err
+	}
+
+	return spreadsheetID, nil
+}
+
+// findOrCreateSpreadsheet returns the ID of the spreadsheet with the configured title in the
+// configured folder, creating it if there isn't one
+func findOrCreateSpreadsheet(config SpreadsheetConfig, driveService *drive.Service) (string, error) {
+	title := config.Title
+	if title == "" {
+		title = DefaultSpreadsheetTitle
+	}
+
+	list, err := driveService.Files.List().
+		Q(spreadsheetQuery(title, config.FolderID)).
+		SupportsAllDrives(true).
+		IncludeItemsFromAllDrives(true).
+		Fields("files(id)").
+		Do()
+	if err != nil {
+		return "", fmt.Errorf("unable to look for spreadsheet %q: %w", title, err)
+	}
+	if len(list.Files) > 0 {
+		return list.Files[0].Id, nil
+	}
+
+	file := &drive.File{Name: title, MimeType: spreadsheetMimeType}
+	if config.FolderID != "" {
+		file.Parents = []string{config.FolderID}
+	}
+
+	created, err := driveService.Files.Create(file).SupportsAllDrives(true).Fields("id").Do()
+	if err != nil {
+		return "", fmt.Errorf("unable to create spreadsheet %q: %w", title, err)
+	}
+
+	slog.Info("created spreadsheet", "title", title, "spreadsheetID", created.Id)
+	fmt.Printf("Created spreadsheet %q with ID %s\n", title, created.Id)
+	return created.Id, nil
+}
+
+// spreadsheetQuery returns the Drive query for a spreadsheet with the title in the folder
+func spreadsheetQuery(title, folderID string) string {
+	escape := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	query := fmt.Sprintf("name = '%s' and mimeType = '%s' and trashed = false", escape.Replace(title), spreadsheetMimeType)
+	if folderID != "" {
+		query += fmt.Sprintf(" and '%s' in parents", escape.Replace(folderID))
+	}
+	return query
+}
+
+// shareSpreadsheet gives each of the grantees who doesn't already have access permission to edit the spreadsheet
+func shareSpreadsheet(spreadsheetID string, grantees []Grantee, driveService *drive.Service) error {
+	if len(grantees) == 0 {
+		return nil
+	}
+
+	permissions, err := driveService.Permissions.List(spreadsheetID).
+		SupportsAllDrives(true).
+		Fields("permissions(emailAddress)").
+		Do()
+	if err != nil {
+		return fmt.Errorf("unable to get the sharing of spreadsheet %s: %w", spreadsheetID, err)
+	}
+
+	shared := map[string]bool{}
+	for _, permission := range permissions.Permissions {
+		shared[strings.ToLower(permission.EmailAddress)] = true
+	}
+
+	for _, grantee := range grantees {
+		if shared[strings.ToLower(grantee.Email)] {
+			continue
+		}
+
+		permission := &drive.Permission{Type: grantee.Type, Role: "writer", EmailAddress: grantee.Email}
+		_, err := driveService.Permissions.Create(spreadsheetID, permission).
+			SupportsAllDrives(true).
+			SendNotificationEmail(false).
+			Do()
+		if err != nil {
+			return fmt.Errorf("unable to share spreadsheet %s with %s (the service account must be an editor, "+
+				"and editors must be allowed to share it): %w", spreadsheetID, grantee.Email, err)
+		}
+		slog.Info("shared spreadsheet", "spreadsheetID", spreadsheetID, "with", grantee.Email)
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package googlesheets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/drive/v3"
+)
+
+func TestParseGrantees(t *testing.T) {
+	got, err := ParseGrantees("")
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+
+	got, err = ParseGrantees("someone@example.org, group:team@example.org,User:other@example.org")
+	assert.NoError(t, err)
+	assert.Equal(t, []Grantee{
+		{Type: GranteeUser, Email: "someone@example.org"},
+		{Type: GranteeGroup, Email: "team@example.org"},
+		{Type: GranteeUser, Email: "other@example.org"},
+	}, got)
+
+	_, err = ParseGrantees("domain:example.org")
+	assert.Error(t, err)
+
+	_, err = ParseGrantees("someone")
+	assert.Error(t, err)
+}
+
+func Test_spreadsheetQuery(t *testing.T) {
+	assert.Equal(t,
+		"name = 'Bob\\'s uptime' and mimeType = 'application/vnd.google-apps.spreadsheet' and trashed = false",
+		spreadsheetQuery("Bob's uptime", ""))
+	assert.Equal(t,
+		"name = 'Uptime' and mimeType = 'application/vnd.google-apps.spreadsheet' and trashed = false and 'abc123' in parents",
+		spreadsheetQuery("Uptime", "abc123"))
+}
+
+func TestSpreadsheetConfig_DriveScopes(t *testing.T) {
+	assert.Empty(t, SpreadsheetConfig{Title: "Uptimes"}.DriveScopes(), "a run that doesn't create or share should not ask for Drive")
+	assert.Equal(t, []string{drive.DriveScope}, SpreadsheetConfig{Create: true}.DriveScopes())
+	assert.Equal(t, []string{drive.DriveScope}, SpreadsheetConfig{ShareWith: []Grantee{{Type: GranteeUser, Email: "a@example.org"}}}.DriveScopes())
+}
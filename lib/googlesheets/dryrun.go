@@ -0,0 +1,319 @@
+package googlesheets
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	PlanFormatText = "text"
+	PlanFormatJSON = "json"
+
+	PlanAddSheet     = "addSheet"
+	PlanInsertColumn = "insertColumn"
+	PlanAddColumn    = "addColumn"
+	PlanInsertRow    = "insertRow"
+	PlanWrite        = "write"
+	PlanFinalize     = "finalize"
+	PlanSummary      = "summary"
+)
+
+// PlannedChange is one of the changes to the spreadsheet that a run would make
+type PlannedChange struct {
+	Action string `json:"action"` // One of PlanAddSheet, PlanInsertColumn etc.
+	Sheet  string `json:"sheet"`
+	Row    int    `json:"row,omitempty"`    // The row (1-based) that is inserted or written to
+	Column string `json:"column,omitempty"` // The letter of the column that is inserted or written to
+	Old    string `json:"old,omitempty"`    // The value of the cell before it is written
+	New    string `json:"new,omitempty"`    // The value written to the cell
+}
+
+// Plan lists the changes to the spreadsheet that a run would make, in order
+type Plan struct {
+	SpreadsheetID string          `json:"spreadsheetId"`
+	Changes       []PlannedChange `json:"changes"`
+}
+
+// ParsePlanFormat checks the format of a dry run's plan. An empty value gives PlanFormatText.
+func ParsePlanFormat(value string) (string, error) {
+	switch format := strings.ToLower(strings.TrimSpace(value)); format {
+	case "":
+		return PlanFormatText, nil
+	case PlanFormatText, PlanFormatJSON:
+		return format, nil
+	}
+	return "", fmt.Errorf(`plan format %q must be "%s" or "%s"`, value, PlanFormatText, PlanFormatJSON)
+}
+
+// Format returns the plan as JSON, or else as text like a diff, with a line for each change:
+// "+" for an added tab, row or column or a value in an empty cell, "~" for a value that changes,
+// and "=" for a value that is written again unchanged
+func (p Plan) Format(format string) (string, error) {
+	if format == PlanFormatJSON {
+		b, err := json.MarshalIndent(p, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("unable to format the plan as JSON: %w", err)
+		}
+		return string(b) + "\n", nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Planned changes to spreadsheet %s:\n", p.SpreadsheetID)
+	for _, change := range p.Changes {
+		switch change.Action {
+		case PlanAddSheet:
+			fmt.Fprintf(&b, "+ tab %q\n", change.Sheet)
+		case PlanInsertColumn:
+			fmt.Fprintf(&b, "+ column %s in %q (inserted)\n", change.Column, change.Sheet)
+		case PlanAddColumn:
+			fmt.Fprintf(&b, "+ column %s in %q (added at the end)\n", change.Column, change.Sheet)
+		case PlanInsertRow:
+			fmt.Fprintf(&b, "+ row %d in %q (inserted)\n", change.Row, change.Sheet)
+		case PlanWrite:
+			cell := SheetRange(change.Sheet, fmt.Sprintf("%s%d", change.Column, change.Row))
+			switch change.Old {
+			case "":
+				fmt.Fprintf(&b, "+ %s: %s\n", cell, change.New)
+			case change.New:
+				fmt.Fprintf(&b, "= %s: %s\n", cell, change.New)
+			default:
+				fmt.Fprintf(&b, "~ %s: %s -> %s\n", cell, change.Old, change.New)
+			}
+		case PlanFinalize:
+			fmt.Fprintf(&b, "~ column %s in %q (no longer provisional)\n", change.Column, change.Sheet)
+		case PlanSummary:
+			fmt.Fprintf(&b, "~ summary of %q (rebuilt)\n", change.Sheet)
+		}
+	}
+	fmt.Fprintf(&b, "%d changes\n", len(p.Changes))
+	return b.String(), nil
+}
+
+// DryRunUnsupported returns the options that a dry run can't plan the changes of, so that they can be
+// rejected rather than silently left out of the plan. A dry run plans the wide layout's year tabs:
+// their new tabs, columns and rows, the uptimes, the provisional columns that are finalized and the
+// rebuilt summaries.
+func DryRunUnsupported(options ArchiveOptions) []string {
+	var unsupported []string
+	add := func(set bool, name string) {
+		if set {
+			unsupported = append(unsupported, name)
+		}
+	}
+
+	add(options.Layout != "" && options.Layout != LayoutWide, "the "+options.Layout+" layout")
+	add(options.RetiredTreatment != "" && options.RetiredTreatment != RetiredNone, "retired rows")
+	add(options.Notes, "notes")
+	add(options.Upsert, "provisional months")
+	add(options.ProtectMonths, "protected months")
+	add(options.Charts, "charts")
+	add(options.Daily, "the daily tab")
+	add(len(options.Services) > 0, "services")
+	add(len(options.SLOs) > 0, "SLOs")
+	add(options.AllTime, "the all-time tab")
+	return unsupported
+}
+
+// PlanWideResults works out the changes that the wide layout's EnsureMonthColumnExists,
+// EnsureCheckRowExists, writes of the uptimes, FinalizeMonthColumn and UpdateSummary would make,
+// without making them. It only reads the spreadsheet: each year tab's values, the check IDs of its
+// rows and its provisional columns. The options that DryRunUnsupported returns aren't planned.
+func PlanWideResults(months []MonthUptimes, precision, countLimit int, now time.Time, sheetsData SheetsData) (Plan, error) {
+	plan := Plan{SpreadsheetID: sheetsData.SpreadsheetID}
+	tabs := map[string]*plannedSheet{}
+	var sheetNames []string
+
+	for _, month := range months {
+		sheetName := sheetsData.GetSheetName(month.Year)
+		tab, ok := tabs[sheetName]
+		if !ok {
+			var err error
+			tab, err = readPlannedSheet(sheetName, sheetsData)
+			if err != nil {
+				return Plan{}, err
+			}
+			tabs[sheetName] = tab
+			sheetNames = append(sheetNames, sheetName)
+			if !tab.exists {
+				plan.Changes = append(plan.Changes, PlannedChange{Action: PlanAddSheet, Sheet: sheetName})
+			}
+		}
+
+		monthColumn, changes := tab.ensureMonthColumn(month.Month, month.Year)
+		plan.Changes = append(plan.Changes, changes...)
+
+		for i, uptime := range month.Uptimes {
+			if i >= countLimit {
+				break
+			}
+			if uptime.Error != nil {
+				continue
+			}
+
+			checkRow, changes := tab.ensureCheckRow(uptime.CheckID, uptime.Label)
+			plan.Changes = append(plan.Changes, changes...)
+
+			value := FormatUptime(roundToPrecision(uptime.Uptime, precision), precision) + "%"
+			plan.Changes = append(plan.Changes, tab.write(checkRow, monthColumn, value))
+		}
+
+		header := month.Month + " " + month.Year
+		failed := slices.ContainsFunc(month.Uptimes, func(uptime CheckUptime) bool { return uptime.Error != nil })
+		if tab.provisional[header] && monthHasEnded(month.Month, month.Year, now) && !failed {
+			letter, _ := ConvertColumnIndexToLetter(int64(monthColumn))
+			plan.Changes = append(plan.Changes, PlannedChange{Action: PlanFinalize, Sheet: sheetName, Column: letter})
+		}
+	}
+
+	for _, sheetName := range sheetNames {
+		plan.Changes = append(plan.Changes, PlannedChange{Action: PlanSummary, Sheet: sheetName})
+	}
+
+	return plan, nil
+}
+
+// plannedSheet is a copy of a year tab that the planned changes are made to
+type plannedSheet struct {
+	name      string
+	exists    bool
+	cells     [][]any        // The values of the tab, from A1
+	checkRows map[int]string // The check IDs of the rows, keyed by row (1-based)
+	format    MonthFormat    // How the month headings are written
+
+	// The months whose columns are provisional (see MarkMonthProvisional), e.g. "March 2024"
+	provisional map[string]bool
+}
+
+// readPlannedSheet reads the values of the tab, the check IDs of its rows and its provisional months.
+// A tab that doesn't exist yet gets the headings that EnsureSheetExists would give it.
+func readPlannedSheet(sheetName string, sheetsData SheetsData) (*plannedSheet, error) {
+	tab := &plannedSheet{name: sheetName, checkRows: map[int]string{}, format: sheetsData.MonthFormat, provisional: map[string]bool{}}
+
+	exists, sheetID, err := GetSheetIDFromTitle(sheetName, sheetsData)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		tab.cells = [][]any{{"", "Uptime Percent"}, {"Checks"}}
+		return tab, nil
+	}
+
+	tab.exists = true
+	resp, err := sheetsData.Service.Spreadsheets.Values.Get(sheetsData.SpreadsheetID, SheetRange(sheetName, "")).Do()
+	if err != nil {
+		return nil, fmt.Errorf("error getting the values of %s: %w", sheetName, err)
+	}
+	tab.cells = resp.Values
+
+	sheetsData.SheetID = sheetID
+	tab.checkRows, err = GetCheckRowsFromMetadata(sheetsData)
+	if err != nil {
+		return nil, err
+	}
+
+	provisional, err := getDimensionMetadata(ProvisionalMetadataKey, "COLUMN", sheetsData)
+	if err != nil {
+		return nil, err
+	}
+	for column := range provisional {
+		if month, err := tab.format.Parse(tab.cell(MonthHeaderRow, column)); err == nil {
+			tab.provisional[month.Format(DefaultMonthLayout)] = true
+		}
+	}
+
+	return tab, nil
+}
+
+// cell returns the value of the cell at the row (1-based) and column (0-based)
+func (t *plannedSheet) cell(row, column int) string {
+	if row < 1 || row > len(t.cells) || column >= len(t.cells[row-1]) {
+		return ""
+	}
+	return fmt.Sprintf("%v", t.cells[row-1][column])
+}
+
+// ensureMonthColumn is EnsureMonthColumnExists for the copy of the tab
+func (t *plannedSheet) ensureMonthColumn(month, year string) (int, []PlannedChange) {
+	var headers []any
+	if len(t.cells) >= MonthHeaderRow && len(t.cells[MonthHeaderRow-1]) > 1 {
+		headers = t.cells[MonthHeaderRow-1][1:]
+	}
+
+	column, insertColumn, addColumn := findMonthColumnPosition(month, year, headers, t.format)
+	letter, _ := ConvertColumnIndexToLetter(int64(column))
+
+	var changes []PlannedChange
+	if insertColumn {
+		changes = append(changes, PlannedChange{Action: PlanInsertColumn, Sheet: t.name, Column: letter})
+		for i, row := range t.cells {
+			if column < len(row) {
+				t.cells[i] = slices.Insert(row, column, any(""))
+			}
+		}
+	}
+	if addColumn {
+		changes = append(changes, PlannedChange{Action: PlanAddColumn, Sheet: t.name, Column: letter})
+	}
+
+	return column, append(changes, t.write(MonthHeaderRow, column, t.format.HeaderFor(month, year)))
+}
+
+// ensureCheckRow is EnsureCheckRowExists for the copy of the tab
+func (t *plannedSheet) ensureCheckRow(checkID, label string) (int, []PlannedChange) {
+	var rows [][]any
+	for i := FirstCheckRow - 1; i < len(t.cells); i++ {
+		rows = append(rows, t.cells[i][:min(len(t.cells[i]), 1)])
+	}
+
+	row, insertRow, found := findCheckRow(checkID, label, rows, t.checkRows)
+	if found {
+		if t.cell(row, 0) == label {
+			return row, nil
+		}
+		return row, []PlannedChange{t.write(row, 0, label)}
+	}
+
+	var changes []PlannedChange
+	if insertRow {
+		changes = append(changes, PlannedChange{Action: PlanInsertRow, Sheet: t.name, Row: row})
+		if row <= len(t.cells) {
+			t.cells = slices.Insert(t.cells, row-1, []any{})
+		}
+
+		checkRows := map[int]string{}
+		for r, id := range t.checkRows {
+			if r >= row {
+				r++
+			}
+			checkRows[r] = id
+		}
+		t.checkRows = checkRows
+	}
+
+	changes = append(changes, t.write(row, 0, label))
+	if checkID != "" {
+		t.checkRows[row] = checkID
+	}
+
+	return row, changes
+}
+
+// write sets the value of the cell at the row (1-based) and column (0-based) in the copy of the tab,
+// and returns the change
+func (t *plannedSheet) write(row, column int, value string) PlannedChange {
+	letter, _ := ConvertColumnIndexToLetter(int64(column))
+	change := PlannedChange{Action: PlanWrite, Sheet: t.name, Row: row, Column: letter, Old: t.cell(row, column), New: value}
+
+	for len(t.cells) < row {
+		t.cells = append(t.cells, []any{})
+	}
+	for len(t.cells[row-1]) <= column {
+		t.cells[row-1] = append(t.cells[row-1], "")
+	}
+	t.cells[row-1][column] = value
+
+	return change
+}
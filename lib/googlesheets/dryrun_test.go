@@ -0,0 +1,124 @@
+package googlesheets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
+)
+
+func Test_findMonthColumnPosition(t *testing.T) {
+	column, insert, add := findMonthColumnPosition("March", "2024", nil, MonthFormat{})
+	assert.Equal(t, []any{1, false, false}, []any{column, insert, add})
+
+	headers := []any{"January 2024", "April 2024", "Year avg", "Worst month", "Months below SLO"}
+	column, insert, add = findMonthColumnPosition("March", "2024", headers, MonthFormat{})
+	assert.Equal(t, []any{2, true, false}, []any{column, insert, add})
+
+	column, insert, add = findMonthColumnPosition("May", "2024", headers, MonthFormat{})
+	assert.Equal(t, []any{3, true, false}, []any{column, insert, add}, "a later month should go before the summary")
+
+	column, insert, add = findMonthColumnPosition("May", "2024", []any{"January 2024", "April 2024"}, MonthFormat{})
+	assert.Equal(t, []any{3, false, true}, []any{column, insert, add})
+
+	column, insert, add = findMonthColumnPosition("April", "2024", headers, MonthFormat{})
+	assert.Equal(t, []any{2, false, false}, []any{column, insert, add}, "a month should reuse its column")
+
+	mixed := []any{"janvier 2024", "2024-02", "April 2024"}
+	column, insert, add = findMonthColumnPosition("March", "2024", mixed, MonthFormat{Layout: "2006-01"})
+	assert.Equal(t, []any{3, true, false}, []any{column, insert, add}, "headings in other formats should still be in order")
+
+	column, insert, add = findMonthColumnPosition("January", "2024", mixed, MonthFormat{Layout: "2006-01"})
+	assert.Equal(t, []any{1, false, false}, []any{column, insert, add}, "a month should reuse its column with a heading in another format")
+}
+
+func Test_findCheckRow(t *testing.T) {
+	rows := [][]any{{"API"}, {"Website"}, {SummaryTotalsLabel}}
+
+	row, insert, found := findCheckRow("id2", "Website (renamed)", rows, map[int]string{3: "id1", 4: "id2"})
+	assert.Equal(t, []any{4, false, true}, []any{row, insert, found})
+
+	row, insert, found = findCheckRow("id3", "Mail", rows, map[int]string{3: "id1", 4: "id2"})
+	assert.Equal(t, []any{4, true, false}, []any{row, insert, found})
+
+	row, insert, found = findCheckRow("id3", "Zebra", rows, map[int]string{3: "id1", 4: "id2"})
+	assert.Equal(t, []any{5, true, false}, []any{row, insert, found}, "a new check should go above the totals row")
+}
+
+func TestPlannedSheet(t *testing.T) {
+	tab := &plannedSheet{
+		name:   "2024",
+		exists: true,
+		cells: [][]any{
+			{"", "Uptime Percent"},
+			{"Checks", "January 2024", "April 2024"},
+			{"API", "99.000%", "98.000%"},
+			{"Website", "100.000%"},
+		},
+		checkRows: map[int]string{3: "id1", 4: "id2"},
+	}
+
+	column, changes := tab.ensureMonthColumn("March", "2024")
+	assert.Equal(t, 2, column)
+	assert.Equal(t, []PlannedChange{
+		{Action: PlanInsertColumn, Sheet: "2024", Column: "C"},
+		{Action: PlanWrite, Sheet: "2024", Row: 2, Column: "C", New: "March 2024"},
+	}, changes)
+	assert.Equal(t, "98.000%", tab.cell(3, 3), "later months should move right")
+
+	row, changes := tab.ensureCheckRow("id3", "Mail")
+	assert.Equal(t, 4, row)
+	assert.Equal(t, []PlannedChange{
+		{Action: PlanInsertRow, Sheet: "2024", Row: 4},
+		{Action: PlanWrite, Sheet: "2024", Row: 4, Column: "A", New: "Mail"},
+	}, changes)
+	assert.Equal(t, map[int]string{3: "id1", 4: "id3", 5: "id2"}, tab.checkRows, "later rows should move down")
+
+	row, changes = tab.ensureCheckRow("id2", "Website")
+	assert.Equal(t, 5, row)
+	assert.Empty(t, changes)
+
+	assert.Equal(t, PlannedChange{Action: PlanWrite, Sheet: "2024", Row: 3, Column: "B", Old: "99.000%", New: "99.500%"},
+		tab.write(3, 1, "99.500%"))
+}
+
+func TestPlanFormat(t *testing.T) {
+	plan := Plan{SpreadsheetID: "abc", Changes: []PlannedChange{
+		{Action: PlanAddSheet, Sheet: "2024"},
+		{Action: PlanInsertRow, Sheet: "2024", Row: 4},
+		{Action: PlanWrite, Sheet: "2024", Row: 4, Column: "B", New: "99.000%"},
+		{Action: PlanWrite, Sheet: "2024", Row: 5, Column: "B", Old: "98.000%", New: "99.000%"},
+		{Action: PlanWrite, Sheet: "2024", Row: 6, Column: "B", Old: "99.000%", New: "99.000%"},
+		{Action: PlanFinalize, Sheet: "2024", Column: "B"},
+		{Action: PlanSummary, Sheet: "2024"},
+	}}
+
+	text, err := plan.Format(PlanFormatText)
+	assert.NoError(t, err)
+	assert.Equal(t, `Planned changes to spreadsheet abc:
++ tab "2024"
++ row 4 in "2024" (inserted)
++ '2024'!B4: 99.000%
+~ '2024'!B5: 98.000% -> 99.000%
+= '2024'!B6: 99.000%
+~ column B in "2024" (no longer provisional)
+~ summary of "2024" (rebuilt)
+7 changes
+`, text)
+
+	json, err := plan.Format(PlanFormatJSON)
+	assert.NoError(t, err)
+	assert.Contains(t, json, `"action": "insertRow"`)
+
+	_, err = ParsePlanFormat("yaml")
+	assert.Error(t, err)
+}
+
+func TestDryRunUnsupported(t *testing.T) {
+	assert.Empty(t, DryRunUnsupported(ArchiveOptions{}))
+	assert.Empty(t, DryRunUnsupported(ArchiveOptions{Layout: LayoutWide, RetiredTreatment: RetiredNone, DryRun: true}))
+
+	options := ArchiveOptions{Layout: LayoutLong, Daily: true, Charts: true, SLOs: []nodeping.SLO{{}}}
+	assert.Equal(t, []string{"the long layout", "charts", "the daily tab", "SLOs"}, DryRunUnsupported(options))
+}
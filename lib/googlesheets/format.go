@@ -0,0 +1,136 @@
+package googlesheets
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/sheets/v4"
+)
+
+// UptimeThresholds are the uptime percentages that decide the color of an uptime cell. A value at or
+// above Green is green, otherwise at or above Amber is amber, and below Red is red. Values between
+// Red and Amber aren't colored, so Red is usually the same as Amber.
+type UptimeThresholds struct {
+	Green float64
+	Amber float64
+	Red   float64
+}
+
+// DefaultUptimeThresholds are used for new tabs unless other thresholds are configured
+var DefaultUptimeThresholds = UptimeThresholds{Green: 99.9, Amber: 99, Red: 99}
+
+// ParseUptimeThresholds parses thresholds like "99.9,99,99" (green, amber and red). An empty value
+// gives the DefaultUptimeThresholds.
+func ParseUptimeThresholds(value string) (UptimeThresholds, error) {
+	if value == "" {
+		return DefaultUptimeThresholds, nil
+	}
+
+	parts := strings.Split(value, ",")
+	if len(parts) != 3 {
+		return UptimeThresholds{}, fmt.Errorf("thresholds %q must be three numbers, for green, amber and red", value)
+	}
+
+	var numbers [3]float64
+	for i, part := range parts {
+		number, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return UptimeThresholds{}, fmt.Errorf("invalid threshold in %q: %w", value, err)
+		}
+		numbers[i] = number
+	}
+
+	thresholds := UptimeThresholds{Green: numbers[0], Amber: numbers[1], Red: numbers[2]}
+	if thresholds.Green < thresholds.Amber || thresholds.Amber < thresholds.Red {
+		return UptimeThresholds{}, fmt.Errorf("thresholds %q must be in order: green, then amber, then red", value)
+	}
+
+	return thresholds, nil
+}
+
+// UptimeNumberFormat returns the number format of an uptime cell, which shows the value with the
+// given number of decimal places and a percent sign
+func UptimeNumberFormat(precision int) *sheets.NumberFormat {
+	pattern := "0"
+	if precision > 0 {
+		pattern += "." + strings.Repeat("0", precision)
+	}
+
+	return &sheets.NumberFormat{Type: "NUMBER", Pattern: pattern + `"%"`}
+}
+
+// WriteUptimeToCell writes an uptime percentage to a cell as a number, rounded to the precision and
+// shown with UptimeNumberFormat. The row is 1-based, as in A1 notation, and the column is 0-based.
+func WriteUptimeToCell(rowIndex, columnIndex int64, uptime float64, precision int, sheetsData SheetsData) error {
+	rbb := &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{newUptimeCellRequest(rowIndex, columnIndex, uptime, precision, sheetsData.SheetID)},
+	}
+	_, err := sheetsData.Service.Spreadsheets.BatchUpdate(sheetsData.SpreadsheetID, rbb).Context(context.Background()).Do()
+	if err != nil {
+		return fmt.Errorf("unable to write uptime to row %d, column %d: %w", rowIndex, columnIndex+1, err)
+	}
+
+	return nil
+}
+
+// newUptimeCellRequest returns the request that WriteUptimeToCell makes
+func newUptimeCellRequest(rowIndex, columnIndex int64, uptime float64, precision int, sheetID int64) *sheets.Request {
+	value := roundToPrecision(uptime, precision)
+	return &sheets.Request{
+		UpdateCells: &sheets.UpdateCellsRequest{
+			Start: &sheets.GridCoordinate{
+				SheetId:     sheetID,
+				RowIndex:    rowIndex - 1,
+				ColumnIndex: columnIndex,
+			},
+			Rows: []*sheets.RowData{{Values: []*sheets.CellData{{
+				UserEnteredValue:  &sheets.ExtendedValue{NumberValue: &value},
+				UserEnteredFormat: &sheets.CellFormat{NumberFormat: UptimeNumberFormat(precision)},
+			}}}},
+			Fields: "userEnteredValue,userEnteredFormat.numberFormat",
+		},
+	}
+}
+
+// uptimeFormatRequests returns the requests that add the green, amber and red conditional formatting
+// rules to the uptime cells of a year tab, i.e. from B3 down and to the right
+func uptimeFormatRequests(sheetID int64, thresholds UptimeThresholds) []*sheets.Request {
+	// The count of months below SLO in the summary block isn't an uptime, so it isn't colored
+	firstCell := fmt.Sprintf("B%d", FirstCheckRow)
+	isUptime := fmt.Sprintf(`ISNUMBER(%s), B$%d<>"%s"`, firstCell, MonthHeaderRow, SummaryHeaders[2])
+	rules := []struct {
+		formula string
+		color   *sheets.Color
+	}{
+		{fmt.Sprintf("=AND(%s, %s>=%v)", isUptime, firstCell, thresholds.Green), heatmapGreen},
+		{fmt.Sprintf("=AND(%s, %s>=%v)", isUptime, firstCell, thresholds.Amber), heatmapAmber},
+		{fmt.Sprintf("=AND(%s, %s<%v)", isUptime, firstCell, thresholds.Red), heatmapRed},
+	}
+
+	var requests []*sheets.Request
+	for i, rule := range rules {
+		requests = append(requests, &sheets.Request{
+			AddConditionalFormatRule: &sheets.AddConditionalFormatRuleRequest{
+				Index: int64(i),
+				Rule: &sheets.ConditionalFormatRule{
+					Ranges: []*sheets.GridRange{{
+						SheetId:          sheetID,
+						StartRowIndex:    FirstCheckRow - 1,
+						StartColumnIndex: 1,
+					}},
+					BooleanRule: &sheets.BooleanRule{
+						Condition: &sheets.BooleanCondition{
+							Type:   "CUSTOM_FORMULA",
+							Values: []*sheets.ConditionValue{{UserEnteredValue: rule.formula}},
+						},
+						Format: &sheets.CellFormat{BackgroundColor: rule.color},
+					},
+				},
+			},
+		})
+	}
+
+	return requests
+}
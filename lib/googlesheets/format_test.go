@@ -0,0 +1,44 @@
+package googlesheets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUptimeThresholds(t *testing.T) {
+	got, err := ParseUptimeThresholds("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultUptimeThresholds, got)
+
+	got, err = ParseUptimeThresholds("99.95, 99.5, 99")
+	assert.NoError(t, err)
+	assert.Equal(t, UptimeThresholds{Green: 99.95, Amber: 99.5, Red: 99}, got)
+
+	_, err = ParseUptimeThresholds("99.9,99")
+	assert.Error(t, err)
+
+	_, err = ParseUptimeThresholds("99,99.9,99")
+	assert.Error(t, err, "green must not be below amber")
+
+	_, err = ParseUptimeThresholds("99.9,high,99")
+	assert.Error(t, err)
+}
+
+func TestUptimeNumberFormat(t *testing.T) {
+	assert.Equal(t, `0.000"%"`, UptimeNumberFormat(3).Pattern)
+	assert.Equal(t, `0"%"`, UptimeNumberFormat(0).Pattern)
+}
+
+func Test_uptimeFormatRequests(t *testing.T) {
+	requests := uptimeFormatRequests(7, UptimeThresholds{Green: 99.9, Amber: 99, Red: 98})
+	assert.Len(t, requests, 3)
+
+	formula := func(i int) string {
+		return requests[i].AddConditionalFormatRule.Rule.BooleanRule.Condition.Values[0].UserEnteredValue
+	}
+	assert.Equal(t, `=AND(ISNUMBER(B3), B$2<>"Months below SLO", B3>=99.9)`, formula(0))
+	assert.Equal(t, `=AND(ISNUMBER(B3), B$2<>"Months below SLO", B3>=99)`, formula(1))
+	assert.Equal(t, `=AND(ISNUMBER(B3), B$2<>"Months below SLO", B3<98)`, formula(2))
+	assert.Equal(t, int64(7), requests[2].AddConditionalFormatRule.Rule.Ranges[0].SheetId)
+}
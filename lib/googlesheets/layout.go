@@ -0,0 +1,509 @@
+package googlesheets
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/sheets/v4"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
+)
+
+const (
+	LayoutWide       = "wide"       // A tab per year, with months across and checks down
+	LayoutTransposed = "transposed" // A tab per year, with checks across and months down
+	LayoutLong       = "long"       // A single table with a row for each month and check
+
+	DefaultTabTemplate = "{year}"
+
+	// LongSheetName takes the place of the year in the tab template for the table of the long layout
+	LongSheetName = "Uptimes"
+)
+
+// LongHeaders are the headings of the columns of the long layout's table
+var LongHeaders = []string{"Month", "Contact group", "Check", "Check ID", "Uptime"}
+
+// ParseLayout checks the name of a layout. An empty value gives LayoutWide.
+func ParseLayout(value string) (string, error) {
+	switch layout := strings.ToLower(strings.TrimSpace(value)); layout {
+	case "":
+		return LayoutWide, nil
+	case LayoutWide, LayoutTransposed, LayoutLong:
+		return layout, nil
+	}
+	return "", fmt.Errorf(`layout %q must be "%s", "%s" or "%s"`, value, LayoutWide, LayoutTransposed, LayoutLong)
+}
+
+// ValidateTabTemplate checks that a tab template includes the year, since each year gets a tab of its
+// own, and the other tabs (such as the SLO tab) use their name in place of the year
+func ValidateTabTemplate(template string) error {
+	if template == "" || strings.Contains(template, "{year}") {
+		return nil
+	}
+	return fmt.Errorf(`tab template %q must include "{year}"`, template)
+}
+
+// TabName fills in a tab template like "{group} {year}" with the contact group and the year. An empty
+// template gives the DefaultTabTemplate, i.e. just the year.
+func TabName(template, group, year string) string {
+	if template == "" {
+		template = DefaultTabTemplate
+	}
+	return strings.NewReplacer("{group}", group, "{year}", year).Replace(template)
+}
+
+// ArchiveTransposedResults writes the uptimes to the year tabs of the transposed layout, which have a
+// column for each check (from column B, in alphabetical order) and a row for each month (from
+// FirstCheckRow, in calendar order). If notes is set, the cells get the notes of WriteUptimeWithNotes.
+// A check that can't be written is reported as failed, and the rest are still written. The checks
+// and month rows in the checkpoints (which can be nil) are skipped and reused.
+func ArchiveTransposedResults(
+	monthUptimes []MonthUptimes,
+	checks []nodeping.Check,
+	period nodeping.Period,
+	precision, countLimit int,
+	notes bool,
+	thresholds UptimeThresholds,
+	checkpoints *checkpointer,
+	sheetsData SheetsData,
+) ([]MonthReport, error) {
+	checksByID := map[string]nodeping.Check{}
+	for _, check := range checks {
+		checksByID[check.ID] = check
+	}
+
+	index := 1
+	const delay = time.Second * 22
+
+	var reports []MonthReport
+	for _, month := range monthUptimes {
+		started := time.Now()
+		sheetName := sheetsData.GetSheetName(month.Year)
+		sheetID, err := ensureUptimeSheetExists(sheetName, "Month", thresholds, sheetsData)
+		if err != nil {
+			return reports, err
+		}
+		sheetsData.SheetID = sheetID
+
+		monthCheckpoint := checkpoints.month(sheetName, month.Month+" "+month.Year)
+		monthRow, err := monthCheckpoint.resumedRow(sheetsData)
+		if err != nil {
+			return reports, err
+		}
+		if monthRow == 0 {
+			monthRow, err = EnsureMonthRowExists(month.Month, month.Year, sheetsData)
+			if err != nil {
+				return reports, fmt.Errorf("error choosing row for '%s': %w", month.Month, err)
+			}
+			if monthCheckpoint != nil {
+				monthCheckpoint.Row = monthRow
+			}
+		}
+		report := MonthReport{Sheet: sheetName, Month: month.Month + " " + month.Year, Row: monthRow}
+
+		span := monthSpan(month.Month, month.Year, period, time.Now().UTC())
+		for i, checkUptime := range month.Uptimes {
+			if i >= countLimit {
+				break
+			}
+
+			// The quotas are per minute per user, so the checks are spread out. A request that is over
+			// a quota anyway is retried (see retryTransport).
+			if index%20 == 0 {
+				fmt.Printf("Waiting %v seconds at index %d to avoid Google Api rate limiting.\n", delay.Seconds(), index)
+				time.Sleep(delay)
+			}
+
+			checkReport := CheckReport{
+				CheckID: checkUptime.CheckID,
+				Label:   checkUptime.Label,
+				Row:     monthRow,
+				Value:   roundToPrecision(checkUptime.Uptime, precision),
+			}
+
+			if monthCheckpoint.isDone(checkpointKey(checkUptime)) {
+				checkReport.Resumed = true
+				report.Checks = append(report.Checks, checkReport)
+				continue
+			}
+
+			if checkUptime.Error != nil {
+				checkReport.Error = checkUptime.Error.Error()
+				report.Checks = append(report.Checks, checkReport)
+				continue
+			}
+
+			checkColumn, inserted, err := ensureCheckColumn(checkUptime.CheckID, checkUptime.Label, month.Year, sheetsData)
+			if err != nil {
+				err = fmt.Errorf("error adding column for '%s': %w", checkUptime.Label, err)
+			} else {
+				checkReport.Column, _ = ConvertColumnIndexToLetter(int64(checkColumn))
+				checkReport.Inserted = inserted
+				if notes {
+					check := checksByID[checkUptime.CheckID]
+					err = writeUptimeWithNotes(int64(monthRow), int64(checkColumn), MonthHeaderRow, checkColumn, checkUptime, check, span, precision, sheetsData)
+				} else {
+					err = WriteUptimeToCell(int64(monthRow), int64(checkColumn), checkUptime.Uptime, precision, sheetsData)
+				}
+			}
+			if err != nil {
+				slog.Error("unable to archive NodePing check", "check", checkUptime.Label, "month", report.Month, "error", err)
+				checkReport.Error = err.Error()
+			} else if err := checkpoints.done(monthCheckpoint, checkpointKey(checkUptime)); err != nil {
+				return append(reports, report), err
+			}
+			report.Checks = append(report.Checks, checkReport)
+
+			index++
+		}
+
+		report.Seconds = secondsSince(started)
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+// EnsureMonthRowExists is the transposed layout's EnsureMonthColumnExists. It returns the row (1-based)
+// of the month in column A, adding the month in calendar order if it isn't there yet.
+func EnsureMonthRowExists(month, year string, sheetsData SheetsData) (int, error) {
+	sheetName := sheetsData.GetSheetName(year)
+	srv := sheetsData.Service
+	spreadsheetID := sheetsData.SpreadsheetID
+
+	properties, err := GetGridProperties(sheetName, sheetsData)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := getCheckNames(sheetName, properties, sheetsData)
+	if err != nil {
+		return 0, err
+	}
+
+	monthHeader := sheetsData.MonthFormat.HeaderFor(month, year)
+	index, insertRow := findMonthRow(month, year, rows, sheetsData.MonthFormat)
+	row := FirstCheckRow + index
+
+	if insertRow {
+		slog.Info("inserting row for month", "row", row, "month", monthHeader)
+		if err := InsertRow(int64(row-1), sheetsData.SheetID, spreadsheetID, srv); err != nil {
+			return 0, fmt.Errorf("error inserting a row in Google sheets: %w", err)
+		}
+	} else if err := EnsureRowCount(int64(row), properties, spreadsheetID, srv); err != nil {
+		return 0, err
+	}
+
+	if !insertRow && cellLabel(rows, index) == monthHeader {
+		return row, nil
+	}
+	return row, WriteToCellWithColumnLetter(int64(row), "A", monthHeader, sheetName, spreadsheetID, srv)
+}
+
+// findMonthRow returns the index (0-based, like the rows) of the month's row, which may have its heading
+// in any format, and whether a row has to be inserted there to keep the months in calendar order
+func findMonthRow(month, year string, rows [][]any, format MonthFormat) (int, bool) {
+	desiredMonth, _ := time.Parse(DefaultMonthLayout, month+" "+year)
+	for i := range rows {
+		label := cellLabel(rows, i)
+		if label == "" || format.SameMonth(label, month, year) {
+			return i, false
+		}
+		if rowMonth, err := format.Parse(label); err == nil && desiredMonth.Before(rowMonth) {
+			return i, true
+		}
+	}
+	return len(rows), false
+}
+
+// EnsureCheckColumnExists is the transposed layout's EnsureCheckRowExists. It returns the (0-based)
+// column of the check in the heading row, which it looks for by the check ID in the column's developer
+// metadata, and then by the check's name, ignoring columns that belong to other checks. Otherwise,
+// the check is added in alphabetical order. A renamed check gets its new name.
+func EnsureCheckColumnExists(checkID, label, year string, sheetsData SheetsData) (int, error) {
+	column, _, err := ensureCheckColumn(checkID, label, year, sheetsData)
+	return column, err
+}
+
+// ensureCheckColumn is EnsureCheckColumnExists, which also reports whether a column was inserted for the check
+func ensureCheckColumn(checkID, label, year string, sheetsData SheetsData) (int, bool, error) {
+	sheetName := sheetsData.GetSheetName(year)
+	srv := sheetsData.Service
+	spreadsheetID := sheetsData.SpreadsheetID
+
+	properties, err := GetGridProperties(sheetName, sheetsData)
+	if err != nil {
+		return 0, false, err
+	}
+
+	headers, err := getMonthHeaders(sheetName, properties, sheetsData)
+	if err != nil {
+		return 0, false, err
+	}
+
+	// The headings from column B, in the form of rows, so that they can be searched like check names
+	names := make([][]any, len(headers))
+	for i, header := range headers {
+		names[i] = []any{header}
+	}
+
+	claimed := map[int]string{}
+	if checkID != "" {
+		checkColumns, err := GetCheckColumnsFromMetadata(sheetsData)
+		if err != nil {
+			return 0, false, err
+		}
+
+		for column, id := range checkColumns {
+			if id != checkID {
+				claimed[column-1] = id
+				continue
+			}
+			if cellLabel(names, column-1) == label {
+				return column, false, nil
+			}
+			slog.Info("updating label of renamed NodePing check", "column", column+1, "check", label)
+			return column, false, WriteToCellWithColumnIndex(MonthHeaderRow, int64(column), label, sheetName, spreadsheetID, srv)
+		}
+	}
+
+	index, insertColumn := findRowForCheck(label, names, claimed)
+	column := index + 1
+
+	if insertColumn {
+		slog.Info("inserting column for NodePing check", "column", column+1, "check", label)
+		if err := InsertColumn(int64(column), sheetsData.SheetID, spreadsheetID, srv); err != nil {
+			return 0, false, fmt.Errorf("error inserting a column in Google sheets: %w", err)
+		}
+	} else if err := EnsureColumnCount(int64(column+1), properties, spreadsheetID, srv); err != nil {
+		return 0, false, err
+	}
+
+	err = WriteToCellWithColumnIndex(MonthHeaderRow, int64(column), label, sheetName, spreadsheetID, srv)
+	if err != nil || checkID == "" {
+		return column, insertColumn, err
+	}
+
+	return column, insertColumn, SetCheckColumnMetadata(column, checkID, sheetsData)
+}
+
+// ArchiveLongResults writes the uptimes to the table of the long layout, which has a row for each
+// month and check, with the columns of LongHeaders. The table is on a single tab, named by the tab
+// template with LongSheetName in place of the year, so that it can feed a pivot table. The row of a
+// month and check that is already in the table is updated, and the other rows are appended.
+func ArchiveLongResults(group string, monthUptimes []MonthUptimes, precision, countLimit int, sheetsData SheetsData) ([]MonthReport, error) {
+	started := time.Now()
+	sheetName := sheetsData.GetSheetName(LongSheetName)
+	srv := sheetsData.Service
+	spreadsheetID := sheetsData.SpreadsheetID
+
+	sheetID, err := ensureLongSheetExists(sheetName, sheetsData)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := srv.Spreadsheets.Values.Get(spreadsheetID, SheetRange(sheetName, "A2:D")).Do()
+	if err != nil {
+		return nil, fmt.Errorf("error getting the rows of %s: %w", sheetName, err)
+	}
+
+	updates, appends, reports := planLongRows(sheetName, resp.Values, group, monthUptimes, precision, countLimit)
+
+	if len(updates) > 0 {
+		update := &sheets.BatchUpdateValuesRequest{Data: updates, ValueInputOption: "RAW"}
+		if _, err := srv.Spreadsheets.Values.BatchUpdate(spreadsheetID, update).Do(); err != nil {
+			return nil, fmt.Errorf("unable to update the rows of %s: %w", sheetName, err)
+		}
+	}
+
+	if len(appends) > 0 {
+		appended, err := srv.Spreadsheets.Values.Append(spreadsheetID, SheetRange(sheetName, "A1"), &sheets.ValueRange{Values: appends}).
+			ValueInputOption("RAW").
+			InsertDataOption("INSERT_ROWS").
+			Do()
+		if err != nil {
+			return nil, fmt.Errorf("unable to append rows to %s: %w", sheetName, err)
+		}
+		if appended.Updates != nil {
+			numberAppendedRows(reports, firstRowOfRange(appended.Updates.UpdatedRange))
+		}
+	}
+
+	// Appended rows don't always take on the format of the rows above them
+	uptimeColumn := int64(len(LongHeaders) - 1)
+	format := &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{{
+			RepeatCell: &sheets.RepeatCellRequest{
+				Range: &sheets.GridRange{
+					SheetId:          sheetID,
+					StartRowIndex:    1,
+					StartColumnIndex: uptimeColumn,
+					EndColumnIndex:   uptimeColumn + 1,
+				},
+				Cell:   &sheets.CellData{UserEnteredFormat: &sheets.CellFormat{NumberFormat: UptimeNumberFormat(precision)}},
+				Fields: "userEnteredFormat.numberFormat",
+			},
+		}},
+	}
+	if _, err := srv.Spreadsheets.BatchUpdate(spreadsheetID, format).Context(context.Background()).Do(); err != nil {
+		return nil, fmt.Errorf("unable to format the uptimes of %s: %w", sheetName, err)
+	}
+
+	for i := range reports {
+		reports[i].Seconds = secondsSince(started)
+	}
+	return reports, nil
+}
+
+// ensureLongSheetExists creates the tab of the long layout's table if it doesn't already exist, with a
+// frozen heading row, and returns its sheet ID
+func ensureLongSheetExists(sheetName string, sheetsData SheetsData) (int64, error) {
+	properties, err := GetSheetProperties(sheetName, sheetsData)
+	if err != nil {
+		return 0, err
+	}
+	if properties != nil {
+		return properties.SheetId, nil
+	}
+
+	srv := sheetsData.Service
+	spreadsheetID := sheetsData.SpreadsheetID
+
+	addSheet := &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{{
+			AddSheet: &sheets.AddSheetRequest{
+				Properties: &sheets.SheetProperties{
+					Title:          sheetName,
+					GridProperties: &sheets.GridProperties{FrozenRowCount: 1},
+				},
+			},
+		}},
+	}
+
+	resp, err := srv.Spreadsheets.BatchUpdate(spreadsheetID, addSheet).Context(context.Background()).Do()
+	if err != nil {
+		return 0, fmt.Errorf("unable to create new sheet %s. %s", sheetName, err)
+	}
+
+	headers := make([]any, len(LongHeaders))
+	for i, header := range LongHeaders {
+		headers[i] = header
+	}
+
+	valueRange := &sheets.ValueRange{Values: [][]any{headers}}
+	_, err = srv.Spreadsheets.Values.Update(spreadsheetID, SheetRange(sheetName, "A1"), valueRange).ValueInputOption("RAW").Do()
+	if err != nil {
+		return 0, fmt.Errorf("unable to write the headings of %s: %w", sheetName, err)
+	}
+
+	return resp.Replies[0].AddSheet.Properties.SheetId, nil
+}
+
+// longRowKey identifies a row of the long layout's table. The check is its ID, or its name if it has none.
+type longRowKey struct {
+	month string
+	group string
+	check string
+}
+
+// planLongRows returns the updates to the rows of the long layout's table that are already there, and
+// the rows to append for the rest, along with a report for each month. The existing rows are the cells
+// of columns A to D from row 2. An update rewrites the check's name, in case it has been renamed, as
+// well as its uptime. The reports of the appended rows have no row until numberAppendedRows.
+func planLongRows(
+	sheetName string,
+	existing [][]any,
+	group string,
+	monthUptimes []MonthUptimes,
+	precision, countLimit int,
+) ([]*sheets.ValueRange, [][]any, []MonthReport) {
+	cell := func(row []any, i int) string {
+		if i >= len(row) {
+			return ""
+		}
+		return fmt.Sprintf("%v", row[i])
+	}
+
+	rows := map[longRowKey]int{}
+	for i, row := range existing {
+		check := cell(row, 3)
+		if check == "" {
+			check = cell(row, 2)
+		}
+		rows[longRowKey{month: cell(row, 0), group: cell(row, 1), check: check}] = i + 2
+	}
+
+	uptimeColumn, _ := ConvertColumnIndexToLetter(int64(len(LongHeaders) - 1))
+
+	var updates []*sheets.ValueRange
+	var appends [][]any
+	var reports []MonthReport
+	for _, month := range monthUptimes {
+		monthKey := longMonth(month.Month, month.Year)
+		report := MonthReport{Sheet: sheetName, Month: month.Month + " " + month.Year}
+		for i, uptime := range month.Uptimes {
+			if i >= countLimit {
+				break
+			}
+
+			check := uptime.CheckID
+			if check == "" {
+				check = uptime.Label
+			}
+			value := roundToPrecision(uptime.Uptime, precision)
+			checkReport := CheckReport{CheckID: uptime.CheckID, Label: uptime.Label, Column: uptimeColumn, Value: value}
+
+			if uptime.Error != nil {
+				checkReport.Error = uptime.Error.Error()
+				report.Checks = append(report.Checks, checkReport)
+				continue
+			}
+
+			if row, ok := rows[longRowKey{month: monthKey, group: group, check: check}]; ok {
+				updates = append(updates, &sheets.ValueRange{
+					Range:  SheetRange(sheetName, fmt.Sprintf("C%d:E%d", row, row)),
+					Values: [][]any{{uptime.Label, uptime.CheckID, value}},
+				})
+				checkReport.Row = row
+			} else {
+				appends = append(appends, []any{monthKey, group, uptime.Label, uptime.CheckID, value})
+				checkReport.Inserted = true
+			}
+			report.Checks = append(report.Checks, checkReport)
+		}
+		reports = append(reports, report)
+	}
+
+	return updates, appends, reports
+}
+
+// numberAppendedRows fills in the rows of the checks whose rows were appended to the long layout's
+// table, in order, given the row (1-based) of the first appended row
+func numberAppendedRows(reports []MonthReport, firstRow int) {
+	if firstRow < 1 {
+		return
+	}
+
+	row := firstRow
+	for i := range reports {
+		for j := range reports[i].Checks {
+			if reports[i].Checks[j].Inserted {
+				reports[i].Checks[j].Row = row
+				row++
+			}
+		}
+	}
+}
+
+// longMonth returns the month as it is written to the long layout's table, e.g. "2024-03", which
+// sorts in calendar order
+func longMonth(month, year string) string {
+	t, err := time.Parse("January 2006", month+" "+year)
+	if err != nil {
+		return month + " " + year
+	}
+	return t.Format(nodeping.MonthLayout)
+}
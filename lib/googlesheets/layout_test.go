@@ -0,0 +1,107 @@
+package googlesheets
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/sheets/v4"
+)
+
+func TestParseLayout(t *testing.T) {
+	layout, err := ParseLayout("")
+	assert.NoError(t, err)
+	assert.Equal(t, LayoutWide, layout)
+
+	layout, err = ParseLayout(" Transposed ")
+	assert.NoError(t, err)
+	assert.Equal(t, LayoutTransposed, layout)
+
+	_, err = ParseLayout("tall")
+	assert.Error(t, err)
+}
+
+func TestTabName(t *testing.T) {
+	assert.Equal(t, "2024", TabName("", "Ops", "2024"))
+	assert.Equal(t, "Ops 2024", TabName("{group} {year}", "Ops", "2024"))
+	assert.Equal(t, "Ops SLO", TabName("{group} {year}", "Ops", SLOSheetName))
+
+	sheetsData := SheetsData{TabTemplate: "{group} {year}", Group: "Ops"}
+	assert.Equal(t, "Ops 2024 charts", ChartsSheetName(sheetsData.GetSheetName("2024")))
+
+	sheetsData.SheetName = "Custom"
+	assert.Equal(t, "Custom", sheetsData.GetSheetName("2024"), "a sheet name should override the template")
+
+	assert.NoError(t, ValidateTabTemplate(""))
+	assert.NoError(t, ValidateTabTemplate("{year} {group}"))
+	assert.Error(t, ValidateTabTemplate("{group}"))
+}
+
+func Test_findMonthRow(t *testing.T) {
+	rows := [][]any{{"January 2024"}, {"March 2024"}, {"June 2024"}}
+
+	index, insert := findMonthRow("March", "2024", rows, MonthFormat{})
+	assert.Equal(t, 1, index)
+	assert.False(t, insert)
+
+	index, insert = findMonthRow("April", "2024", rows, MonthFormat{})
+	assert.Equal(t, 2, index)
+	assert.True(t, insert)
+
+	index, insert = findMonthRow("December", "2024", rows, MonthFormat{})
+	assert.Equal(t, 3, index)
+	assert.False(t, insert)
+
+	index, insert = findMonthRow("May", "2024", [][]any{{"April 2024"}, {""}}, MonthFormat{})
+	assert.Equal(t, 1, index)
+	assert.False(t, insert)
+}
+
+func Test_planLongRows(t *testing.T) {
+	existing := [][]any{
+		{"2024-02", "Ops", "Website", "id1"},
+		{"2024-02", "Other", "Website", "id1"},
+		{"2024-03", "Ops", "Old name", "id1"},
+	}
+	months := []MonthUptimes{
+		{Month: "March", Year: "2024", Uptimes: []CheckUptime{
+			{CheckID: "id1", Label: "Website", Uptime: 99.12345},
+			{CheckID: "id2", Label: "API", Uptime: 100},
+		}},
+		{Month: "April", Year: "2024", Uptimes: []CheckUptime{
+			{CheckID: "id1", Label: "Website", Uptime: 98},
+			{CheckID: "id2", Label: "API", Uptime: 97},
+		}},
+	}
+
+	updates, appends, reports := planLongRows("Uptimes", existing, "Ops", months, 3, 1)
+	assert.Equal(t, []*sheets.ValueRange{
+		{Range: "'Uptimes'!C4:E4", Values: [][]any{{"Website", "id1", 99.123}}},
+	}, updates)
+	assert.Equal(t, [][]any{
+		{"2024-04", "Ops", "Website", "id1", 98.0},
+	}, appends, "only countLimit checks should be written per month")
+	assert.Equal(t, []MonthReport{
+		{Sheet: "Uptimes", Month: "March 2024", Checks: []CheckReport{
+			{CheckID: "id1", Label: "Website", Row: 4, Column: "E", Value: 99.123},
+		}},
+		{Sheet: "Uptimes", Month: "April 2024", Checks: []CheckReport{
+			{CheckID: "id1", Label: "Website", Column: "E", Inserted: true, Value: 98},
+		}},
+	}, reports)
+
+	numberAppendedRows(reports, 5)
+	assert.Equal(t, 4, reports[0].Checks[0].Row, "an updated row should keep its row")
+	assert.Equal(t, 5, reports[1].Checks[0].Row)
+
+	_, appends, _ = planLongRows("Uptimes", existing, "Ops", months, 3, 10)
+	assert.Len(t, appends, 3)
+
+	failed := []MonthUptimes{{Month: "April", Year: "2024", Uptimes: []CheckUptime{
+		{CheckID: "id2", Label: "API", Error: errors.New("timeout")},
+	}}}
+	updates, appends, reports = planLongRows("Uptimes", existing, "Ops", failed, 3, 10)
+	assert.Empty(t, updates)
+	assert.Empty(t, appends, "a check whose uptime couldn't be retrieved should not be written")
+	assert.Equal(t, []CheckReport{{CheckID: "id2", Label: "API", Column: "E", Error: "timeout"}}, reports[0].Checks)
+}
@@ -0,0 +1,291 @@
+package googlesheets
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/sheets/v4"
+)
+
+const (
+	// LockMetadataKey is the key of the spreadsheet's developer metadata that holds the lease of the
+	// run that is changing it
+	LockMetadataKey = "archiverLock"
+
+	DefaultLockLease = 30 * time.Minute
+
+	lockPollInterval = 15 * time.Second
+)
+
+// Lease is the value of the lock's developer metadata: who holds the lock, since when, and until when.
+// A lease that has expired is ignored, so that a run that dies without releasing the lock doesn't
+// block the later runs for more than the length of the lease. While the run is alive, its lease is
+// renewed well before it expires, however long the run takes.
+type Lease struct {
+	Owner    string    `json:"owner"`
+	Acquired time.Time `json:"acquired"`
+	Expires  time.Time `json:"expires"`
+}
+
+// Lock is a lease on a spreadsheet, so that only one run at a time inserts rows and columns into it
+type Lock struct {
+	metadataID int64
+	lease      Lease
+	sheetsData SheetsData
+
+	// Closing stop ends the renewal of the lease, which then closes renewed
+	stop    chan struct{}
+	renewed chan struct{}
+}
+
+// lockMetadata is a lease in the spreadsheet, along with the ID of its developer metadata
+type lockMetadata struct {
+	id    int64
+	lease Lease
+}
+
+// LockOwner returns a description of this process, to show in the lease, e.g. "myhost:1234"
+func LockOwner() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
+
+// AcquireLock takes out a lease on the spreadsheet for the given length of time (DefaultLockLease
+// if it is zero), which is renewed every third of that time until the lock is released. If another
+// run holds the lock, it waits up to the given time for it to be released or expire, and then fails.
+// Two runs that take out a lease at the same moment both check afterwards which one was first, and
+// the other one backs off.
+func AcquireLock(owner string, lease, wait time.Duration, sheetsData SheetsData) (*Lock, error) {
+	if lease <= 0 {
+		lease = DefaultLockLease
+	}
+	deadline := time.Now().Add(wait)
+
+	for {
+		leases, err := getLeases(sheetsData)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now().UTC()
+		holder := activeLease(leases, now)
+		if holder == nil {
+			lock, err := createLease(owner, lease, now, leases, sheetsData)
+			if err != nil {
+				return nil, err
+			}
+
+			leases, err = getLeases(sheetsData)
+			if err != nil {
+				return nil, err
+			}
+			holder = activeLease(leases, time.Now().UTC())
+			if holder != nil && holder.id == lock.metadataID {
+				slog.Info("locked spreadsheet", "spreadsheetID", sheetsData.SpreadsheetID, "until", lock.lease.Expires)
+				lock.keepRenewed(lease)
+				return lock, nil
+			}
+
+			// Another run took out a lease first
+			if err := lock.Release(); err != nil {
+				return nil, err
+			}
+		}
+
+		if holder == nil || !time.Now().Before(deadline) {
+			return nil, lockedError(holder, sheetsData.SpreadsheetID)
+		}
+
+		slog.Info("waiting for the lock on the spreadsheet", "owner", holder.lease.Owner, "until", holder.lease.Expires)
+		time.Sleep(min(lockPollInterval, time.Until(deadline)))
+	}
+}
+
+// Release ends the lease, so that another run can change the spreadsheet
+func (l *Lock) Release() error {
+	if l.stop != nil {
+		close(l.stop)
+		<-l.renewed
+		l.stop = nil
+	}
+
+	rbb := &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{newDeleteMetadataRequest(l.metadataID)},
+	}
+	_, err := l.sheetsData.Service.Spreadsheets.BatchUpdate(l.sheetsData.SpreadsheetID, rbb).Context(context.Background()).Do()
+	if err != nil {
+		return fmt.Errorf("unable to release the lock on spreadsheet %s: %w", l.sheetsData.SpreadsheetID, err)
+	}
+	return nil
+}
+
+// keepRenewed renews the lease every third of its length, in the background, until the lock is released.
+// A renewal that fails is tried again at the next one, while there's still time before the lease expires.
+func (l *Lock) keepRenewed(length time.Duration) {
+	l.stop = make(chan struct{})
+	l.renewed = make(chan struct{})
+	ticker := time.NewTicker(length / 3)
+
+	go func() {
+		defer close(l.renewed)
+		defer ticker.Stop()
+
+		lease := l.lease
+		for {
+			select {
+			case <-l.stop:
+				return
+			case now := <-ticker.C:
+				renewal := lease
+				renewal.Expires = now.UTC().Add(length)
+				if err := l.renew(renewal); err != nil {
+					slog.Warn("unable to renew the lock on the spreadsheet", "error", err, "until", lease.Expires)
+					continue
+				}
+				lease = renewal
+			}
+		}
+	}()
+}
+
+// renew replaces the lock's lease with the renewal
+func (l *Lock) renew(renewal Lease) error {
+	request, err := newLeaseUpdateRequest(l.metadataID, renewal)
+	if err != nil {
+		return err
+	}
+
+	rbb := &sheets.BatchUpdateSpreadsheetRequest{Requests: []*sheets.Request{request}}
+	_, err = l.sheetsData.Service.Spreadsheets.BatchUpdate(l.sheetsData.SpreadsheetID, rbb).Context(context.Background()).Do()
+	if err != nil {
+		return fmt.Errorf("unable to renew the lock on spreadsheet %s: %w", l.sheetsData.SpreadsheetID, err)
+	}
+	return nil
+}
+
+// newLeaseUpdateRequest returns a request that replaces the lease in the lock's developer metadata
+func newLeaseUpdateRequest(metadataID int64, lease Lease) (*sheets.Request, error) {
+	value, err := json.Marshal(lease)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode the lock: %w", err)
+	}
+
+	return &sheets.Request{
+		UpdateDeveloperMetadata: &sheets.UpdateDeveloperMetadataRequest{
+			DataFilters: []*sheets.DataFilter{{
+				DeveloperMetadataLookup: &sheets.DeveloperMetadataLookup{MetadataId: metadataID},
+			}},
+			DeveloperMetadata: &sheets.DeveloperMetadata{MetadataValue: string(value)},
+			Fields:            "metadataValue",
+		},
+	}, nil
+}
+
+// lockedError describes who holds the lock on the spreadsheet
+func lockedError(holder *lockMetadata, spreadsheetID string) error {
+	if holder == nil {
+		return fmt.Errorf("spreadsheet %s is locked by another run", spreadsheetID)
+	}
+	return fmt.Errorf("spreadsheet %s is locked by %s until %s, so another run must be changing it",
+		spreadsheetID, holder.lease.Owner, holder.lease.Expires.Format(time.RFC3339))
+}
+
+// getLeases returns the leases in the spreadsheet's developer metadata, including the expired ones
+func getLeases(sheetsData SheetsData) ([]lockMetadata, error) {
+	request := &sheets.SearchDeveloperMetadataRequest{
+		DataFilters: []*sheets.DataFilter{{
+			DeveloperMetadataLookup: &sheets.DeveloperMetadataLookup{
+				MetadataKey:  LockMetadataKey,
+				LocationType: "SPREADSHEET",
+			},
+		}},
+	}
+
+	resp, err := sheetsData.Service.Spreadsheets.DeveloperMetadata.Search(sheetsData.SpreadsheetID, request).Do()
+	if err != nil {
+		return nil, fmt.Errorf("error searching for the lock on spreadsheet %s: %w", sheetsData.SpreadsheetID, err)
+	}
+
+	var leases []lockMetadata
+	for _, match := range resp.MatchedDeveloperMetadata {
+		if match.DeveloperMetadata == nil {
+			continue
+		}
+
+		var lease Lease
+		if err := json.Unmarshal([]byte(match.DeveloperMetadata.MetadataValue), &lease); err != nil {
+			slog.Warn("ignoring an unreadable lock", "value", match.DeveloperMetadata.MetadataValue, "error", err)
+		}
+		leases = append(leases, lockMetadata{id: match.DeveloperMetadata.MetadataId, lease: lease})
+	}
+	return leases, nil
+}
+
+// activeLease returns the lease that holds the lock: of the leases that haven't expired, the one that
+// was acquired first, or the one with the lowest ID if they were acquired at the same time. It returns
+// nil if they have all expired.
+func activeLease(leases []lockMetadata, now time.Time) *lockMetadata {
+	var active []lockMetadata
+	for _, l := range leases {
+		if l.lease.Expires.After(now) {
+			active = append(active, l)
+		}
+	}
+	if len(active) == 0 {
+		return nil
+	}
+
+	first := slices.MinFunc(active, func(a, b lockMetadata) int {
+		if c := a.lease.Acquired.Compare(b.lease.Acquired); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.id, b.id)
+	})
+	return &first
+}
+
+// createLease adds a lease to the spreadsheet's developer metadata, and deletes the expired ones
+func createLease(owner string, length time.Duration, now time.Time, expired []lockMetadata, sheetsData SheetsData) (*Lock, error) {
+	lease := Lease{Owner: owner, Acquired: now, Expires: now.Add(length)}
+	value, err := json.Marshal(lease)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode the lock: %w", err)
+	}
+
+	var requests []*sheets.Request
+	for _, l := range expired {
+		requests = append(requests, newDeleteMetadataRequest(l.id))
+	}
+	requests = append(requests, &sheets.Request{
+		CreateDeveloperMetadata: &sheets.CreateDeveloperMetadataRequest{
+			DeveloperMetadata: &sheets.DeveloperMetadata{
+				MetadataKey:   LockMetadataKey,
+				MetadataValue: string(value),
+				Location:      &sheets.DeveloperMetadataLocation{Spreadsheet: true},
+				Visibility:    "DOCUMENT",
+			},
+		},
+	})
+
+	rbb := &sheets.BatchUpdateSpreadsheetRequest{Requests: requests}
+	resp, err := sheetsData.Service.Spreadsheets.BatchUpdate(sheetsData.SpreadsheetID, rbb).Context(context.Background()).Do()
+	if err != nil {
+		return nil, fmt.Errorf("unable to lock spreadsheet %s: %w", sheetsData.SpreadsheetID, err)
+	}
+
+	reply := resp.Replies[len(resp.Replies)-1]
+	if reply.CreateDeveloperMetadata == nil || reply.CreateDeveloperMetadata.DeveloperMetadata == nil {
+		return nil, fmt.Errorf("unable to lock spreadsheet %s", sheetsData.SpreadsheetID)
+	}
+
+	return &Lock{metadataID: reply.CreateDeveloperMetadata.DeveloperMetadata.MetadataId, lease: lease, sheetsData: sheetsData}, nil
+}
@@ -0,0 +1,50 @@
+package googlesheets
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_activeLease(t *testing.T) {
+	now := time.Date(2024, 3, 1, 3, 30, 0, 0, time.UTC)
+	expired := lockMetadata{id: 1, lease: Lease{Owner: "old", Acquired: now.Add(-time.Hour), Expires: now.Add(-time.Minute)}}
+	unreadable := lockMetadata{id: 2}
+	first := lockMetadata{id: 9, lease: Lease{Owner: "first", Acquired: now.Add(-time.Second), Expires: now.Add(time.Minute)}}
+	second := lockMetadata{id: 3, lease: Lease{Owner: "second", Acquired: now, Expires: now.Add(time.Minute)}}
+	tied := lockMetadata{id: 4, lease: Lease{Owner: "tied", Acquired: now.Add(-time.Second), Expires: now.Add(time.Minute)}}
+
+	assert.Nil(t, activeLease(nil, now))
+	assert.Nil(t, activeLease([]lockMetadata{expired, unreadable}, now), "expired and unreadable leases should be ignored")
+	assert.Equal(t, "first", activeLease([]lockMetadata{expired, second, first}, now).lease.Owner,
+		"the lease acquired first should hold the lock")
+	assert.Equal(t, "tied", activeLease([]lockMetadata{first, tied}, now).lease.Owner,
+		"a tie should go to the lowest ID")
+	assert.Nil(t, activeLease([]lockMetadata{first}, now.Add(time.Minute)), "a lease should expire at its expiry time")
+}
+
+func Test_lockedError(t *testing.T) {
+	holder := &lockMetadata{lease: Lease{Owner: "myhost:1234", Expires: time.Date(2024, 3, 1, 4, 0, 0, 0, time.UTC)}}
+	assert.EqualError(t, lockedError(holder, "abc"),
+		"spreadsheet abc is locked by myhost:1234 until 2024-03-01T04:00:00Z, so another run must be changing it")
+	assert.EqualError(t, lockedError(nil, "abc"), "spreadsheet abc is locked by another run")
+}
+
+func Test_newLeaseUpdateRequest(t *testing.T) {
+	lease := Lease{
+		Owner:    "myhost:1234",
+		Acquired: time.Date(2024, 3, 1, 3, 30, 0, 0, time.UTC),
+		Expires:  time.Date(2024, 3, 1, 4, 30, 0, 0, time.UTC),
+	}
+
+	request, err := newLeaseUpdateRequest(7, lease)
+	assert.NoError(t, err)
+
+	update := request.UpdateDeveloperMetadata
+	assert.Equal(t, int64(7), update.DataFilters[0].DeveloperMetadataLookup.MetadataId)
+	assert.Equal(t, "metadataValue", update.Fields, "only the lease should change, not the key or location")
+	assert.Equal(t,
+		`{"owner":"myhost:1234","acquired":"2024-03-01T03:30:00Z","expires":"2024-03-01T04:30:00Z"}`,
+		update.DeveloperMetadata.MetadataValue)
+}
@@ -0,0 +1,158 @@
+package googlesheets
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/sheets/v4"
+)
+
+// CheckIDMetadataKey is the developer metadata key that holds the NodePing check ID of a check's row.
+// Unlike the label in column A, it doesn't change when a check is renamed, and it moves with the row
+// when other rows are inserted above it.
+const CheckIDMetadataKey = "nodepingCheckID"
+
+// GetCheckRowsFromMetadata returns the NodePing check ID of every row in the sheet that has one,
+// keyed by row number (1-based, as in A1 notation)
+func GetCheckRowsFromMetadata(sheetsData SheetsData) (map[int]string, error) {
+	metadata, err := GetRowMetadata(CheckIDMetadataKey, sheetsData)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := map[int]string{}
+	for row, m := range metadata {
+		rows[row] = m.MetadataValue
+	}
+	return rows, nil
+}
+
+// GetRowMetadata returns the developer metadata with the given key on each row of the sheet,
+// keyed by row number (1-based, as in A1 notation)
+func GetRowMetadata(key string, sheetsData SheetsData) (map[int]*sheets.DeveloperMetadata, error) {
+	metadata, err := getDimensionMetadata(key, "ROW", sheetsData)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := map[int]*sheets.DeveloperMetadata{}
+	for index, m := range metadata {
+		rows[index+1] = m
+	}
+	return rows, nil
+}
+
+// GetCheckColumnsFromMetadata returns the NodePing check ID of every column in the sheet that has one,
+// keyed by (0-based) column, as in the transposed layout
+func GetCheckColumnsFromMetadata(sheetsData SheetsData) (map[int]string, error) {
+	metadata, err := getDimensionMetadata(CheckIDMetadataKey, "COLUMN", sheetsData)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[int]string{}
+	for column, m := range metadata {
+		columns[column] = m.MetadataValue
+	}
+	return columns, nil
+}
+
+// getDimensionMetadata returns the developer metadata with the given key on each row or column
+// (the locationType is "ROW" or "COLUMN") of the sheet, keyed by its 0-based index
+func getDimensionMetadata(key, locationType string, sheetsData SheetsData) (map[int]*sheets.DeveloperMetadata, error) {
+	request := &sheets.SearchDeveloperMetadataRequest{
+		DataFilters: []*sheets.DataFilter{{
+			DeveloperMetadataLookup: &sheets.DeveloperMetadataLookup{
+				MetadataKey:              key,
+				LocationType:             locationType,
+				LocationMatchingStrategy: "INTERSECTING_LOCATION",
+				MetadataLocation:         &sheets.DeveloperMetadataLocation{SheetId: sheetsData.SheetID},
+			},
+		}},
+	}
+
+	resp, err := sheetsData.Service.Spreadsheets.DeveloperMetadata.Search(sheetsData.SpreadsheetID, request).Do()
+	if err != nil {
+		return nil, fmt.Errorf("error searching for %s metadata in sheet '%d': %w", key, sheetsData.SheetID, err)
+	}
+
+	indexes := map[int]*sheets.DeveloperMetadata{}
+	for _, match := range resp.MatchedDeveloperMetadata {
+		metadata := match.DeveloperMetadata
+		if metadata == nil || metadata.Location == nil || metadata.Location.DimensionRange == nil {
+			continue
+		}
+
+		dimensionRange := metadata.Location.DimensionRange
+		if dimensionRange.SheetId != sheetsData.SheetID {
+			continue
+		}
+		indexes[int(dimensionRange.StartIndex)] = metadata
+	}
+
+	return indexes, nil
+}
+
+// SetCheckRowMetadata attaches the NodePing check ID to the row (1-based) as developer metadata
+func SetCheckRowMetadata(row int, checkID string, sheetsData SheetsData) error {
+	rbb := &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{newRowMetadataRequest(row, CheckIDMetadataKey, checkID, sheetsData.SheetID)},
+	}
+	_, err := sheetsData.Service.Spreadsheets.BatchUpdate(sheetsData.SpreadsheetID, rbb).Context(context.Background()).Do()
+	if err != nil {
+		return fmt.Errorf("unable to set check ID on row %d: %w", row, err)
+	}
+
+	return nil
+}
+
+// SetCheckColumnMetadata attaches the NodePing check ID to the (0-based) column as developer metadata
+func SetCheckColumnMetadata(column int, checkID string, sheetsData SheetsData) error {
+	rbb := &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{newDimensionMetadataRequest("COLUMNS", column, CheckIDMetadataKey, checkID, sheetsData.SheetID)},
+	}
+	_, err := sheetsData.Service.Spreadsheets.BatchUpdate(sheetsData.SpreadsheetID, rbb).Context(context.Background()).Do()
+	if err != nil {
+		return fmt.Errorf("unable to set check ID on column %d: %w", column+1, err)
+	}
+
+	return nil
+}
+
+// newRowMetadataRequest returns a request that attaches the key and value to the row (1-based)
+func newRowMetadataRequest(row int, key, value string, sheetID int64) *sheets.Request {
+	return newDimensionMetadataRequest("ROWS", row-1, key, value, sheetID)
+}
+
+// newDimensionMetadataRequest returns a request that attaches the key and value to the row or column
+// (the dimension is "ROWS" or "COLUMNS") at the 0-based index
+func newDimensionMetadataRequest(dimension string, index int, key, value string, sheetID int64) *sheets.Request {
+	return &sheets.Request{
+		CreateDeveloperMetadata: &sheets.CreateDeveloperMetadataRequest{
+			DeveloperMetadata: &sheets.DeveloperMetadata{
+				MetadataKey:   key,
+				MetadataValue: value,
+				Visibility:    "DOCUMENT",
+				Location: &sheets.DeveloperMetadataLocation{
+					DimensionRange: &sheets.DimensionRange{
+						SheetId:    sheetID,
+						Dimension:  dimension,
+						StartIndex: int64(index),
+						EndIndex:   int64(index + 1),
+					},
+				},
+			},
+		},
+	}
+}
+
+// newDeleteMetadataRequest returns a request that deletes the developer metadata with the ID
+func newDeleteMetadataRequest(metadataID int64) *sheets.Request {
+	return &sheets.Request{
+		DeleteDeveloperMetadata: &sheets.DeleteDeveloperMetadataRequest{
+			DataFilter: &sheets.DataFilter{
+				DeveloperMetadataLookup: &sheets.DeveloperMetadataLookup{MetadataId: metadataID},
+			},
+		},
+	}
+}
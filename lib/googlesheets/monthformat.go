@@ -0,0 +1,175 @@
+package googlesheets
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
+)
+
+const (
+	DefaultMonthLayout = "January 2006"
+	DefaultMonthLocale = "en"
+)
+
+// monthNames are the full and abbreviated month names of each supported locale, January first
+var monthNames = map[string]struct{ full, short [12]string }{
+	"en": {
+		full:  [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		short: [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+	},
+	"fr": {
+		full:  [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		short: [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+	},
+	"es": {
+		full:  [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		short: [12]string{"ene.", "feb.", "mar.", "abr.", "may.", "jun.", "jul.", "ago.", "sept.", "oct.", "nov.", "dic."},
+	},
+	"pt": {
+		full:  [12]string{"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro"},
+		short: [12]string{"jan.", "fev.", "mar.", "abr.", "mai.", "jun.", "jul.", "ago.", "set.", "out.", "nov.", "dez."},
+	},
+	"de": {
+		full:  [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		short: [12]string{"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni", "Juli", "Aug.", "Sept.", "Okt.", "Nov.", "Dez."},
+	},
+}
+
+// monthHeaderLayouts are the layouts that month headings are parsed with, besides the MonthFormat's
+// own, so that the headings written by earlier versions or with other settings are still understood
+var monthHeaderLayouts = []string{DefaultMonthLayout, "Jan 2006", nodeping.MonthLayout, "01/2006", "1/2006", "2006/01"}
+
+// monthWord matches a word of a month heading, along with the dot of an abbreviation
+var monthWord = regexp.MustCompile(`\p{L}+\.?`)
+
+// monthIndexes maps the lower case full and abbreviated month names of every locale, without any
+// dot, to the index of the month, January being 0
+var monthIndexes = func() map[string]int {
+	indexes := map[string]int{}
+	for _, locale := range monthNames {
+		for i := range 12 {
+			indexes[strings.ToLower(strings.TrimSuffix(locale.short[i], "."))] = i
+			indexes[strings.ToLower(locale.full[i])] = i
+		}
+	}
+	return indexes
+}()
+
+// MonthFormat is how the month headings of the wide layout's columns and the transposed layout's rows
+// are written. The zero value writes them like "March 2024". Headings are parsed in any of the supported
+// formats, so that a tab with the headings of earlier runs can still be sorted and matched.
+type MonthFormat struct {
+	Layout string // A Go time layout with the month and the year, e.g. "January 2006" or "2006-01"
+	Locale string // The language of the month names, e.g. "fr" (see MonthLocales)
+}
+
+// MonthLocales returns the supported locales of month names
+func MonthLocales() []string {
+	locales := make([]string, 0, len(monthNames))
+	for locale := range monthNames {
+		locales = append(locales, locale)
+	}
+	slices.Sort(locales)
+	return locales
+}
+
+// ParseMonthFormat checks the layout and locale of the month headings. Empty values give
+// DefaultMonthLayout and DefaultMonthLocale.
+func ParseMonthFormat(layout, locale string) (MonthFormat, error) {
+	format := MonthFormat{Layout: layout, Locale: strings.ToLower(strings.TrimSpace(locale))}
+	if _, ok := monthNames[format.locale()]; !ok {
+		return MonthFormat{}, fmt.Errorf("month locale %q must be one of %s", locale, strings.Join(MonthLocales(), ", "))
+	}
+
+	// Every month of a year must be written differently, and be read back as itself
+	for month := time.January; month <= time.December; month++ {
+		t := time.Date(2024, month, 1, 0, 0, 0, 0, time.UTC)
+		if parsed, err := format.Parse(format.Header(t)); err != nil || !parsed.Equal(t) {
+			return MonthFormat{}, fmt.Errorf("month layout %q must include the month and the year, e.g. %q", layout, DefaultMonthLayout)
+		}
+	}
+	return format, nil
+}
+
+func (f MonthFormat) layout() string {
+	if f.Layout == "" {
+		return DefaultMonthLayout
+	}
+	return f.Layout
+}
+
+func (f MonthFormat) locale() string {
+	if f.Locale == "" {
+		return DefaultMonthLocale
+	}
+	return f.Locale
+}
+
+// Header returns the heading of the month, e.g. "March 2024", or "mars 2024" in French
+func (f MonthFormat) Header(month time.Time) string {
+	header := month.Format(f.layout())
+	names, ok := monthNames[f.locale()]
+	if !ok || f.locale() == DefaultMonthLocale {
+		return header
+	}
+
+	// The layout's month is either in full ("January") or abbreviated ("Jan")
+	english, localized := monthNames[DefaultMonthLocale].full, names.full
+	if !strings.Contains(f.layout(), "January") {
+		english, localized = monthNames[DefaultMonthLocale].short, names.short
+	}
+	return monthWord.ReplaceAllStringFunc(header, func(word string) string {
+		if i := slices.Index(english[:], word); i >= 0 {
+			return localized[i]
+		}
+		return word
+	})
+}
+
+// HeaderFor returns the heading of a month given its English name and year, as they are in
+// MonthUptimes, e.g. "March" and "2024"
+func (f MonthFormat) HeaderFor(month, year string) string {
+	t, err := time.Parse(DefaultMonthLayout, month+" "+year)
+	if err != nil {
+		return month + " " + year
+	}
+	return f.Header(t)
+}
+
+// Parse returns the first day of the month of a heading. The heading may be in the format's layout or
+// any of the other supported layouts, with the month names of any of the supported locales.
+func (f MonthFormat) Parse(header string) (time.Time, error) {
+	header = strings.Join(strings.Fields(header), " ")
+
+	// The month names of other locales are put into English, in full and abbreviated
+	english := monthNames[DefaultMonthLocale]
+	values := []string{header}
+	for _, names := range [][12]string{english.full, english.short} {
+		values = append(values, monthWord.ReplaceAllStringFunc(header, func(word string) string {
+			if i, ok := monthIndexes[strings.ToLower(strings.TrimSuffix(word, "."))]; ok {
+				return names[i]
+			}
+			return word
+		}))
+	}
+
+	for _, layout := range append([]string{f.layout()}, monthHeaderLayouts...) {
+		for _, value := range values {
+			if t, err := time.Parse(layout, value); err == nil {
+				return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a month heading", header)
+}
+
+// SameMonth reports whether the heading is of the month, given its English name and year, as they
+// are in MonthUptimes
+func (f MonthFormat) SameMonth(header, month, year string) bool {
+	t, err := f.Parse(header)
+	return err == nil && t.Format(DefaultMonthLayout) == month+" "+year
+}
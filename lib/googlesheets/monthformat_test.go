@@ -0,0 +1,71 @@
+package googlesheets
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonthFormat_Header(t *testing.T) {
+	march := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, "March 2024", MonthFormat{}.Header(march))
+	assert.Equal(t, "mars 2024", MonthFormat{Locale: "fr"}.Header(march))
+	assert.Equal(t, "2024-03", MonthFormat{Layout: "2006-01", Locale: "fr"}.Header(march))
+	assert.Equal(t, "févr. 24", MonthFormat{Layout: "Jan 06", Locale: "fr"}.Header(march.AddDate(0, -1, 0)))
+	assert.Equal(t, "may. 2024", MonthFormat{Layout: "Jan 2006", Locale: "es"}.Header(march.AddDate(0, 2, 0)),
+		"an abbreviated layout should get the locale's abbreviations")
+	assert.Equal(t, "juillet 2024", MonthFormat{Locale: "fr"}.HeaderFor("July", "2024"))
+}
+
+func TestMonthFormat_Parse(t *testing.T) {
+	march := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, header := range []string{"March 2024", "Mar 2024", "mars 2024", "Mars  2024", "marzo 2024", "März 2024", "2024-03", "03/2024"} {
+		month, err := MonthFormat{}.Parse(header)
+		assert.NoError(t, err, header)
+		assert.Equal(t, march, month, header)
+	}
+
+	month, err := MonthFormat{Layout: "Jan '06", Locale: "fr"}.Parse("mars '24")
+	assert.NoError(t, err)
+	assert.Equal(t, march, month, "a custom layout should be parsed with the month names of any locale")
+
+	for _, header := range []string{"", "Year avg", "Months below SLO", "Checks", "2024"} {
+		_, err := MonthFormat{}.Parse(header)
+		assert.Error(t, err, header)
+	}
+
+	assert.True(t, MonthFormat{Locale: "fr"}.SameMonth("March 2024", "March", "2024"))
+	assert.False(t, MonthFormat{}.SameMonth("mars 2023", "March", "2024"))
+}
+
+func TestParseMonthFormat(t *testing.T) {
+	format, err := ParseMonthFormat("", " FR ")
+	assert.NoError(t, err)
+	assert.Equal(t, MonthFormat{Locale: "fr"}, format)
+
+	_, err = ParseMonthFormat("2006-01", "")
+	assert.NoError(t, err)
+
+	_, err = ParseMonthFormat("", "xx")
+	assert.Error(t, err)
+
+	_, err = ParseMonthFormat("2006", "")
+	assert.Error(t, err, "a layout without the month should be rejected")
+
+	_, err = ParseMonthFormat("January", "")
+	assert.Error(t, err, "a layout without the year should be rejected")
+}
+
+func TestGetMonthPosition(t *testing.T) {
+	for header, want := range map[string]int{"January 2024": 1, "march": 3, "août 2024": 8, "Dez. 2024": 12} {
+		position, err := GetMonthPosition(header)
+		assert.NoError(t, err, header)
+		assert.Equal(t, want, position, header)
+	}
+
+	_, err := GetMonthPosition("Year avg")
+	assert.Error(t, err)
+}
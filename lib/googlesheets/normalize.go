@@ -0,0 +1,707 @@
+package googlesheets
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/sheets/v4"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
+)
+
+// The orders that NormalizeYearTab can sort the check rows into
+const (
+	NormalizeSortLabel   = "label"   // Alphabetical order, which is the order that new checks are added in
+	NormalizeSortAverage = "average" // The lowest average uptime first
+)
+
+// NormalizeOptions holds the settings for NormalizeYearTab
+type NormalizeOptions struct {
+	SortBy string // NormalizeSortLabel or NormalizeSortAverage
+
+	// The SLOs, default SLO target and precision of the summary, which is rebuilt as at the end of a run
+	SLOs          []nodeping.SLO
+	DefaultTarget float64
+	Precision     int
+
+	// Where to save a snapshot of the tab before it is changed (none if nil), and how many to keep
+	Snapshots     Store
+	SnapshotsKept int
+}
+
+// NormalizeResult describes the repairs that NormalizeYearTab made
+type NormalizeResult struct {
+	Sheet         string   `json:"sheet"`
+	Changed       bool     `json:"changed"`
+	SortedColumns bool     `json:"sortedColumns"` // Whether the month columns were out of order
+	SortedRows    bool     `json:"sortedRows"`    // Whether the check rows were out of order
+	MergedColumns []string `json:"mergedColumns"` // The months whose duplicate columns were merged
+	MergedRows    []string `json:"mergedRows"`    // The checks whose duplicate rows were merged
+	RemovedRows   int      `json:"removedRows"`   // The number of empty rows removed
+	Conflicts     []string `json:"conflicts"`     // The cells of merged rows or columns that disagreed
+}
+
+// ParseNormalizeSort checks the order to sort the check rows into. An empty value gives NormalizeSortLabel.
+func ParseNormalizeSort(value string) (string, error) {
+	switch sortBy := strings.ToLower(strings.TrimSpace(value)); sortBy {
+	case "":
+		return NormalizeSortLabel, nil
+	case NormalizeSortLabel, NormalizeSortAverage:
+		return sortBy, nil
+	}
+	return "", fmt.Errorf(`sort order %q must be "%s" or "%s"`, value, NormalizeSortLabel, NormalizeSortAverage)
+}
+
+// NormalizeYearTab repairs a year tab of the wide layout that has been edited by hand or written by
+// older versions, so that later runs put new checks and months in the right places. It sorts the month
+// columns into calendar order and the check rows into the order of options.SortBy, merges the columns
+// of the same month and the rows of the same check, and removes the empty rows between checks. The
+// totals row and the section of retired checks stay where they are, and the rows within that section
+// are repaired in the same way. Each row and column keeps its values, formats, notes and check ID.
+// Where merged rows or columns both have a value in a cell, the first one's value is kept, and the
+// cell is listed in the result's conflicts. The references in formulas are changed to follow the rows
+// and columns they refer to, the protected months are moved with their columns, and the summary is
+// rebuilt.
+func NormalizeYearTab(options NormalizeOptions, sheetsData SheetsData) (NormalizeResult, error) {
+	sheetName := sheetsData.SheetName
+	result := NormalizeResult{Sheet: sheetName}
+
+	snapshot, err := TakeSnapshot(sheetName, time.Now().UTC(), sheetsData)
+	if err != nil {
+		return result, err
+	}
+	if snapshot == nil {
+		return result, fmt.Errorf("there is no sheet %s in spreadsheet %s", sheetName, sheetsData.SpreadsheetID)
+	}
+
+	normalized, result := normalizeSnapshot(snapshot, options.SortBy, sheetsData.MonthFormat)
+	if !result.Changed {
+		return result, nil
+	}
+
+	if options.Snapshots != nil {
+		name, err := SaveSnapshot(snapshot, options.SnapshotsKept, options.Snapshots)
+		if err != nil {
+			return result, fmt.Errorf("unable to save the snapshot of %s: %w", sheetName, err)
+		}
+		slog.Info("saved snapshot", "sheet", sheetName, "snapshot", name)
+	}
+
+	if err := writeSnapshot(normalized, sheetsData); err != nil {
+		return result, err
+	}
+
+	if headings := monthHeadings(normalized.Rows); headings != nil {
+		if err := moveProtectedMonths(headings, sheetsData); err != nil {
+			return result, err
+		}
+	}
+
+	err = UpdateSummary("", options.SLOs, options.DefaultTarget, options.Precision, sheetsData)
+	if err != nil {
+		return result, fmt.Errorf("error updating summary: %w", err)
+	}
+
+	slog.Info("normalized year tab", "sheet", sheetName)
+	return result, nil
+}
+
+// normalizeSnapshot returns a copy of the snapshot of a year tab with its month columns and check
+// rows repaired as described by NormalizeYearTab, along with what was repaired
+func normalizeSnapshot(snapshot *Snapshot, sortBy string, format MonthFormat) (*Snapshot, NormalizeResult) {
+	result := NormalizeResult{Sheet: snapshot.SheetName}
+
+	normalized := *snapshot
+	var columnIndex, rowIndex []int
+	normalized.Rows, normalized.Metadata, columnIndex = normalizeColumns(snapshot.Rows, snapshot.Metadata, format, &result)
+	normalized.Rows, normalized.Metadata, rowIndex = normalizeRows(normalized.Rows, normalized.Metadata, sortBy, &result)
+	normalized.Rows = shiftFormulas(normalized.Rows, snapshot.SheetName, rowIndex, columnIndex)
+
+	result.Changed = !reflect.DeepEqual(snapshot.Rows, normalized.Rows) || !reflect.DeepEqual(snapshot.Metadata, normalized.Metadata)
+	return &normalized, result
+}
+
+// normalizeColumns puts the month columns in calendar order after column A, merging the columns of
+// the same month (even if their headings are in different formats), and moves any other columns (such
+// as the summary columns) after them. It also returns the new index of each old column, which is that
+// of the column it was merged into, if it was.
+func normalizeColumns(rows []*sheets.RowData, metadata []SnapshotMetadata, format MonthFormat, result *NormalizeResult) ([]*sheets.RowData, []SnapshotMetadata, []int) {
+	if len(rows) < MonthHeaderRow {
+		return rows, metadata, nil
+	}
+
+	width := 0
+	for _, row := range rows {
+		if row != nil {
+			width = max(width, len(row.Values))
+		}
+	}
+
+	// The columns of each month, in their current order, and the other columns
+	monthColumns := map[time.Time][]int{}
+	var months []time.Time
+	var others []int
+	for column := 1; column < width; column++ {
+		month, err := format.Parse(cellText(rowCell(rows[MonthHeaderRow-1], column)))
+		if err != nil {
+			others = append(others, column)
+			continue
+		}
+		if _, ok := monthColumns[month]; !ok {
+			months = append(months, month)
+		}
+		monthColumns[month] = append(monthColumns[month], column)
+	}
+	if !slices.IsSortedFunc(months, time.Time.Compare) {
+		result.SortedColumns = true
+	}
+	slices.SortFunc(months, time.Time.Compare)
+
+	// The new index of each old column, or -1 if it is merged into another
+	newIndex := make([]int, width)
+	order := []int{0}
+	for _, month := range months {
+		columns := monthColumns[month]
+		for _, duplicate := range columns[1:] {
+			newIndex[duplicate] = -1
+		}
+		if len(columns) > 1 {
+			result.MergedColumns = append(result.MergedColumns, month.Format(DefaultMonthLayout))
+		}
+		newIndex[columns[0]] = len(order)
+		order = append(order, columns[0])
+	}
+	for _, column := range others {
+		newIndex[column] = len(order)
+		order = append(order, column)
+	}
+
+	columnIndex := slices.Clone(newIndex)
+	for _, columns := range monthColumns {
+		for _, duplicate := range columns[1:] {
+			columnIndex[duplicate] = newIndex[columns[0]]
+		}
+	}
+
+	newRows := make([]*sheets.RowData, len(rows))
+	for r, row := range rows {
+		if row == nil || len(row.Values) == 0 {
+			newRows[r] = row
+			continue
+		}
+
+		values := make([]*sheets.CellData, len(order))
+		for i, column := range order {
+			values[i] = rowCell(row, column)
+		}
+		for _, month := range months {
+			columns := monthColumns[month]
+			for _, duplicate := range columns[1:] {
+				merged := mergeCell(values[newIndex[columns[0]]], rowCell(row, duplicate), r >= FirstCheckRow-1)
+				if merged.conflict {
+					letter, _ := ConvertColumnIndexToLetter(int64(newIndex[columns[0]]))
+					result.Conflicts = append(result.Conflicts, fmt.Sprintf("%s%d (%s, %s): kept %s, not %s",
+						letter, r+1, cellText(rowCell(row, 0)), month.Format(DefaultMonthLayout),
+						cellText(values[newIndex[columns[0]]]), cellText(rowCell(row, duplicate))))
+				}
+				values[newIndex[columns[0]]] = merged.cell
+			}
+		}
+		for len(values) > 0 && values[len(values)-1] == nil {
+			values = values[:len(values)-1]
+		}
+
+		newRow := *row
+		newRow.Values = values
+		newRows[r] = &newRow
+	}
+
+	var newMetadata []SnapshotMetadata
+	for _, m := range metadata {
+		if m.Dimension == "COLUMNS" {
+			if m.Index >= width || newIndex[m.Index] < 0 {
+				continue
+			}
+			m.Index = newIndex[m.Index]
+		}
+		newMetadata = append(newMetadata, m)
+	}
+
+	return newRows, newMetadata, columnIndex
+}
+
+// normalizeRow is a check row, along with its old index and developer metadata
+type normalizeRow struct {
+	row      *sheets.RowData
+	index    int
+	merged   []int // The old indexes of the duplicate rows merged into it
+	metadata []SnapshotMetadata
+}
+
+// checkID returns the row's check ID, if it has one
+func (r normalizeRow) checkID() string {
+	for _, m := range r.metadata {
+		if m.Key == CheckIDMetadataKey {
+			return m.Value
+		}
+	}
+	return ""
+}
+
+// normalizeRows repairs the rows from FirstCheckRow. The rows between the totals row and the heading
+// of the retired section (see isSectionLabel) are repaired separately, and those rows stay put. It also
+// returns the new index of each old row, which is that of the row it was merged into, if it was, or -1
+// if it was removed.
+func normalizeRows(rows []*sheets.RowData, metadata []SnapshotMetadata, sortBy string, result *NormalizeResult) ([]*sheets.RowData, []SnapshotMetadata, []int) {
+	if len(rows) < FirstCheckRow {
+		return rows, metadata, nil
+	}
+
+	rowIndex := make([]int, len(rows))
+	for i := range rowIndex {
+		rowIndex[i] = -1
+	}
+
+	rowMetadata := map[int][]SnapshotMetadata{}
+	var newMetadata []SnapshotMetadata
+	for _, m := range metadata {
+		if m.Dimension == "ROWS" && m.Index >= FirstCheckRow-1 {
+			rowMetadata[m.Index] = append(rowMetadata[m.Index], m)
+		} else {
+			newMetadata = append(newMetadata, m)
+		}
+	}
+
+	newRows := slices.Clone(rows[:FirstCheckRow-1])
+	for i := range newRows {
+		rowIndex[i] = i
+	}
+	add := func(r normalizeRow) {
+		for _, old := range append([]int{r.index}, r.merged...) {
+			rowIndex[old] = len(newRows)
+		}
+		for _, m := range r.metadata {
+			m.Index = len(newRows)
+			newMetadata = append(newMetadata, m)
+		}
+		newRows = append(newRows, r.row)
+	}
+
+	var segment []normalizeRow
+	for i := FirstCheckRow - 1; i <= len(rows); i++ {
+		if i < len(rows) && !isSectionLabel(cellText(rowCell(rows[i], 0))) {
+			segment = append(segment, normalizeRow{row: rows[i], index: i, metadata: rowMetadata[i]})
+			continue
+		}
+
+		for _, r := range normalizeSegment(segment, sortBy, result) {
+			add(r)
+		}
+		segment = nil
+		if i < len(rows) {
+			add(normalizeRow{row: rows[i], index: i, metadata: rowMetadata[i]})
+		}
+	}
+
+	return newRows, newMetadata, rowIndex
+}
+
+// normalizeSegment removes the empty rows among the check rows, merges the rows of the same check,
+// and sorts them. Rows are the same check if they have the same check ID; a row without one is merged
+// into the first row with its name (ignoring case), unless both have different IDs. Rows with values
+// but no check name go last.
+func normalizeSegment(segment []normalizeRow, sortBy string, result *NormalizeResult) []normalizeRow {
+	var rows []normalizeRow
+	byID := map[string]int{}
+	byLabel := map[string]int{}
+	for _, r := range segment {
+		label := cellText(rowCell(r.row, 0))
+		if label == "" {
+			if !rowHasValues(r.row) {
+				result.RemovedRows++
+				continue
+			}
+			rows = append(rows, r)
+			continue
+		}
+
+		key := strings.ToLower(label)
+		id := r.checkID()
+		first, ok := byID[id]
+		if !ok {
+			first, ok = byLabel[key]
+			ok = ok && (id == "" || rows[first].checkID() == "")
+		}
+		if !ok {
+			if _, seen := byLabel[key]; !seen {
+				byLabel[key] = len(rows)
+			}
+			if id != "" {
+				byID[id] = len(rows)
+			}
+			rows = append(rows, r)
+			continue
+		}
+
+		rows[first] = mergeRows(rows[first], r, result)
+		if id := rows[first].checkID(); id != "" {
+			byID[id] = first
+		}
+		result.MergedRows = append(result.MergedRows, label)
+	}
+
+	sorted := slices.Clone(rows)
+	slices.SortStableFunc(sorted, func(a, b normalizeRow) int {
+		labelA, labelB := cellText(rowCell(a.row, 0)), cellText(rowCell(b.row, 0))
+		if (labelA == "") != (labelB == "") {
+			if labelA == "" {
+				return 1
+			}
+			return -1
+		}
+		if sortBy == NormalizeSortAverage {
+			if c := cmp.Compare(rowAverage(a.row), rowAverage(b.row)); c != 0 {
+				return c
+			}
+		}
+		return strings.Compare(strings.ToLower(labelA), strings.ToLower(labelB))
+	})
+
+	for i := range rows {
+		if sorted[i].index != rows[i].index {
+			result.SortedRows = true
+		}
+	}
+	return sorted
+}
+
+// mergeRows fills in the empty cells of the first row of a check from a duplicate row, and gives it
+// the duplicate's check ID if it doesn't have one
+func mergeRows(first, duplicate normalizeRow, result *NormalizeResult) normalizeRow {
+	merged := first
+	row := *first.row
+	row.Values = slices.Clone(row.Values)
+
+	for column := 1; column < len(duplicate.row.Values); column++ {
+		for len(row.Values) <= column {
+			row.Values = append(row.Values, nil)
+		}
+		m := mergeCell(row.Values[column], duplicate.row.Values[column], true)
+		if m.conflict {
+			letter, _ := ConvertColumnIndexToLetter(int64(column))
+			result.Conflicts = append(result.Conflicts, fmt.Sprintf("%s%d and %s%d (%s): kept %s, not %s",
+				letter, first.index+1, letter, duplicate.index+1, cellText(rowCell(first.row, 0)),
+				cellText(row.Values[column]), cellText(duplicate.row.Values[column])))
+		}
+		row.Values[column] = m.cell
+	}
+	merged.row = &row
+	merged.merged = append(append(slices.Clone(first.merged), duplicate.index), duplicate.merged...)
+
+	if first.checkID() == "" {
+		for _, m := range duplicate.metadata {
+			if m.Key == CheckIDMetadataKey {
+				merged.metadata = append(slices.Clone(first.metadata), m)
+			}
+		}
+	}
+	return merged
+}
+
+// mergedCell is the result of merging two cells
+type mergedCell struct {
+	cell     *sheets.CellData
+	conflict bool // Whether both cells had different values, in which case the first is kept
+}
+
+// mergeCell merges a cell with the same cell of a duplicate row or column. Formulas, such as those of
+// the summary, aren't reported as conflicts, since they are rewritten anyway. Nor are the cells that
+// aren't checked, such as the headings.
+func mergeCell(first, duplicate *sheets.CellData, checked bool) mergedCell {
+	if !cellHasValue(duplicate) {
+		return mergedCell{cell: first}
+	}
+	if !cellHasValue(first) {
+		return mergedCell{cell: duplicate}
+	}
+
+	isFormula := first.UserEnteredValue.FormulaValue != nil || duplicate.UserEnteredValue.FormulaValue != nil
+	conflict := checked && !isFormula && cellText(first) != cellText(duplicate)
+	return mergedCell{cell: first, conflict: conflict}
+}
+
+// cellReference matches an A1 reference in a formula, with the tab it is on, if it says: a cell like "B3"
+// or "$B$3", a range of cells like "B3:D3", or a range of columns like "B:D". Its groups are the tab, and
+// the column and row of each end.
+var cellReference = regexp.MustCompile(`(?:('(?:[^']|'')+'|[A-Za-z_][A-Za-z0-9_.]*)!)?(\$?[A-Z]{1,3})(\$?[0-9]+)?(?::(\$?[A-Z]{1,3})(\$?[0-9]+)?)?`)
+
+// shiftFormulas changes the references of the formulas in the rows of the tab to the new places of the
+// rows and columns they refer to, given the new (0-based) index of each old row and column. The cells
+// with formulas are copied, rather than changed.
+func shiftFormulas(rows []*sheets.RowData, sheetName string, rowIndex, columnIndex []int) []*sheets.RowData {
+	newRows := slices.Clone(rows)
+	for r, row := range newRows {
+		if row == nil {
+			continue
+		}
+
+		for column, cell := range row.Values {
+			if !cellHasValue(cell) || cell.UserEnteredValue.FormulaValue == nil {
+				continue
+			}
+			formula := shiftFormula(*cell.UserEnteredValue.FormulaValue, sheetName, rowIndex, columnIndex)
+			if formula == *cell.UserEnteredValue.FormulaValue {
+				continue
+			}
+
+			if newRows[r] == rows[r] {
+				newRow := *row
+				newRow.Values = slices.Clone(row.Values)
+				newRows[r] = &newRow
+			}
+			newCell := *cell
+			newCell.UserEnteredValue = &sheets.ExtendedValue{FormulaValue: &formula}
+			newRows[r].Values[column] = &newCell
+		}
+	}
+	return newRows
+}
+
+// shiftFormula changes the references of a formula to the new places of the rows and columns they refer
+// to, as Sheets does when rows and columns are moved. A range refers to the rows and columns that are
+// left of those it covered, and a reference to rows or columns that were all removed becomes #REF!.
+// References to other tabs and text in quotes are left alone.
+func shiftFormula(formula, sheetName string, rowIndex, columnIndex []int) string {
+	// The parts in double quotes are text, and every other part is outside them
+	parts := strings.Split(formula, `"`)
+	for i := 0; i < len(parts); i += 2 {
+		part := parts[i]
+		var b strings.Builder
+		last := 0
+		for _, match := range cellReference.FindAllStringSubmatchIndex(part, -1) {
+			start, end := match[0], match[1]
+			group := func(n int) string {
+				if match[2*n] < 0 {
+					return ""
+				}
+				return part[match[2*n]:match[2*n+1]]
+			}
+
+			// Skip the names of functions, tabs and the like, which only look like references
+			if start > 0 && (isWordByte(part[start-1]) || strings.ContainsRune("$.'!:", rune(part[start-1]))) ||
+				end < len(part) && (isWordByte(part[end]) || strings.ContainsRune("(.!:", rune(part[end]))) {
+				continue
+			}
+
+			tab, column1, row1, column2, row2 := group(1), group(2), group(3), group(4), group(5)
+			if tab != "" && unquoteSheetName(tab) != sheetName {
+				continue
+			}
+
+			// A cell, a range of cells or a range of columns, and not a word like TRUE or half of each
+			cells := row1 != "" && (column2 == "") == (row2 == "")
+			columns := row1 == "" && column2 != "" && row2 == ""
+			if !cells && !columns {
+				continue
+			}
+
+			shifted, ok := shiftReference(column1, row1, column2, row2, rowIndex, columnIndex)
+			if !ok {
+				shifted = "#REF!"
+			} else if tab != "" {
+				shifted = tab + "!" + shifted
+			}
+
+			b.WriteString(part[last:start])
+			b.WriteString(shifted)
+			last = end
+		}
+		b.WriteString(part[last:])
+		parts[i] = b.String()
+	}
+	return strings.Join(parts, `"`)
+}
+
+// shiftReference returns a reference, whose second end is optional, with its rows and columns at
+// their new places, or false if they were all removed
+func shiftReference(column1, row1, column2, row2 string, rowIndex, columnIndex []int) (string, bool) {
+	single := column2 == ""
+	if single {
+		column2, row2 = column1, row1
+	}
+
+	firstColumn, lastColumn, ok := shiftRange(referenceIndex(column1), referenceIndex(column2), columnIndex)
+	if !ok {
+		return "", false
+	}
+	reference := func(column, row string, newColumn, newRow int) string {
+		letter, _ := ConvertColumnIndexToLetter(int64(newColumn))
+		text := dollar(column) + letter
+		if row != "" {
+			text += dollar(row) + strconv.Itoa(newRow+1)
+		}
+		return text
+	}
+
+	var firstRow, lastRow int
+	if row1 != "" {
+		firstRow, lastRow, ok = shiftRange(referenceIndex(row1), referenceIndex(row2), rowIndex)
+		if !ok {
+			return "", false
+		}
+	}
+
+	first := reference(column1, row1, firstColumn, firstRow)
+	if single && firstColumn == lastColumn && firstRow == lastRow {
+		return first, true
+	}
+	return first + ":" + reference(column2, row2, lastColumn, lastRow), true
+}
+
+// shiftRange returns the new first and last index of the old indexes from first to last, or false if
+// they were all removed. Indexes past the end of newIndex stay where they are.
+func shiftRange(first, last int, newIndex []int) (int, int, bool) {
+	lo, hi := -1, -1
+	for i := first; i <= last; i++ {
+		index := i
+		if i < len(newIndex) {
+			index = newIndex[i]
+		}
+		if index < 0 {
+			continue
+		}
+		if lo < 0 || index < lo {
+			lo = index
+		}
+		hi = max(hi, index)
+	}
+	return lo, hi, lo >= 0
+}
+
+// referenceIndex returns the 0-based index of the column letters or row number of a reference,
+// ignoring any "$"
+func referenceIndex(part string) int {
+	part = strings.TrimPrefix(part, "$")
+	if row, err := strconv.Atoi(part); err == nil {
+		return row - 1
+	}
+
+	index := 0
+	for _, c := range part {
+		index = index*26 + int(c-'A'+1)
+	}
+	return index - 1
+}
+
+// dollar returns "$" if the part of a reference is absolute
+func dollar(part string) string {
+	if strings.HasPrefix(part, "$") {
+		return "$"
+	}
+	return ""
+}
+
+// isWordByte reports whether the byte is a letter, digit or underscore
+func isWordByte(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_'
+}
+
+// unquoteSheetName returns the name of a tab as it is written in a formula, without its quotes
+func unquoteSheetName(name string) string {
+	if strings.HasPrefix(name, "'") && strings.HasSuffix(name, "'") && len(name) >= 2 {
+		return strings.ReplaceAll(name[1:len(name)-1], "''", "'")
+	}
+	return name
+}
+
+// rowCell returns the cell of the row at the (0-based) column, or nil if there isn't one
+func rowCell(row *sheets.RowData, column int) *sheets.CellData {
+	if row == nil || column >= len(row.Values) {
+		return nil
+	}
+	return row.Values[column]
+}
+
+// cellHasValue reports whether the cell has a value, rather than just a format or a note
+func cellHasValue(cell *sheets.CellData) bool {
+	return cell != nil && cell.UserEnteredValue != nil
+}
+
+// cellText returns the value of the cell as text, or "" if it has none
+func cellText(cell *sheets.CellData) string {
+	if !cellHasValue(cell) {
+		return ""
+	}
+
+	value := cell.UserEnteredValue
+	switch {
+	case value.StringValue != nil:
+		return strings.TrimSpace(*value.StringValue)
+	case value.NumberValue != nil:
+		return strconv.FormatFloat(*value.NumberValue, 'f', -1, 64)
+	case value.BoolValue != nil:
+		return strconv.FormatBool(*value.BoolValue)
+	case value.FormulaValue != nil:
+		return *value.FormulaValue
+	}
+	return ""
+}
+
+// rowHasValues reports whether any of the row's cells has a value
+func rowHasValues(row *sheets.RowData) bool {
+	return row != nil && slices.ContainsFunc(row.Values, cellHasValue)
+}
+
+// rowAverage returns the average of the numbers in the row after column A, or +Inf if there are none,
+// so that the rows without any uptimes go last when sorting by average
+func rowAverage(row *sheets.RowData) float64 {
+	sum, count := 0.0, 0
+	for column := 1; column < len(row.Values); column++ {
+		cell := row.Values[column]
+		if cellHasValue(cell) && cell.UserEnteredValue.NumberValue != nil {
+			sum += *cell.UserEnteredValue.NumberValue
+			count++
+		}
+	}
+	if count == 0 {
+		return math.Inf(1)
+	}
+	return sum / float64(count)
+}
+
+// String returns the result as text
+func (r NormalizeResult) String() string {
+	if !r.Changed {
+		return fmt.Sprintf("%q is already in order\n", r.Sheet)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Normalized %q:\n", r.Sheet)
+	if r.SortedColumns {
+		b.WriteString("  sorted the month columns\n")
+	}
+	if r.SortedRows {
+		b.WriteString("  sorted the check rows\n")
+	}
+	for _, month := range r.MergedColumns {
+		fmt.Fprintf(&b, "  merged the columns of %s\n", month)
+	}
+	for _, label := range r.MergedRows {
+		fmt.Fprintf(&b, "  merged a duplicate row of %s\n", label)
+	}
+	if r.RemovedRows > 0 {
+		fmt.Fprintf(&b, "  removed %d empty rows\n", r.RemovedRows)
+	}
+	for _, conflict := range r.Conflicts {
+		fmt.Fprintf(&b, "  conflict at %s\n", conflict)
+	}
+	return b.String()
+}
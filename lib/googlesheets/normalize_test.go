@@ -0,0 +1,200 @@
+package googlesheets
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/sheets/v4"
+)
+
+// testRow returns a row of cells, where a string is a text cell (or a formula, if it starts with "="),
+// a float64 a number cell and nil an empty cell
+func testRow(values ...any) *sheets.RowData {
+	row := &sheets.RowData{}
+	for _, value := range values {
+		switch v := value.(type) {
+		case string:
+			if strings.HasPrefix(v, "=") {
+				row.Values = append(row.Values, &sheets.CellData{UserEnteredValue: &sheets.ExtendedValue{FormulaValue: &v}})
+				continue
+			}
+			row.Values = append(row.Values, &sheets.CellData{UserEnteredValue: &sheets.ExtendedValue{StringValue: &v}})
+		case float64:
+			row.Values = append(row.Values, &sheets.CellData{UserEnteredValue: &sheets.ExtendedValue{NumberValue: &v}})
+		default:
+			row.Values = append(row.Values, nil)
+		}
+	}
+	return row
+}
+
+// rowTexts returns the values of the snapshot's rows as text
+func rowTexts(rows []*sheets.RowData) [][]string {
+	var texts [][]string
+	for _, row := range rows {
+		var text []string
+		if row != nil {
+			for _, cell := range row.Values {
+				text = append(text, cellText(cell))
+			}
+		}
+		texts = append(texts, text)
+	}
+	return texts
+}
+
+func Test_normalizeSnapshot(t *testing.T) {
+	snapshot := &Snapshot{
+		SheetName: "2024",
+		Rows: []*sheets.RowData{
+			testRow("Uptimes"),
+			testRow("", "March 2024", "January 2024", "March 2024", "Year avg"),
+			testRow("web", 99.5, 99.0, nil, "=AVERAGE(B3:D3)"),
+			nil,
+			testRow("API", nil, 98.0, 97.0, "=AVERAGE(B5:D5)"),
+			testRow("Web", 99.6, nil, 99.1, "=AVERAGE(B6:D6)"),
+			testRow(SummaryTotalsLabel, "=AVERAGE(B3:B6)"),
+			testRow(RetiredSectionLabel),
+			testRow("old", 90.0),
+		},
+		Metadata: []SnapshotMetadata{
+			{Key: CheckIDMetadataKey, Value: "api", Dimension: "ROWS", Index: 4},
+			{Key: CheckIDMetadataKey, Value: "web", Dimension: "ROWS", Index: 5},
+			{Key: RetiredMetadataKey, Value: "2024-02-01", Dimension: "ROWS", Index: 8},
+		},
+	}
+
+	normalized, result := normalizeSnapshot(snapshot, NormalizeSortLabel, MonthFormat{})
+
+	assert.Equal(t, [][]string{
+		{"Uptimes"},
+		{"", "January 2024", "March 2024", "Year avg"},
+		{"API", "98", "97", "=AVERAGE(B3:C3)"},
+		{"web", "99", "99.5", "=AVERAGE(B4:C4)"},
+		{SummaryTotalsLabel, "", "=AVERAGE(C3:C4)"},
+		{RetiredSectionLabel},
+		{"old", "", "90"},
+	}, rowTexts(normalized.Rows), "the formulas should refer to the new places of their rows and columns")
+
+	assert.Equal(t, []SnapshotMetadata{
+		{Key: CheckIDMetadataKey, Value: "api", Dimension: "ROWS", Index: 2},
+		{Key: CheckIDMetadataKey, Value: "web", Dimension: "ROWS", Index: 3},
+		{Key: RetiredMetadataKey, Value: "2024-02-01", Dimension: "ROWS", Index: 6},
+	}, normalized.Metadata, "the metadata should move with its rows, and a merged row should take the duplicate's check ID")
+
+	assert.True(t, result.Changed)
+	assert.True(t, result.SortedColumns)
+	assert.True(t, result.SortedRows)
+	assert.Equal(t, []string{"March 2024"}, result.MergedColumns)
+	assert.Equal(t, []string{"Web"}, result.MergedRows)
+	assert.Equal(t, 1, result.RemovedRows)
+	assert.Len(t, result.Conflicts, 2, "web's and Web's March uptimes differ, as do Web's two March columns")
+
+	again, result := normalizeSnapshot(normalized, NormalizeSortLabel, MonthFormat{})
+	assert.False(t, result.Changed, "a normalized tab should be left alone")
+	assert.Equal(t, normalized.Rows, again.Rows)
+}
+
+func Test_normalizeSnapshotByAverage(t *testing.T) {
+	snapshot := &Snapshot{
+		SheetName: "2024",
+		Rows: []*sheets.RowData{
+			testRow("Uptimes"),
+			testRow("", "January 2024", "February 2024"),
+			testRow("a", 99.0, 100.0),
+			testRow("b", 98.0, 99.0),
+			testRow("c"),
+			testRow("", 97.0),
+		},
+	}
+
+	normalized, result := normalizeSnapshot(snapshot, NormalizeSortAverage, MonthFormat{})
+
+	assert.Equal(t, [][]string{
+		{"Uptimes"},
+		{"", "January 2024", "February 2024"},
+		{"b", "98", "99"},
+		{"a", "99", "100"},
+		{"c"},
+		{"", "97"},
+	}, rowTexts(normalized.Rows), "checks without uptimes, and then rows without names, should go last")
+	assert.False(t, result.SortedColumns)
+	assert.True(t, result.SortedRows)
+	assert.Empty(t, result.MergedRows)
+}
+
+func Test_shiftFormula(t *testing.T) {
+	// Rows 3 and 4 swap places, row 5 is removed and row 6 is merged into row 3. Columns B and C swap.
+	rowIndex := []int{0, 1, 3, 2, -1, 3}
+	columnIndex := []int{0, 2, 1}
+
+	tests := map[string]string{
+		"=B3":                               "=C4",
+		"=$B$4*2":                           "=$C$3*2",
+		"=AVERAGE(B3:C6)":                   "=AVERAGE(B3:C4)",
+		"=SUM(B:B)":                         "=SUM(C:C)",
+		"=B5":                               "=#REF!",
+		"=B9+D3":                            "=C9+D4",
+		"='2024'!B3+'Ops 2024'!B3":          "='2024'!C4+'Ops 2024'!B3",
+		`=IF(B3>99,"B3 is fine",LOG10(B4))`: `=IF(C4>99,"B3 is fine",LOG10(C3))`,
+		"=TRUE":                             "=TRUE",
+	}
+	for formula, want := range tests {
+		assert.Equal(t, want, shiftFormula(formula, "2024", rowIndex, columnIndex), formula)
+	}
+
+	assert.Equal(t, "=AVERAGE(B3:C3)", shiftFormula("=AVERAGE(B3:C3)", "2024", nil, nil), "nothing moved")
+}
+
+func Test_normalizeSegmentKeepsDifferentChecks(t *testing.T) {
+	segment := []normalizeRow{
+		{row: testRow("web", 99.0), index: 2, metadata: []SnapshotMetadata{{Key: CheckIDMetadataKey, Value: "one", Dimension: "ROWS", Index: 2}}},
+		{row: testRow("web", 98.0), index: 3, metadata: []SnapshotMetadata{{Key: CheckIDMetadataKey, Value: "two", Dimension: "ROWS", Index: 3}}},
+	}
+
+	var result NormalizeResult
+	rows := normalizeSegment(segment, NormalizeSortLabel, &result)
+
+	assert.Len(t, rows, 2, "rows with the same name but different check IDs should not be merged")
+	assert.Empty(t, result.MergedRows)
+	assert.Empty(t, result.Conflicts)
+}
+
+func Test_normalizeSegmentMergesByCheckID(t *testing.T) {
+	checkID := func(id string, index int) []SnapshotMetadata {
+		return []SnapshotMetadata{{Key: CheckIDMetadataKey, Value: id, Dimension: "ROWS", Index: index}}
+	}
+	segment := []normalizeRow{
+		{row: testRow("web", 99.0), index: 2, metadata: checkID("one", 2)},
+		{row: testRow("web", 98.0), index: 3, metadata: checkID("two", 3)},
+		{row: testRow("web", nil, 97.0), index: 4, metadata: checkID("two", 4)},
+		{row: testRow("Web", nil, nil, 96.0), index: 5},
+	}
+
+	var result NormalizeResult
+	rows := normalizeSegment(segment, NormalizeSortLabel, &result)
+
+	if assert.Len(t, rows, 2, "the second check's duplicate row should be merged into its first row") {
+		assert.Equal(t, "one", rows[0].checkID())
+		assert.Equal(t, []int{5}, rows[0].merged, "a row without an ID should be merged by its name")
+		assert.Equal(t, "two", rows[1].checkID())
+		assert.Equal(t, []int{4}, rows[1].merged)
+		assert.Equal(t, "97", cellText(rowCell(rows[1].row, 2)))
+	}
+	assert.Equal(t, []string{"web", "Web"}, result.MergedRows)
+	assert.Empty(t, result.Conflicts)
+}
+
+func TestParseNormalizeSort(t *testing.T) {
+	sortBy, err := ParseNormalizeSort("")
+	assert.NoError(t, err)
+	assert.Equal(t, NormalizeSortLabel, sortBy)
+
+	sortBy, err = ParseNormalizeSort(" Average ")
+	assert.NoError(t, err)
+	assert.Equal(t, NormalizeSortAverage, sortBy)
+
+	_, err = ParseNormalizeSort("id")
+	assert.Error(t, err)
+}
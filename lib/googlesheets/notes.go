@@ -0,0 +1,123 @@
+package googlesheets
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/sheets/v4"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
+)
+
+// WriteUptimeWithNotes is like WriteUptimeToCell, but also puts notes on the cells: the check's ID,
+// type, target and interval on its name, and the down time, enabled time and coverage on the uptime.
+// The coverage is the share of the month's span that the check was enabled for.
+func WriteUptimeWithNotes(
+	rowIndex, columnIndex int64,
+	uptime CheckUptime,
+	check nodeping.Check,
+	span time.Duration,
+	precision int,
+	sheetsData SheetsData,
+) error {
+	return writeUptimeWithNotes(rowIndex, columnIndex, int(rowIndex), 0, uptime, check, span, precision, sheetsData)
+}
+
+// writeUptimeWithNotes is like WriteUptimeWithNotes, with the cell of the check's name given by its
+// row (1-based) and column (0-based), since it isn't in column A of the transposed layout
+func writeUptimeWithNotes(
+	rowIndex, columnIndex int64,
+	labelRow, labelColumn int,
+	uptime CheckUptime,
+	check nodeping.Check,
+	span time.Duration,
+	precision int,
+	sheetsData SheetsData,
+) error {
+	uptimeRequest := newUptimeCellRequest(rowIndex, columnIndex, uptime.Uptime, precision, sheetsData.SheetID)
+	uptimeRequest.UpdateCells.Rows[0].Values[0].Note = uptimeNote(uptime.Response, span)
+	uptimeRequest.UpdateCells.Fields += ",note"
+
+	rbb := &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{
+			uptimeRequest,
+			newCellNoteRequest(labelRow, labelColumn, checkNote(check), sheetsData.SheetID),
+		},
+	}
+	_, err := sheetsData.Service.Spreadsheets.BatchUpdate(sheetsData.SpreadsheetID, rbb).Context(context.Background()).Do()
+	if err != nil {
+		return fmt.Errorf("unable to write uptime and notes to row %d, column %d: %w", rowIndex, columnIndex+1, err)
+	}
+
+	return nil
+}
+
+// checkNote returns the note on a check's name, which describes the NodePing check
+func checkNote(check nodeping.Check) string {
+	lines := []string{"Check ID: " + check.ID}
+	if check.Type != "" {
+		lines = append(lines, "Type: "+check.Type)
+	}
+	if check.Target != "" {
+		lines = append(lines, "Target: "+check.Target)
+	}
+	if check.Interval > 0 {
+		lines = append(lines, fmt.Sprintf("Interval: %d min", check.Interval))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// uptimeNote returns the note on an uptime cell, with NodePing's down and enabled time in minutes,
+// and the coverage of the span. There is no coverage if the span is unknown.
+func uptimeNote(response nodeping.UptimeResponse, span time.Duration) string {
+	enabled := time.Duration(response.Enabled) * time.Millisecond
+	down := time.Duration(response.Down) * time.Millisecond
+
+	lines := []string{
+		"Down: " + formatMinutes(down),
+		"Enabled: " + formatMinutes(enabled),
+	}
+	if span > 0 {
+		coverage := min(100*enabled.Seconds()/span.Seconds(), 100)
+		lines = append(lines, fmt.Sprintf("Coverage: %s%%", FormatUptime(coverage, 1)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatMinutes formats a duration as minutes, with a decimal place if it isn't a whole number of minutes
+func formatMinutes(d time.Duration) string {
+	if d%time.Minute == 0 {
+		return fmt.Sprintf("%d min", int64(d/time.Minute))
+	}
+	return fmt.Sprintf("%.1f min", d.Minutes())
+}
+
+// monthSpan returns the part of the month that is within the period and not in the future, which is
+// the time a check could have been enabled for
+func monthSpan(month, year string, period nodeping.Period, now time.Time) time.Duration {
+	start, err := time.Parse("January 2006", month+" "+year)
+	if err != nil {
+		return 0
+	}
+
+	from := start
+	if period.From.After(from) {
+		from = period.From
+	}
+
+	// The period ends at the last second of its last day
+	to := start.AddDate(0, 1, 0)
+	if end := period.To.Add(time.Second); end.Before(to) {
+		to = end
+	}
+	if now.Before(to) {
+		to = now
+	}
+
+	if !to.After(from) {
+		return 0
+	}
+	return to.Sub(from)
+}
@@ -0,0 +1,38 @@
+package googlesheets
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
+)
+
+func TestCheckNote(t *testing.T) {
+	check := nodeping.Check{ID: "id1", Label: "Website", Type: "HTTP", Target: "https://example.org", Interval: 5}
+	assert.Equal(t, "Check ID: id1\nType: HTTP\nTarget: https://example.org\nInterval: 5 min", checkNote(check))
+
+	assert.Equal(t, "Check ID: id2", checkNote(nodeping.Check{ID: "id2"}), "unknown details should be left out")
+}
+
+func TestUptimeNote(t *testing.T) {
+	day := 24 * time.Hour
+	response := nodeping.UptimeResponse{Enabled: (15 * day).Milliseconds(), Down: 90_000}
+
+	assert.Equal(t, "Down: 1.5 min\nEnabled: 21600 min\nCoverage: 50.0%", uptimeNote(response, 30*day))
+	assert.Equal(t, "Down: 1.5 min\nEnabled: 21600 min", uptimeNote(response, 0))
+	assert.Equal(t, "Down: 1.5 min\nEnabled: 21600 min\nCoverage: 100.0%", uptimeNote(response, 10*day),
+		"the coverage should be at most 100%")
+}
+
+func TestMonthSpan(t *testing.T) {
+	lastMonth := nodeping.GetLastMonthPeriod(time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC))
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, 29*24*time.Hour, monthSpan("February", "2024", lastMonth, now))
+
+	thisMonth := nodeping.GetThisMonthPeriod(now)
+	assert.Equal(t, (14*24+12)*time.Hour, monthSpan("March", "2024", thisMonth, now), "the future shouldn't count")
+
+	assert.Equal(t, time.Duration(0), monthSpan("April", "2024", thisMonth, now))
+}
@@ -0,0 +1,343 @@
+package googlesheets
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/sheets/v4"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
+)
+
+const (
+	protectedMonthPrefix = "Archived month: "
+	correctionDateLayout = "2006-01-02"
+)
+
+// ProtectedMonthDescription returns the description of the protected range over a month column,
+// which is how the range is found again. The month is always in English, like "March 2024", whatever
+// the MonthFormat of its heading, so that the range can still be found if the format changes.
+func ProtectedMonthDescription(monthHeader string) string {
+	return protectedMonthPrefix + monthHeader
+}
+
+// MonthHeader returns the heading of the month column for a month like "2024-03", i.e. "March 2024",
+// along with the year
+func MonthHeader(month string) (string, string, error) {
+	t, err := time.Parse(nodeping.MonthLayout, month)
+	if err != nil {
+		return "", "", fmt.Errorf("month %q must be like %q: %w", month, nodeping.MonthLayout, err)
+	}
+	return t.Format("January 2006"), t.Format("2006"), nil
+}
+
+// monthHasEnded reports whether the whole month is in the past, so that its column is archived
+func monthHasEnded(month, year string, now time.Time) bool {
+	start, err := time.Parse("January 2006", month+" "+year)
+	if err != nil {
+		return false
+	}
+	return !start.AddDate(0, 1, 0).After(now)
+}
+
+// ProtectMonthColumn adds a protected range over the (0-based) month column, unless it already has one,
+// so that only the editors can change it. The editors should include the service account, so that
+// later runs can still write to the column.
+func ProtectMonthColumn(monthHeader string, column int, editors []Grantee, sheetsData SheetsData) error {
+	existing, err := findProtectedMonth(monthHeader, sheetsData)
+	if err != nil || existing != nil {
+		return err
+	}
+
+	request := sheets.Request{
+		AddProtectedRange: &sheets.AddProtectedRangeRequest{
+			ProtectedRange: newProtectedMonth(monthHeader, column, editors, sheetsData.SheetID),
+		},
+	}
+
+	rbb := &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{&request},
+	}
+	_, err = sheetsData.Service.Spreadsheets.BatchUpdate(sheetsData.SpreadsheetID, rbb).Context(context.Background()).Do()
+	if err != nil {
+		return fmt.Errorf("unable to protect the column for %s: %w", monthHeader, err)
+	}
+
+	return nil
+}
+
+// newProtectedMonth returns a protected range over the (0-based) month column, from the month heading down
+func newProtectedMonth(monthHeader string, column int, editors []Grantee, sheetID int64) *sheets.ProtectedRange {
+	var users, groups []string
+	for _, editor := range editors {
+		if editor.Type == GranteeGroup {
+			groups = append(groups, editor.Email)
+		} else {
+			users = append(users, editor.Email)
+		}
+	}
+
+	return &sheets.ProtectedRange{
+		Description: ProtectedMonthDescription(monthHeader),
+		Range: &sheets.GridRange{
+			SheetId:          sheetID,
+			StartRowIndex:    MonthHeaderRow - 1,
+			StartColumnIndex: int64(column),
+			EndColumnIndex:   int64(column + 1),
+		},
+		Editors: &sheets.Editors{Users: users, Groups: groups},
+	}
+}
+
+// findProtectedMonth returns the protected range over the month column, or nil if there isn't one
+func findProtectedMonth(monthHeader string, sheetsData SheetsData) (*sheets.ProtectedRange, error) {
+	resp, err := sheetsData.Service.Spreadsheets.Get(sheetsData.SpreadsheetID).
+		Fields("sheets(properties.sheetId,protectedRanges(protectedRangeId,description))").Do()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the protected ranges of sheet '%d': %w", sheetsData.SheetID, err)
+	}
+
+	description := ProtectedMonthDescription(monthHeader)
+	for _, sheet := range resp.Sheets {
+		if sheet.Properties == nil || sheet.Properties.SheetId != sheetsData.SheetID {
+			continue
+		}
+		for _, protectedRange := range sheet.ProtectedRanges {
+			if protectedRange.Description == description {
+				return protectedRange, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// UnprotectMonthColumn removes the protection from a month column, so that it can be corrected by hand,
+// and records who did it and why in a note on the month heading
+func UnprotectMonthColumn(monthHeader, by, reason string, now time.Time, sheetsData SheetsData) error {
+	protectedRange, err := findProtectedMonth(monthHeader, sheetsData)
+	if err != nil {
+		return err
+	}
+	if protectedRange == nil {
+		return fmt.Errorf("the column for %s is not protected", monthHeader)
+	}
+
+	column, err := findMonthColumn(monthHeader, sheetsData)
+	if err != nil {
+		return err
+	}
+
+	note := fmt.Sprintf("Unprotected by %s on %s: %s", by, now.Format(correctionDateLayout), reason)
+	requests := []*sheets.Request{
+		{DeleteProtectedRange: &sheets.DeleteProtectedRangeRequest{ProtectedRangeId: protectedRange.ProtectedRangeId}},
+		newCellNoteRequest(MonthHeaderRow, column, note, sheetsData.SheetID),
+	}
+
+	rbb := &sheets.BatchUpdateSpreadsheetRequest{Requests: requests}
+	_, err = sheetsData.Service.Spreadsheets.BatchUpdate(sheetsData.SpreadsheetID, rbb).Context(context.Background()).Do()
+	if err != nil {
+		return fmt.Errorf("unable to unprotect the column for %s: %w", monthHeader, err)
+	}
+
+	slog.Info("unprotected month column", "month", monthHeader, "by", by, "reason", reason)
+	return nil
+}
+
+// CorrectUptime overwrites a check's uptime for a month, even if the month column is protected, and
+// records who did it, why and what the value was in a note on the cell. The check is given by its
+// NodePing ID or its name in column A (see findCorrectionRow).
+func CorrectUptime(monthHeader, check string, uptime float64, precision int, by, reason string, now time.Time, sheetsData SheetsData) error {
+	sheetName := sheetsData.SheetName
+
+	column, err := findMonthColumn(monthHeader, sheetsData)
+	if err != nil {
+		return err
+	}
+
+	properties, err := GetGridProperties(sheetName, sheetsData)
+	if err != nil {
+		return err
+	}
+
+	rows, err := getCheckNames(sheetName, properties, sheetsData)
+	if err != nil {
+		return err
+	}
+
+	checkRows, err := GetCheckRowsFromMetadata(sheetsData)
+	if err != nil {
+		return err
+	}
+
+	row, err := findCorrectionRow(check, rows, checkRows)
+	if err != nil {
+		return fmt.Errorf("unable to correct %s: %w", sheetName, err)
+	}
+
+	letter, err := ConvertColumnIndexToLetter(int64(column))
+	if err != nil {
+		return err
+	}
+
+	cell := SheetRange(sheetName, fmt.Sprintf("%s%d", letter, row))
+	resp, err := sheetsData.Service.Spreadsheets.Values.Get(sheetsData.SpreadsheetID, cell).Do()
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %w", cell, err)
+	}
+	previous := ""
+	if len(resp.Values) > 0 && len(resp.Values[0]) > 0 {
+		previous = fmt.Sprintf("%v", resp.Values[0][0])
+	}
+
+	if err := WriteUptimeToCell(int64(row), int64(column), uptime, precision, sheetsData); err != nil {
+		return err
+	}
+
+	note := fmt.Sprintf("Corrected by %s on %s: %s", by, now.Format(correctionDateLayout), reason)
+	if previous != "" {
+		note += fmt.Sprintf(" (was %s)", previous)
+	}
+
+	rbb := &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{newCellNoteRequest(row, column, note, sheetsData.SheetID)},
+	}
+	_, err = sheetsData.Service.Spreadsheets.BatchUpdate(sheetsData.SpreadsheetID, rbb).Context(context.Background()).Do()
+	if err != nil {
+		return fmt.Errorf("unable to add a note to %s: %w", cell, err)
+	}
+
+	slog.Info("corrected uptime", "month", monthHeader, "check", check, "uptime", uptime, "by", by, "reason", reason)
+	return nil
+}
+
+// findCorrectionRow returns the row (1-based) of the check, given the check names from FirstCheckRow and
+// the check IDs of the rows (see GetCheckRowsFromMetadata). The check is looked up by its ID first, and
+// then by its name, which has to be on only one row, since checks can share a name.
+func findCorrectionRow(check string, rows [][]any, checkRows map[int]string) (int, error) {
+	var byID []int
+	for row, id := range checkRows {
+		if id == check {
+			byID = append(byID, row)
+		}
+	}
+	if len(byID) > 1 {
+		return 0, fmt.Errorf("there is more than one row for check ID %q", check)
+	}
+	if len(byID) == 1 {
+		return byID[0], nil
+	}
+
+	row := 0
+	for i := range rows {
+		if !strings.EqualFold(cellLabel(rows, i), check) {
+			continue
+		}
+		if row > 0 {
+			return 0, fmt.Errorf("there is more than one row for check %q, so it must be given by its check ID", check)
+		}
+		row = FirstCheckRow + i
+	}
+	if row == 0 {
+		return 0, fmt.Errorf("there is no row with the check ID or name %q", check)
+	}
+	return row, nil
+}
+
+// findMonthColumn returns the (0-based) column with the heading of the month, which is given in English,
+// like "March 2024"
+func findMonthColumn(monthHeader string, sheetsData SheetsData) (int, error) {
+	properties, err := GetGridProperties(sheetsData.SheetName, sheetsData)
+	if err != nil {
+		return 0, err
+	}
+
+	headers, err := getMonthHeaders(sheetsData.SheetName, properties, sheetsData)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, header := range headers {
+		if month, err := sheetsData.MonthFormat.Parse(fmt.Sprintf("%v", header)); err == nil && month.Format(DefaultMonthLayout) == monthHeader {
+			return i + 1, nil
+		}
+	}
+
+	return 0, fmt.Errorf("there is no column for %s in %s", monthHeader, sheetsData.SheetName)
+}
+
+// newCellNoteRequest returns a request that sets the note on a cell, given its row (1-based) and
+// column (0-based). An empty note removes it.
+func newCellNoteRequest(row, column int, note string, sheetID int64) *sheets.Request {
+	return &sheets.Request{
+		UpdateCells: &sheets.UpdateCellsRequest{
+			Start:  &sheets.GridCoordinate{SheetId: sheetID, RowIndex: int64(row - 1), ColumnIndex: int64(column)},
+			Rows:   []*sheets.RowData{{Values: []*sheets.CellData{{Note: note}}}},
+			Fields: "note",
+		},
+	}
+}
+
+// moveProtectedMonths moves the protected range of each month to the column with its heading, given the
+// cells of the month heading row from column A, after the columns have been moved. A month without a
+// column loses its protection.
+func moveProtectedMonths(headers []any, sheetsData SheetsData) error {
+	resp, err := sheetsData.Service.Spreadsheets.Get(sheetsData.SpreadsheetID).
+		Fields("sheets(properties.sheetId,protectedRanges)").Do()
+	if err != nil {
+		return fmt.Errorf("unable to get the protected ranges of sheet '%d': %w", sheetsData.SheetID, err)
+	}
+
+	var requests []*sheets.Request
+	for _, sheet := range resp.Sheets {
+		if sheet.Properties == nil || sheet.Properties.SheetId != sheetsData.SheetID {
+			continue
+		}
+		for _, protectedRange := range sheet.ProtectedRanges {
+			monthHeader, ok := strings.CutPrefix(protectedRange.Description, protectedMonthPrefix)
+			if !ok || protectedRange.Range == nil {
+				continue
+			}
+
+			column := slices.IndexFunc(headers, func(header any) bool {
+				month, err := sheetsData.MonthFormat.Parse(fmt.Sprintf("%v", header))
+				return err == nil && month.Format(DefaultMonthLayout) == monthHeader
+			})
+			if column < 1 {
+				requests = append(requests, &sheets.Request{
+					DeleteProtectedRange: &sheets.DeleteProtectedRangeRequest{ProtectedRangeId: protectedRange.ProtectedRangeId},
+				})
+				continue
+			}
+			if protectedRange.Range.StartColumnIndex == int64(column) {
+				continue
+			}
+
+			protectedRange.Range.StartColumnIndex = int64(column)
+			protectedRange.Range.EndColumnIndex = int64(column + 1)
+			requests = append(requests, &sheets.Request{
+				UpdateProtectedRange: &sheets.UpdateProtectedRangeRequest{
+					ProtectedRange: &sheets.ProtectedRange{
+						ProtectedRangeId: protectedRange.ProtectedRangeId,
+						Range:            protectedRange.Range,
+					},
+					Fields: "range",
+				},
+			})
+		}
+	}
+	if len(requests) == 0 {
+		return nil
+	}
+
+	rbb := &sheets.BatchUpdateSpreadsheetRequest{Requests: requests}
+	_, err = sheetsData.Service.Spreadsheets.BatchUpdate(sheetsData.SpreadsheetID, rbb).Context(context.Background()).Do()
+	if err != nil {
+		return fmt.Errorf("unable to move the protected months of %s: %w", sheetsData.SheetName, err)
+	}
+	return nil
+}
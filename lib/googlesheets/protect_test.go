@@ -0,0 +1,72 @@
+package googlesheets
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonthHeader(t *testing.T) {
+	header, year, err := MonthHeader("2024-03")
+	assert.NoError(t, err)
+	assert.Equal(t, "March 2024", header)
+	assert.Equal(t, "2024", year)
+
+	_, _, err = MonthHeader("March 2024")
+	assert.Error(t, err)
+}
+
+func Test_monthHasEnded(t *testing.T) {
+	now := time.Date(2024, 4, 15, 0, 0, 0, 0, time.UTC)
+	assert.True(t, monthHasEnded("March", "2024", now))
+	assert.False(t, monthHasEnded("April", "2024", now))
+	assert.True(t, monthHasEnded("April", "2024", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, monthHasEnded("Smarch", "2024", now))
+}
+
+func Test_newProtectedMonth(t *testing.T) {
+	editors := []Grantee{
+		{Type: GranteeUser, Email: "archiver@example.iam.gserviceaccount.com"},
+		{Type: GranteeGroup, Email: "admins@example.org"},
+	}
+
+	got := newProtectedMonth("March 2024", 3, editors, 7)
+	assert.Equal(t, "Archived month: March 2024", got.Description)
+	assert.Equal(t, int64(7), got.Range.SheetId)
+	assert.Equal(t, int64(1), got.Range.StartRowIndex)
+	assert.Equal(t, int64(3), got.Range.StartColumnIndex)
+	assert.Equal(t, int64(4), got.Range.EndColumnIndex)
+	assert.Equal(t, []string{"archiver@example.iam.gserviceaccount.com"}, got.Editors.Users)
+	assert.Equal(t, []string{"admins@example.org"}, got.Editors.Groups)
+}
+
+func Test_findCorrectionRow(t *testing.T) {
+	rows := [][]any{{"API"}, {"Website"}, {"website"}, {"Old"}, {SummaryTotalsLabel}, {RetiredSectionLabel}, {"Gone"}}
+	checkRows := map[int]string{3: "api-id", 4: "web-id", 5: "web2-id", 9: "gone-id"}
+
+	row, err := findCorrectionRow("web2-id", rows, checkRows)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, row, "checks that share a name should be told apart by their IDs")
+
+	row, err = findCorrectionRow("gone-id", rows, checkRows)
+	assert.NoError(t, err)
+	assert.Equal(t, 9, row, "a retired check should be found by its ID")
+
+	row, err = findCorrectionRow("old", rows, checkRows)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, row, "a row without an ID should be found by its name")
+
+	row, err = findCorrectionRow("api", rows, checkRows)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, row, "a row with an ID should also be found by a name that no other row has")
+
+	_, err = findCorrectionRow("Website", rows, checkRows)
+	assert.EqualError(t, err, `there is more than one row for check "Website", so it must be given by its check ID`)
+
+	_, err = findCorrectionRow("New", rows, checkRows)
+	assert.EqualError(t, err, `there is no row with the check ID or name "New"`)
+
+	_, err = findCorrectionRow("api-id", rows, map[int]string{3: "api-id", 8: "api-id"})
+	assert.EqualError(t, err, `there is more than one row for check ID "api-id"`)
+}
@@ -0,0 +1,120 @@
+package googlesheets
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/sheets/v4"
+)
+
+// ProvisionalMetadataKey is the developer metadata key that marks the column of a month that hadn't
+// ended when it was last written, whose uptimes may still change. Its value is the date it was written.
+const ProvisionalMetadataKey = "nodepingProvisional"
+
+const provisionalDateLayout = "2006-01-02"
+
+// MarkMonthProvisional marks the (0-based) month column of the tab as provisional: the uptimes of its
+// checks are in italics, its heading has a note saying when they were last updated, and it has the
+// ProvisionalMetadataKey, which FinalizeMonthColumn looks for
+func MarkMonthProvisional(sheetName string, column int, now time.Time, sheetsData SheetsData) error {
+	metadata, err := getDimensionMetadata(ProvisionalMetadataKey, "COLUMN", sheetsData)
+	if err != nil {
+		return err
+	}
+
+	checkCount, err := countCheckRows(sheetName, sheetsData)
+	if err != nil {
+		return err
+	}
+
+	var requests []*sheets.Request
+	if m, ok := metadata[column]; ok {
+		requests = append(requests, newDeleteMetadataRequest(m.MetadataId))
+	}
+
+	updated := now.Format(provisionalDateLayout)
+	note := fmt.Sprintf("Provisional: updated %s. The uptimes will be final once the month has ended and been archived again.", updated)
+	requests = append(requests,
+		newDimensionMetadataRequest("COLUMNS", column, ProvisionalMetadataKey, updated, sheetsData.SheetID),
+		newItalicColumnRequest(column, checkCount, true, sheetsData.SheetID),
+		newCellNoteRequest(MonthHeaderRow, column, note, sheetsData.SheetID),
+	)
+
+	rbb := &sheets.BatchUpdateSpreadsheetRequest{Requests: requests}
+	_, err = sheetsData.Service.Spreadsheets.BatchUpdate(sheetsData.SpreadsheetID, rbb).Context(context.Background()).Do()
+	if err != nil {
+		return fmt.Errorf("unable to mark column %d as provisional: %w", column+1, err)
+	}
+	return nil
+}
+
+// FinalizeMonthColumn removes the marking of MarkMonthProvisional from the (0-based) month column of
+// the tab, if it has it, and reports whether it did
+func FinalizeMonthColumn(sheetName string, column int, sheetsData SheetsData) (bool, error) {
+	metadata, err := getDimensionMetadata(ProvisionalMetadataKey, "COLUMN", sheetsData)
+	if err != nil {
+		return false, err
+	}
+
+	m, ok := metadata[column]
+	if !ok {
+		return false, nil
+	}
+
+	checkCount, err := countCheckRows(sheetName, sheetsData)
+	if err != nil {
+		return false, err
+	}
+
+	requests := []*sheets.Request{
+		newDeleteMetadataRequest(m.MetadataId),
+		newItalicColumnRequest(column, checkCount, false, sheetsData.SheetID),
+		newCellNoteRequest(MonthHeaderRow, column, "", sheetsData.SheetID),
+	}
+
+	rbb := &sheets.BatchUpdateSpreadsheetRequest{Requests: requests}
+	_, err = sheetsData.Service.Spreadsheets.BatchUpdate(sheetsData.SpreadsheetID, rbb).Context(context.Background()).Do()
+	if err != nil {
+		return false, fmt.Errorf("unable to finalize column %d: %w", column+1, err)
+	}
+	return true, nil
+}
+
+// countCheckRows returns the number of rows of the tab's current checks, from FirstCheckRow down to the
+// totals row or the section of retired checks
+func countCheckRows(sheetName string, sheetsData SheetsData) (int, error) {
+	properties, err := GetGridProperties(sheetName, sheetsData)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := getCheckNames(sheetName, properties, sheetsData)
+	if err != nil {
+		return 0, err
+	}
+	return findChecksEnd(rows), nil
+}
+
+// newItalicColumnRequest returns a request that puts the uptimes of the (0-based) column, in the given
+// number of check rows from FirstCheckRow, in italics or back out of them. The rows below the checks,
+// such as the totals and the retired checks, keep their own formatting.
+func newItalicColumnRequest(column, checkCount int, italic bool, sheetID int64) *sheets.Request {
+	return &sheets.Request{
+		RepeatCell: &sheets.RepeatCellRequest{
+			Range: &sheets.GridRange{
+				SheetId:          sheetID,
+				StartRowIndex:    FirstCheckRow - 1,
+				EndRowIndex:      int64(FirstCheckRow - 1 + checkCount),
+				StartColumnIndex: int64(column),
+				EndColumnIndex:   int64(column + 1),
+			},
+			Cell: &sheets.CellData{
+				UserEnteredFormat: &sheets.CellFormat{
+					TextFormat: &sheets.TextFormat{Italic: italic, ForceSendFields: []string{"Italic"}},
+				},
+			},
+			Fields: "userEnteredFormat.textFormat.italic",
+		},
+	}
+}
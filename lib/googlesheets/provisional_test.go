@@ -0,0 +1,24 @@
+package googlesheets
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_newItalicColumnRequest(t *testing.T) {
+	request := newItalicColumnRequest(3, 4, false, 7).RepeatCell
+
+	assert.Equal(t, int64(7), request.Range.SheetId)
+	assert.Equal(t, int64(FirstCheckRow-1), request.Range.StartRowIndex)
+	assert.Equal(t, int64(FirstCheckRow+3), request.Range.EndRowIndex,
+		"the range should end with the last check, above the totals row")
+	assert.Equal(t, []int64{3, 4}, []int64{request.Range.StartColumnIndex, request.Range.EndColumnIndex})
+	assert.Equal(t, "userEnteredFormat.textFormat.italic", request.Fields)
+
+	data, err := json.Marshal(request.Cell)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"userEnteredFormat": {"textFormat": {"italic": false}}}`, string(data),
+		"italic should be sent even when it is false, so that it is turned off")
+}
@@ -0,0 +1,141 @@
+package googlesheets
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RunReport describes what a run of ArchiveResultsForMonth did, month by month and check by check
+type RunReport struct {
+	SpreadsheetID string        `json:"spreadsheetId"`
+	ContactGroup  string        `json:"contactGroup"`
+	Period        string        `json:"period"`
+	Layout        string        `json:"layout"`
+	StartedAt     time.Time     `json:"startedAt"`
+	Seconds       float64       `json:"seconds"`      // How long the whole run took
+	FetchSeconds  float64       `json:"fetchSeconds"` // How long it took to get the uptimes from NodePing
+	Months        []MonthReport `json:"months"`
+}
+
+// MonthReport describes the writing of one month's uptimes
+type MonthReport struct {
+	Sheet   string        `json:"sheet"`
+	Month   string        `json:"month"`            // e.g. "March 2024"
+	Column  string        `json:"column,omitempty"` // The letter of the month's column in the wide layout
+	Row     int           `json:"row,omitempty"`    // The month's row in the transposed layout
+	Seconds float64       `json:"seconds"`
+	Checks  []CheckReport `json:"checks"`
+
+	Provisional bool `json:"provisional,omitempty"` // Whether the month's column was marked provisional
+	Finalized   bool `json:"finalized,omitempty"`   // Whether the month's column was provisional and is now final
+}
+
+// CheckReport describes the writing of one check's uptime for a month
+type CheckReport struct {
+	CheckID  string  `json:"checkId,omitempty"`
+	Label    string  `json:"label"`
+	Row      int     `json:"row,omitempty"`      // The row (1-based) written to
+	Column   string  `json:"column,omitempty"`   // The letter of the column written to
+	Inserted bool    `json:"inserted,omitempty"` // Whether a row (a column in the transposed layout) was added for the check
+	Resumed  bool    `json:"resumed,omitempty"`  // Whether it was skipped, having been written by the run that was resumed
+	Value    float64 `json:"value"`              // The uptime written, rounded to the precision
+	Error    string  `json:"error,omitempty"`
+}
+
+// Failed returns the number of checks that could not be written, out of the total
+func (r RunReport) Failed() (int, int) {
+	failed, total := 0, 0
+	for _, month := range r.Months {
+		for _, check := range month.Checks {
+			total++
+			if check.Error != "" {
+				failed++
+			}
+		}
+	}
+	return failed, total
+}
+
+// hasFailures reports whether any of the month's checks could not be written
+func (m MonthReport) hasFailures() bool {
+	return slices.ContainsFunc(m.Checks, func(check CheckReport) bool { return check.Error != "" })
+}
+
+// Err returns an error if any of the checks could not be written, or else nil
+func (r RunReport) Err() error {
+	failed, total := r.Failed()
+	if failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d checks could not be archived", failed, total)
+}
+
+// String returns the report as text, with a line for each month and each of its checks
+func (r RunReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Archived %s uptimes for contact group %q to spreadsheet %s in %.1fs (%.1fs getting them from NodePing)\n",
+		r.Period, r.ContactGroup, r.SpreadsheetID, r.Seconds, r.FetchSeconds)
+
+	for _, month := range r.Months {
+		place := fmt.Sprintf("%q", month.Sheet)
+		if month.Column != "" {
+			place += " column " + month.Column
+		}
+		if month.Row > 0 {
+			place += fmt.Sprintf(" row %d", month.Row)
+		}
+		if month.Provisional {
+			place += " (provisional)"
+		}
+		if month.Finalized {
+			place += " (finalized)"
+		}
+		fmt.Fprintf(&b, "%s: %s in %.1fs\n", month.Month, place, month.Seconds)
+
+		for _, check := range month.Checks {
+			if check.Error != "" {
+				fmt.Fprintf(&b, "  FAILED %s: %s\n", check.Label, check.Error)
+				continue
+			}
+			if check.Resumed {
+				fmt.Fprintf(&b, "  %-8s %s (written before the run was resumed)\n", "skipped", check.Label)
+				continue
+			}
+
+			cell := fmt.Sprintf("%s%d", check.Column, check.Row)
+			if check.Column == "" {
+				cell = fmt.Sprintf("row %d", check.Row)
+			}
+			line := fmt.Sprintf("  %-8s %s: %s", cell, check.Label, strconv.FormatFloat(check.Value, 'f', -1, 64))
+			if check.Inserted {
+				line += " (added)"
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+
+	failed, total := r.Failed()
+	fmt.Fprintf(&b, "%d of %d checks written, %d failed\n", total-failed, total, failed)
+	return b.String()
+}
+
+// firstRowOfRange returns the first row (1-based) of a range like "'2024'!A5:E7", or 0 if it has none
+func firstRowOfRange(a1Range string) int {
+	if i := strings.LastIndex(a1Range, "!"); i >= 0 {
+		a1Range = a1Range[i+1:]
+	}
+	start, _, _ := strings.Cut(a1Range, ":")
+	row, err := strconv.Atoi(strings.TrimLeft(start, "ABCDEFGHIJKLMNOPQRSTUVWXYZ$"))
+	if err != nil {
+		return 0
+	}
+	return row
+}
+
+// secondsSince returns the time since start in seconds, to the millisecond
+func secondsSince(start time.Time) float64 {
+	return time.Since(start).Round(time.Millisecond).Seconds()
+}
@@ -0,0 +1,65 @@
+package googlesheets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunReport_Err(t *testing.T) {
+	report := RunReport{Months: []MonthReport{
+		{Month: "March 2024", Checks: []CheckReport{{Label: "Website"}, {Label: "API"}}},
+		{Month: "April 2024", Checks: []CheckReport{{Label: "Website"}}},
+	}}
+	assert.NoError(t, report.Err())
+
+	report.Months[1].Checks[0].Error = "error adding row for 'Website': quota exceeded"
+	failed, total := report.Failed()
+	assert.Equal(t, 1, failed)
+	assert.Equal(t, 3, total)
+	assert.EqualError(t, report.Err(), "1 of 3 checks could not be archived")
+	assert.False(t, report.Months[0].hasFailures())
+	assert.True(t, report.Months[1].hasFailures())
+}
+
+func TestRunReport_String(t *testing.T) {
+	report := RunReport{
+		SpreadsheetID: "abc",
+		ContactGroup:  "Ops",
+		Period:        "LastMonth",
+		Seconds:       12.34,
+		FetchSeconds:  1.5,
+		Months: []MonthReport{{
+			Sheet:   "2024",
+			Month:   "March 2024",
+			Column:  "C",
+			Seconds: 8.1,
+			Checks: []CheckReport{
+				{Label: "API", Row: 3, Column: "C", Inserted: true, Value: 100},
+				{Label: "Website", Row: 4, Column: "C", Value: 99.95},
+				{Label: "Mail", Error: "error adding row for 'Mail': quota exceeded"},
+				{Label: "Wiki", Column: "C", Resumed: true, Value: 99},
+			},
+		}},
+	}
+
+	want := `Archived LastMonth uptimes for contact group "Ops" to spreadsheet abc in 12.3s (1.5s getting them from NodePing)
+March 2024: "2024" column C in 8.1s
+  C3       API: 100 (added)
+  C4       Website: 99.95
+  FAILED Mail: error adding row for 'Mail': quota exceeded
+  skipped  Wiki (written before the run was resumed)
+3 of 4 checks written, 1 failed
+`
+	assert.Equal(t, want, report.String())
+
+	report.Months[0].Provisional = true
+	assert.Contains(t, report.String(), `March 2024: "2024" column C (provisional) in 8.1s`)
+}
+
+func Test_firstRowOfRange(t *testing.T) {
+	assert.Equal(t, 5, firstRowOfRange("'Uptimes'!A5:E7"))
+	assert.Equal(t, 12, firstRowOfRange("Uptimes!A12"))
+	assert.Equal(t, 0, firstRowOfRange("'Uptimes'!A:E"))
+	assert.Equal(t, 0, firstRowOfRange(""))
+}
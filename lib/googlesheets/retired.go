@@ -0,0 +1,353 @@
+package googlesheets
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/sheets/v4"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
+)
+
+// The ways of marking the rows of checks that are no longer in the contact group
+const (
+	RetiredNone = "none" // Leave the rows as they are
+	RetiredGrey = "grey" // Grey out the rows
+	RetiredNote = "note" // Add a "Retired on <date>" note to the check name
+	RetiredMove = "move" // Move the rows to a "Retired" section at the bottom of the tab
+)
+
+const (
+	// RetiredMetadataKey is the developer metadata key that marks a retired check's row. Its value is
+	// the date on which the check was found to be retired.
+	RetiredMetadataKey = "nodepingRetired"
+
+	// RetiredSectionLabel is the check name cell that heads the section of retired checks
+	RetiredSectionLabel = "Retired"
+
+	retiredDateLayout = "2006-01-02"
+
+	// retiredNotePrefix starts the line that RetiredNote adds to the note on a check's name
+	retiredNotePrefix = "Retired on "
+)
+
+var (
+	retiredBackground = &sheets.Color{Red: 0.93, Green: 0.93, Blue: 0.93}
+	retiredText       = &sheets.Color{Red: 0.6, Green: 0.6, Blue: 0.6}
+)
+
+// ParseRetiredTreatment validates the way of marking retired checks, which defaults to RetiredNone
+func ParseRetiredTreatment(value string) (string, error) {
+	treatment := strings.ToLower(value)
+	switch treatment {
+	case "":
+		return RetiredNone, nil
+	case RetiredNone, RetiredGrey, RetiredNote, RetiredMove:
+		return treatment, nil
+	}
+
+	return "", fmt.Errorf(`retired treatment %q must be one of "%s", "%s", "%s" or "%s"`,
+		value, RetiredNone, RetiredGrey, RetiredNote, RetiredMove)
+}
+
+// MarkRetiredChecks compares the check rows of the year tab with the checks that are currently in
+// the contact group, and marks the rows of the checks that have left the group (or been deleted)
+// in the way given by the treatment. A row is matched by its check ID or, if it doesn't have one,
+// by its check name. If a retired check comes back, its marking is removed and, if it had been
+// moved, it is moved back among the other checks.
+func MarkRetiredChecks(checks []nodeping.Check, treatment, year string, now time.Time, sheetsData SheetsData) error {
+	if treatment == "" || treatment == RetiredNone {
+		return nil
+	}
+
+	// Don't retire everything just because NodePing didn't return any checks
+	if len(checks) == 0 {
+		slog.Warn("no checks in the contact group, so no rows have been marked as retired")
+		return nil
+	}
+
+	sheetName := sheetsData.GetSheetName(year)
+	srv := sheetsData.Service
+	spreadsheetID := sheetsData.SpreadsheetID
+
+	properties, err := GetGridProperties(sheetName, sheetsData)
+	if err != nil {
+		return err
+	}
+
+	rows, err := getCheckNames(sheetName, properties, sheetsData)
+	if err != nil {
+		return err
+	}
+
+	checkRows, err := GetCheckRowsFromMetadata(sheetsData)
+	if err != nil {
+		return err
+	}
+
+	retiredRows, err := GetRowMetadata(RetiredMetadataKey, sheetsData)
+	if err != nil {
+		return err
+	}
+
+	notes, err := getCheckNotes(sheetName, properties, sheetsData)
+	if err != nil {
+		return err
+	}
+
+	// A section heading may need to be added below the last row
+	if treatment == RetiredMove {
+		if err := EnsureRowCount(int64(FirstCheckRow+len(rows)), properties, spreadsheetID, srv); err != nil {
+			return err
+		}
+	}
+
+	requests := planRetiredRows(rows, checkRows, retiredRows, notes, checks, treatment, now.Format(retiredDateLayout), sheetsData.SheetID)
+	if len(requests) == 0 {
+		return nil
+	}
+
+	rbb := &sheets.BatchUpdateSpreadsheetRequest{Requests: requests}
+	if _, err := srv.Spreadsheets.BatchUpdate(spreadsheetID, rbb).Context(context.Background()).Do(); err != nil {
+		return fmt.Errorf("unable to mark retired checks in %s: %w", sheetName, err)
+	}
+
+	return nil
+}
+
+// getCheckNotes returns the notes on the check names, from FirstCheckRow to the end of the sheet's grid,
+// keyed by row (1-based)
+func getCheckNotes(sheetName string, properties *sheets.SheetProperties, sheetsData SheetsData) (map[int]string, error) {
+	rowCount := max(properties.GridProperties.RowCount, FirstCheckRow)
+	checksRange := SheetRange(sheetName, fmt.Sprintf("A%d:A%d", FirstCheckRow, rowCount))
+	resp, err := sheetsData.Service.Spreadsheets.Get(sheetsData.SpreadsheetID).
+		Ranges(checksRange).
+		IncludeGridData(true).
+		Fields("sheets(data(rowData(values(note))))").
+		Do()
+	if err != nil {
+		return nil, fmt.Errorf("error getting the notes on the NodePing Check names from %s: %w", sheetName, err)
+	}
+
+	notes := map[int]string{}
+	for _, sheet := range resp.Sheets {
+		for _, data := range sheet.Data {
+			for i, row := range data.RowData {
+				if len(row.Values) > 0 && row.Values[0] != nil && row.Values[0].Note != "" {
+					notes[FirstCheckRow+i] = row.Values[0].Note
+				}
+			}
+		}
+	}
+	return notes, nil
+}
+
+// addRetiredNote returns the note on a check's name with a "Retired on <date>" line added, so that
+// the note with the check's details (see checkNote) is kept
+func addRetiredNote(note, date string) string {
+	if note == "" {
+		return retiredNotePrefix + date
+	}
+	return note + "\n" + retiredNotePrefix + date
+}
+
+// removeRetiredNote returns the note on a check's name without the line added by addRetiredNote,
+// and whether it had one
+func removeRetiredNote(note string) (string, bool) {
+	lines := strings.Split(note, "\n")
+	kept := slices.DeleteFunc(slices.Clone(lines), func(line string) bool {
+		return strings.HasPrefix(line, retiredNotePrefix)
+	})
+	return strings.Join(kept, "\n"), len(kept) < len(lines)
+}
+
+// findRetiredSection returns the index of the row that heads the section of retired checks,
+// or -1 if there isn't one
+func findRetiredSection(rows [][]any) int {
+	for i := range rows {
+		if cellLabel(rows, i) == RetiredSectionLabel {
+			return i
+		}
+	}
+	return -1
+}
+
+// planRetiredRows returns the requests that mark the rows of checks that are no longer current,
+// and unmark those of checks that have come back. The rows are the check name column, starting
+// at FirstCheckRow. The checkRows, retiredRows and the notes on the check names are keyed by row
+// number (1-based).
+func planRetiredRows(
+	rows [][]any,
+	checkRows map[int]string,
+	retiredRows map[int]*sheets.DeveloperMetadata,
+	notes map[int]string,
+	checks []nodeping.Check,
+	treatment, date string,
+	sheetID int64,
+) []*sheets.Request {
+	currentIDs := map[string]bool{}
+	currentLabels := map[string]bool{}
+	for _, check := range checks {
+		currentIDs[check.ID] = true
+		currentLabels[strings.ToLower(check.Label)] = true
+	}
+
+	section := findRetiredSection(rows)
+
+	var requests []*sheets.Request
+	var toMove, toRestore []int
+	for i := range rows {
+		label := cellLabel(rows, i)
+		if label == "" || isSectionLabel(label) {
+			continue
+		}
+
+		row := FirstCheckRow + i
+		current := currentLabels[strings.ToLower(label)]
+		if checkID, ok := checkRows[row]; ok {
+			current = currentIDs[checkID]
+		}
+		marked := retiredRows[row]
+
+		switch {
+		case !current && marked == nil:
+			slog.Info("marking retired NodePing check", "row", row, "check", label)
+			requests = append(requests, newRowMetadataRequest(row, RetiredMetadataKey, date, sheetID))
+
+			switch treatment {
+			case RetiredGrey:
+				requests = append(requests, newRowFormatRequest(row, &sheets.CellData{
+					UserEnteredFormat: &sheets.CellFormat{
+						BackgroundColor: retiredBackground,
+						TextFormat:      &sheets.TextFormat{ForegroundColor: retiredText},
+					},
+				}, sheetID))
+			case RetiredNote:
+				requests = append(requests, newCellNoteRequest(row, 0, addRetiredNote(notes[row], date), sheetID))
+			case RetiredMove:
+				if section < 0 || i < section {
+					toMove = append(toMove, i)
+				}
+			}
+
+		case current && marked != nil:
+			slog.Info("unmarking NodePing check that has come back", "row", row, "check", label)
+			requests = append(requests,
+				newDeleteMetadataRequest(marked.MetadataId),
+				newRowFormatRequest(row, &sheets.CellData{}, sheetID),
+			)
+			if note, ok := removeRetiredNote(notes[row]); ok {
+				requests = append(requests, newCellNoteRequest(row, 0, note, sheetID))
+			}
+
+			if section >= 0 && i > section {
+				toRestore = append(toRestore, i)
+			}
+		}
+	}
+
+	if len(toMove) == 0 && len(toRestore) == 0 {
+		return requests
+	}
+
+	// Keep track of where each of the original rows is as they get moved around
+	order := make([]int, len(rows))
+	for i := range order {
+		order[i] = i
+	}
+	labelOf := func(i int) string {
+		if i == section {
+			return RetiredSectionLabel
+		}
+		return cellLabel(rows, i)
+	}
+	checksEnd := func() int {
+		return slices.IndexFunc(order, func(i int) bool { return isSectionLabel(labelOf(i)) })
+	}
+
+	if section < 0 && len(toMove) > 0 {
+		section = len(rows)
+		order = append(order, section)
+		requests = append(requests, newRetiredSectionRequest(FirstCheckRow+section, sheetID))
+	}
+
+	for _, i := range toMove {
+		from := slices.Index(order, i)
+		requests = append(requests, newMoveRowRequest(from, len(order), sheetID))
+		order = append(slices.Delete(order, from, from+1), i)
+	}
+
+	for _, i := range toRestore {
+		from := slices.Index(order, i)
+
+		var activeRows [][]any
+		for _, j := range order[:checksEnd()] {
+			activeRows = append(activeRows, []any{labelOf(j)})
+		}
+		to, _ := findRowForCheck(labelOf(i), activeRows, nil)
+
+		requests = append(requests, newMoveRowRequest(from, to, sheetID))
+		order = slices.Insert(slices.Delete(order, from, from+1), to, i)
+	}
+
+	return requests
+}
+
+// cellLabel returns the value of the first cell of the row, as a string
+func cellLabel(rows [][]any, i int) string {
+	if i < 0 || i >= len(rows) || len(rows[i]) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%v", rows[i][0])
+}
+
+// newRowFormatRequest returns a request that sets the background and text color of the whole row
+// (1-based) to those of the cell. An empty cell clears them.
+func newRowFormatRequest(row int, cell *sheets.CellData, sheetID int64) *sheets.Request {
+	return &sheets.Request{
+		RepeatCell: &sheets.RepeatCellRequest{
+			Range: &sheets.GridRange{
+				SheetId:       sheetID,
+				StartRowIndex: int64(row - 1),
+				EndRowIndex:   int64(row),
+			},
+			Cell:   cell,
+			Fields: "userEnteredFormat.backgroundColor,userEnteredFormat.textFormat.foregroundColor",
+		},
+	}
+}
+
+// newRetiredSectionRequest returns a request that writes the heading of the retired section in the row (1-based)
+func newRetiredSectionRequest(row int, sheetID int64) *sheets.Request {
+	label := RetiredSectionLabel
+	return &sheets.Request{
+		UpdateCells: &sheets.UpdateCellsRequest{
+			Start: &sheets.GridCoordinate{SheetId: sheetID, RowIndex: int64(row - 1)},
+			Rows: []*sheets.RowData{{Values: []*sheets.CellData{{
+				UserEnteredValue:  &sheets.ExtendedValue{StringValue: &label},
+				UserEnteredFormat: &sheets.CellFormat{TextFormat: &sheets.TextFormat{Bold: true}},
+			}}}},
+			Fields: "userEnteredValue,userEnteredFormat.textFormat.bold",
+		},
+	}
+}
+
+// newMoveRowRequest returns a request that moves a check row to before another one. Both are
+// indexes into the check rows, and the destination is as it was before the move.
+func newMoveRowRequest(from, to int, sheetID int64) *sheets.Request {
+	return &sheets.Request{
+		MoveDimension: &sheets.MoveDimensionRequest{
+			Source: &sheets.DimensionRange{
+				SheetId:    sheetID,
+				Dimension:  "ROWS",
+				StartIndex: int64(FirstCheckRow - 1 + from),
+				EndIndex:   int64(FirstCheckRow + from),
+			},
+			DestinationIndex: int64(FirstCheckRow - 1 + to),
+		},
+	}
+}
@@ -0,0 +1,105 @@
+package googlesheets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/sheets/v4"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
+)
+
+func TestParseRetiredTreatment(t *testing.T) {
+	got, err := ParseRetiredTreatment("")
+	assert.NoError(t, err)
+	assert.Equal(t, RetiredNone, got)
+
+	got, err = ParseRetiredTreatment("Move")
+	assert.NoError(t, err)
+	assert.Equal(t, RetiredMove, got)
+
+	_, err = ParseRetiredTreatment("delete")
+	assert.Error(t, err)
+}
+
+func Test_planRetiredRows(t *testing.T) {
+	const sheetID = 7
+	rows := [][]any{{"Alpha"}, {"Beta"}, {"Gamma"}, {"Legacy"}}
+	checkRows := map[int]string{3: "a", 4: "b", 5: "g"}
+	checks := []nodeping.Check{{ID: "a", Label: "Alpha"}, {ID: "g", Label: "Gamma (renamed)"}}
+
+	notes := map[int]string{4: "Check ID: b"}
+
+	requests := planRetiredRows(rows, checkRows, nil, notes, checks, RetiredNote, "2024-03-01", sheetID)
+	assert.Len(t, requests, 4, "Beta and Legacy should each get metadata and a note")
+	assert.Equal(t, "2024-03-01", requests[0].CreateDeveloperMetadata.DeveloperMetadata.MetadataValue)
+	assert.Equal(t, int64(3), requests[0].CreateDeveloperMetadata.DeveloperMetadata.Location.DimensionRange.StartIndex)
+	assert.Equal(t, "Check ID: b\nRetired on 2024-03-01", requests[1].UpdateCells.Rows[0].Values[0].Note,
+		"the note with the check's details should be kept")
+	assert.Equal(t, "Retired on 2024-03-01", requests[3].UpdateCells.Rows[0].Values[0].Note)
+	assert.Equal(t, int64(5), requests[3].UpdateCells.Start.RowIndex)
+
+	retiredRows := map[int]*sheets.DeveloperMetadata{4: {MetadataId: 42}, 6: {MetadataId: 43}}
+	notes = map[int]string{4: "Check ID: b\nRetired on 2024-03-01", 6: "Check ID: l"}
+	requests = planRetiredRows(rows, checkRows, retiredRows, notes, checks, RetiredNote, "2024-04-01", sheetID)
+	assert.Empty(t, requests, "rows that are already marked should be left alone")
+
+	checks = append(checks, nodeping.Check{ID: "b", Label: "Beta"}, nodeping.Check{ID: "l", Label: "Legacy"})
+	requests = planRetiredRows(rows, checkRows, retiredRows, notes, checks, RetiredGrey, "2024-04-01", sheetID)
+	assert.Len(t, requests, 5, "Beta and Legacy should each be unmarked, and only Beta's note changed")
+	assert.Equal(t, int64(42), requests[0].DeleteDeveloperMetadata.DataFilter.DeveloperMetadataLookup.MetadataId)
+	assert.Equal(t, "Check ID: b", requests[2].UpdateCells.Rows[0].Values[0].Note)
+	assert.Equal(t, int64(3), requests[2].UpdateCells.Start.RowIndex)
+	assert.Equal(t, int64(43), requests[3].DeleteDeveloperMetadata.DataFilter.DeveloperMetadataLookup.MetadataId)
+}
+
+func Test_removeRetiredNote(t *testing.T) {
+	note, ok := removeRetiredNote(addRetiredNote("Check ID: b\nType: HTTP", "2024-03-01"))
+	assert.True(t, ok)
+	assert.Equal(t, "Check ID: b\nType: HTTP", note)
+
+	note, ok = removeRetiredNote(addRetiredNote("", "2024-03-01"))
+	assert.True(t, ok)
+	assert.Equal(t, "", note)
+
+	_, ok = removeRetiredNote("Corrected by someone@example.org")
+	assert.False(t, ok, "a note that this didn't write should be left alone")
+}
+
+func Test_planRetiredRowsMove(t *testing.T) {
+	const sheetID = 7
+	rows := [][]any{{"Alpha"}, {"Beta"}, {"Delta"}, {RetiredSectionLabel}, {"Gamma"}}
+	checkRows := map[int]string{3: "a", 4: "b", 5: "d", 7: "g"}
+	retiredRows := map[int]*sheets.DeveloperMetadata{7: {MetadataId: 42}}
+	checks := []nodeping.Check{{ID: "a", Label: "Alpha"}, {ID: "g", Label: "Gamma"}}
+
+	requests := planRetiredRows(rows, checkRows, retiredRows, nil, checks, RetiredMove, "2024-03-01", sheetID)
+
+	var moves []*sheets.MoveDimensionRequest
+	for _, request := range requests {
+		if request.MoveDimension != nil {
+			moves = append(moves, request.MoveDimension)
+		}
+	}
+
+	// Beta and Delta go to the bottom, then Gamma goes back after Alpha
+	assert.Len(t, moves, 3)
+	assert.Equal(t, int64(3), moves[0].Source.StartIndex)
+	assert.Equal(t, int64(7), moves[0].DestinationIndex)
+	assert.Equal(t, int64(3), moves[1].Source.StartIndex)
+	assert.Equal(t, int64(7), moves[1].DestinationIndex)
+	assert.Equal(t, int64(4), moves[2].Source.StartIndex)
+	assert.Equal(t, int64(3), moves[2].DestinationIndex)
+}
+
+func Test_planRetiredRowsAddsSection(t *testing.T) {
+	rows := [][]any{{"Alpha"}, {"Beta"}}
+	checks := []nodeping.Check{{ID: "b", Label: "Beta"}}
+
+	requests := planRetiredRows(rows, nil, nil, nil, checks, RetiredMove, "2024-03-01", 7)
+	assert.Len(t, requests, 3)
+	assert.Equal(t, RetiredSectionLabel, *requests[1].UpdateCells.Rows[0].Values[0].UserEnteredValue.StringValue)
+	assert.Equal(t, int64(4), requests[1].UpdateCells.Start.RowIndex)
+	assert.Equal(t, int64(2), requests[2].MoveDimension.Source.StartIndex)
+	assert.Equal(t, int64(5), requests[2].MoveDimension.DestinationIndex)
+}
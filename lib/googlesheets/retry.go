@@ -0,0 +1,82 @@
+package googlesheets
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2/jwt"
+)
+
+const (
+	// The number of times a request that is over the quota is retried, and how long to wait before the
+	// first retry. The wait doubles each time, so the last retry comes about a minute after the first
+	// request, by when the per-minute read and write quotas of the Sheets API have started over.
+	quotaRetries    = 6
+	quotaRetryDelay = time.Second
+)
+
+// retryTransport retries the requests that the Google APIs turn away for being over a quota (429) or
+// that fail with a temporary server error (502, 503 or 504), waiting twice as long each time, or as long
+// as the response's Retry-After header says. This way every read and write of a run is covered in one
+// place, however many of them a check needs.
+type retryTransport struct {
+	base    http.RoundTripper
+	retries int
+	delay   time.Duration
+	sleep   func(time.Duration)
+}
+
+// newGoogleClient returns an HTTP client for the Google APIs that authenticates with the config and
+// retries the requests that are over a quota
+func newGoogleClient(config *jwt.Config) *http.Client {
+	client := config.Client(context.Background())
+	client.Transport = &retryTransport{
+		base:    client.Transport,
+		retries: quotaRetries,
+		delay:   quotaRetryDelay,
+		sleep:   time.Sleep,
+	}
+	return client
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	delay := t.delay
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if err != nil || !isRetryable(resp.StatusCode) || attempt == t.retries {
+			return resp, err
+		}
+		if req.Body != nil && req.GetBody == nil {
+			return resp, nil
+		}
+
+		wait := delay
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			wait = time.Duration(seconds) * time.Second
+		}
+		resp.Body.Close()
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		t.sleep(wait)
+		delay *= 2
+	}
+}
+
+// isRetryable reports whether a request that got the status code may succeed if it is sent again
+func isRetryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
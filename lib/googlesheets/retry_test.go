@@ -0,0 +1,73 @@
+package googlesheets
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// roundTripFunc is an http.RoundTripper made from a function
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func Test_retryTransport(t *testing.T) {
+	statuses := []int{http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusOK}
+	var bodies []string
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		bodies = append(bodies, string(body))
+
+		resp := &http.Response{StatusCode: statuses[0], Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}
+		if len(bodies) == 2 {
+			resp.Header.Set("Retry-After", "5")
+		}
+		statuses = statuses[1:]
+		return resp, nil
+	})
+
+	var waits []time.Duration
+	transport := &retryTransport{base: base, retries: 3, delay: time.Second, sleep: func(d time.Duration) { waits = append(waits, d) }}
+
+	req, _ := http.NewRequest(http.MethodPost, "https://sheets.googleapis.com/v4/spreadsheets/abc:batchUpdate", bytes.NewReader([]byte(`{"requests":[]}`)))
+	resp, err := transport.RoundTrip(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{`{"requests":[]}`, `{"requests":[]}`, `{"requests":[]}`}, bodies, "each retry should send the whole body again")
+	assert.Equal(t, []time.Duration{time.Second, 5 * time.Second}, waits, "Retry-After should take the place of the backoff")
+}
+
+func Test_retryTransport_givesUp(t *testing.T) {
+	calls := 0
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: http.StatusTooManyRequests, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+
+	var waits []time.Duration
+	transport := &retryTransport{base: base, retries: 3, delay: time.Second, sleep: func(d time.Duration) { waits = append(waits, d) }}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://sheets.googleapis.com/v4/spreadsheets/abc", nil)
+	resp, err := transport.RoundTrip(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode, "the last response should be returned")
+	assert.Equal(t, 4, calls)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}, waits)
+
+	calls = 0
+	transport.base = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: http.StatusBadRequest, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+	resp, err = transport.RoundTrip(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, 1, calls, "an error that isn't temporary should not be retried")
+}
@@ -0,0 +1,110 @@
+package googlesheets
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"golang.org/x/net/context"
+)
+
+// s3API is the part of the S3 client that S3Store uses
+type s3API interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+}
+
+// S3Store is a Store that keeps each file as an object in an S3 bucket, under a prefix. Unlike a
+// LocalStore on Lambda, whose file system only lasts as long as the function's instance, it keeps
+// the files from one invocation to the next.
+type S3Store struct {
+	Bucket string
+	Prefix string // e.g. "snapshots/"
+	client s3API
+}
+
+// NewS3Store returns an S3Store that uses the AWS credentials and region of the environment, such as
+// those of a Lambda function's role
+func NewS3Store(bucket, prefix string) (S3Store, error) {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return S3Store{}, fmt.Errorf("unable to load the AWS config for bucket %s: %w", bucket, err)
+	}
+	return S3Store{Bucket: bucket, Prefix: prefix, client: s3.NewFromConfig(cfg)}, nil
+}
+
+func (s S3Store) Save(name string, data []byte) error {
+	_, err := s.client.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.Prefix + name),
+		Body:   bytes.NewReader(data),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to write %s to bucket %s: %w", s.Prefix+name, s.Bucket, err)
+	}
+	return nil
+}
+
+func (s S3Store) Load(name string) ([]byte, error) {
+	resp, err := s.client.GetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.Prefix + name),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s from bucket %s: %w", s.Prefix+name, s.Bucket, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s from bucket %s: %w", s.Prefix+name, s.Bucket, err)
+	}
+	return data, nil
+}
+
+func (s S3Store) List(prefix string) ([]string, error) {
+	var names []string
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.Bucket),
+		Prefix: aws.String(s.Prefix + prefix),
+	}
+	for {
+		resp, err := s.client.ListObjectsV2(context.Background(), input)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list bucket %s: %w", s.Bucket, err)
+		}
+
+		for _, object := range resp.Contents {
+			name := strings.TrimPrefix(aws.ToString(object.Key), s.Prefix)
+			if !strings.Contains(name, "/") {
+				names = append(names, name)
+			}
+		}
+
+		if !aws.ToBool(resp.IsTruncated) {
+			break
+		}
+		input.ContinuationToken = resp.NextContinuationToken
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s S3Store) Delete(name string) error {
+	_, err := s.client.DeleteObject(context.Background(), &s3.DeleteObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.Prefix + name),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to delete %s from bucket %s: %w", s.Prefix+name, s.Bucket, err)
+	}
+	return nil
+}
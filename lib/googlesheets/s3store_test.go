@@ -0,0 +1,110 @@
+package googlesheets
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+// fakeS3 is an s3API that keeps the objects of one bucket in memory, and lists them a page at a time
+type fakeS3 struct {
+	objects  map[string][]byte
+	pageSize int
+}
+
+func (f *fakeS3) PutObject(_ context.Context, params *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.objects[aws.ToString(params.Key)] = data
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) GetObject(_ context.Context, params *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	data, ok := f.objects[aws.ToString(params.Key)]
+	if !ok {
+		return nil, errors.New("no such key")
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data))}, nil
+}
+
+func (f *fakeS3) DeleteObject(_ context.Context, params *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	delete(f.objects, aws.ToString(params.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func (f *fakeS3) ListObjectsV2(_ context.Context, params *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, aws.ToString(params.Prefix)) && key > aws.ToString(params.ContinuationToken) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	resp := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(len(keys) > f.pageSize)}
+	if len(keys) > f.pageSize {
+		keys = keys[:f.pageSize]
+		resp.NextContinuationToken = aws.String(keys[len(keys)-1])
+	}
+	for _, key := range keys {
+		resp.Contents = append(resp.Contents, types.Object{Key: aws.String(key)})
+	}
+	return resp, nil
+}
+
+func TestS3Store(t *testing.T) {
+	client := &fakeS3{objects: map[string][]byte{"other/abc.2024.1.json": nil}, pageSize: 2}
+	store := S3Store{Bucket: "bucket", Prefix: "snapshots/", client: client}
+
+	for _, name := range []string{"abc.2024.3.json", "abc.2024.1.json", "abc.2024.2.json", "abc.2023.1.json"} {
+		assert.NoError(t, store.Save(name, []byte(name)))
+	}
+	assert.Contains(t, client.objects, "snapshots/abc.2024.1.json")
+
+	names, err := store.List("abc.2024.")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"abc.2024.1.json", "abc.2024.2.json", "abc.2024.3.json"}, names,
+		"every page should be listed, without the store's prefix")
+
+	data, err := store.Load("abc.2024.2.json")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc.2024.2.json", string(data))
+
+	assert.NoError(t, store.Delete("abc.2024.2.json"))
+	_, err = store.Load("abc.2024.2.json")
+	assert.Error(t, err)
+}
+
+func TestOpenStore(t *testing.T) {
+	store, err := OpenStore("./snapshots")
+	assert.NoError(t, err)
+	assert.Equal(t, LocalStore{Dir: "./snapshots"}, store)
+	assert.False(t, IsDurable(store))
+
+	t.Setenv("AWS_REGION", "us-east-1")
+	store, err = OpenStore("s3://my-bucket/archiver/snapshots/")
+	assert.NoError(t, err)
+	if assert.IsType(t, S3Store{}, store) {
+		assert.Equal(t, "my-bucket", store.(S3Store).Bucket)
+		assert.Equal(t, "archiver/snapshots/", store.(S3Store).Prefix)
+	}
+	assert.True(t, IsDurable(store))
+
+	store, err = OpenStore("s3://my-bucket")
+	assert.NoError(t, err)
+	assert.Equal(t, "", store.(S3Store).Prefix)
+
+	_, err = OpenStore("s3://")
+	assert.Error(t, err)
+}
@@ -0,0 +1,84 @@
+package googlesheets
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
+)
+
+const ServicesSheetSuffix = " services"
+
+// ServicesSheetName returns the name of the tab that holds the composite service uptimes for the year tab
+func ServicesSheetName(yearSheetName string) string {
+	return yearSheetName + ServicesSheetSuffix
+}
+
+// GetServiceUptimes computes each service's composite uptime for every month in the period,
+// using the same month and year naming as GetMonthUptimes. The period is cut short at now,
+// so that the rest of the current month doesn't count as uptime.
+func GetServiceUptimes(services []nodeping.Service, outages map[string][]nodeping.Interval, period nodeping.Period, now time.Time) []MonthUptimes {
+	end := period.To
+	if end.After(now) {
+		end = now
+	}
+
+	var months []MonthUptimes
+	for month := time.Date(period.From.Year(), period.From.Month(), 1, 0, 0, 0, 0, time.UTC); month.Before(end); month = month.AddDate(0, 1, 0) {
+		from := month
+		if from.Before(period.From) {
+			from = period.From
+		}
+		to := month.AddDate(0, 1, 0)
+		if to.After(end) {
+			to = end
+		}
+
+		var uptimes []CheckUptime
+		for _, service := range services {
+			uptimes = append(uptimes, CheckUptime{Label: service.Name, Uptime: service.Uptime(outages, from, to)})
+		}
+
+		months = append(months, MonthUptimes{
+			Month:   month.Format("January"),
+			Year:    month.Format("2006"),
+			Uptimes: uptimes,
+		})
+	}
+
+	return months
+}
+
+// ArchiveServiceUptimes writes each service's composite uptime to the "<year> services" tab, which
+// has the same layout as the year tab, but with one row per service instead of one per check.
+func ArchiveServiceUptimes(monthUptimes []MonthUptimes, precision int, thresholds UptimeThresholds, sheetsData SheetsData) error {
+	yearTabs := sheetsData
+	for _, month := range monthUptimes {
+		sheetsData.SheetName = ServicesSheetName(yearTabs.GetSheetName(month.Year))
+
+		sheetID, err := EnsureSheetExists(sheetsData.SheetName, thresholds, sheetsData)
+		if err != nil {
+			return err
+		}
+		sheetsData.SheetID = sheetID
+
+		monthColumn, err := EnsureMonthColumnExists(month.Month, month.Year, sheetsData)
+		if err != nil {
+			return fmt.Errorf("error choosing services column for '%s': %w", month.Month, err)
+		}
+
+		for _, service := range month.Uptimes {
+			serviceRow, err := EnsureCheckRowExists("", service.Label, month.Year, sheetsData)
+			if err != nil {
+				return fmt.Errorf("error adding row for service '%s': %w", service.Label, err)
+			}
+
+			err = WriteUptimeToCell(int64(serviceRow), int64(monthColumn), service.Uptime, precision, sheetsData)
+			if err != nil {
+				return fmt.Errorf("error writing uptime for service '%s': %w", service.Label, err)
+			}
+		}
+	}
+
+	return nil
+}
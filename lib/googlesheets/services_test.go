@@ -0,0 +1,31 @@
+package googlesheets
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
+)
+
+func TestGetServiceUptimes(t *testing.T) {
+	services := []nodeping.Service{{Name: "Website", Rule: nodeping.ServiceRuleAnd, Checks: []string{"web"}}}
+	outages := map[string][]nodeping.Interval{
+		"web": {{
+			Start: time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC),
+			End:   time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC),
+		}},
+	}
+	period, _ := nodeping.GetPeriod("2024-01:2024-12")
+	now := time.Date(2024, 2, 3, 0, 0, 0, 0, time.UTC)
+
+	got := GetServiceUptimes(services, outages, *period, now)
+
+	assert.Len(t, got, 2, "months after now should be left out")
+	assert.Equal(t, "January", got[0].Month)
+	assert.Equal(t, "2024", got[0].Year)
+	assert.InDelta(t, 100*(1-12.0/(31*24)), got[0].Uptimes[0].Uptime, 1e-9)
+	assert.Equal(t, "February", got[1].Month)
+	assert.InDelta(t, 100*(1-12.0/(2*24)), got[1].Uptimes[0].Uptime, 1e-9)
+}
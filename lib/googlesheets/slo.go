@@ -0,0 +1,152 @@
+package googlesheets
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/sheets/v4"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
+)
+
+const (
+	SLOSheetName      = "SLO"
+	SLOExhaustedLabel = "EXHAUSTED"
+	sloDateLayout     = "2006-01-02"
+)
+
+var sloHeader = []any{
+	"Checks", "Target", "Window", "From", "To", "Uptime so far",
+	"Budget (minutes)", "Consumed (minutes)", "Remaining (minutes)", "Budget used %", "Status",
+}
+
+// EnsureSLOSheetExists creates the SLO tab if it doesn't already exist. A new tab gets a frozen
+// header row and a rule that colors the rows of checks which have used up their error budget.
+func EnsureSLOSheetExists(sheetsData SheetsData) (*sheets.SheetProperties, error) {
+	sheetName := sheetsData.GetSheetName(SLOSheetName)
+	properties, err := GetSheetProperties(sheetName, sheetsData)
+	if err != nil || properties != nil {
+		return properties, err
+	}
+
+	srv := sheetsData.Service
+	spreadsheetID := sheetsData.SpreadsheetID
+
+	addSheet := &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{{
+			AddSheet: &sheets.AddSheetRequest{
+				Properties: &sheets.SheetProperties{
+					Title:          sheetName,
+					GridProperties: &sheets.GridProperties{FrozenRowCount: 1},
+				},
+			},
+		}},
+	}
+
+	resp, err := srv.Spreadsheets.BatchUpdate(spreadsheetID, addSheet).Context(context.Background()).Do()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create new sheet %s. %s", sheetName, err)
+	}
+	properties = resp.Replies[0].AddSheet.Properties
+
+	statusColumn, err := ConvertColumnIndexToLetter(int64(len(sloHeader) - 1))
+	if err != nil {
+		return nil, err
+	}
+
+	exhaustedRule := &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{{
+			AddConditionalFormatRule: &sheets.AddConditionalFormatRuleRequest{
+				Rule: &sheets.ConditionalFormatRule{
+					Ranges: []*sheets.GridRange{{
+						SheetId:          properties.SheetId,
+						StartRowIndex:    1,
+						StartColumnIndex: 0,
+						EndColumnIndex:   int64(len(sloHeader)),
+					}},
+					BooleanRule: &sheets.BooleanRule{
+						Condition: &sheets.BooleanCondition{
+							Type: "CUSTOM_FORMULA",
+							Values: []*sheets.ConditionValue{{
+								UserEnteredValue: fmt.Sprintf(`=$%s2="%s"`, statusColumn, SLOExhaustedLabel),
+							}},
+						},
+						Format: &sheets.CellFormat{BackgroundColor: heatmapRed},
+					},
+				},
+			},
+		}},
+	}
+
+	if _, err := srv.Spreadsheets.BatchUpdate(spreadsheetID, exhaustedRule).Context(context.Background()).Do(); err != nil {
+		return nil, fmt.Errorf("unable to add formatting to sheet %s: %w", sheetName, err)
+	}
+
+	return properties, nil
+}
+
+// ArchiveErrorBudgets replaces the contents of the SLO tab with the current state of each check's
+// error budget. Checks that have used up their budget are flagged in the Status column.
+func ArchiveErrorBudgets(budgets []nodeping.ErrorBudget, precision int, sheetsData SheetsData) error {
+	properties, err := EnsureSLOSheetExists(sheetsData)
+	if err != nil {
+		return err
+	}
+
+	sheetName := sheetsData.GetSheetName(SLOSheetName)
+	srv := sheetsData.Service
+	spreadsheetID := sheetsData.SpreadsheetID
+
+	rows := [][]any{sloHeader}
+	for _, budget := range budgets {
+		rows = append(rows, errorBudgetRow(budget, precision))
+	}
+
+	if err := EnsureRowCount(int64(len(rows)), properties, spreadsheetID, srv); err != nil {
+		return err
+	}
+
+	_, err = srv.Spreadsheets.Values.Clear(spreadsheetID, SheetRange(sheetName, ""), &sheets.ClearValuesRequest{}).Do()
+	if err != nil {
+		return fmt.Errorf("unable to clear sheet %s: %w", sheetName, err)
+	}
+
+	valueRange := &sheets.ValueRange{Values: rows}
+	_, err = srv.Spreadsheets.Values.Update(spreadsheetID, SheetRange(sheetName, "A1"), valueRange).ValueInputOption("RAW").Do()
+	if err != nil {
+		return fmt.Errorf("unable to write error budgets to %s: %w", sheetName, err)
+	}
+
+	return nil
+}
+
+func errorBudgetRow(budget nodeping.ErrorBudget, precision int) []any {
+	status := "OK"
+	if budget.Exhausted() {
+		status = SLOExhaustedLabel
+	}
+
+	return []any{
+		budget.Label,
+		budget.SLO.Target,
+		budget.SLO.Window,
+		budget.Window.From.Format(sloDateLayout),
+		budget.Window.To.Format(sloDateLayout),
+		roundToPrecision(budget.Uptime, precision),
+		roundToPrecision(budget.Budget.Minutes(), 1),
+		roundToPrecision(budget.Consumed.Minutes(), 1),
+		roundToPrecision(budget.Remaining.Minutes(), 1),
+		roundToPrecision(budget.ConsumedPercent(), 1),
+		status,
+	}
+}
+
+// sloReferenceTime returns the time whose SLO windows should be reported for the period,
+// i.e. the end of the period or now, whichever is earlier
+func sloReferenceTime(period nodeping.Period, now time.Time) time.Time {
+	if period.To.After(now) {
+		return now
+	}
+	return period.To
+}
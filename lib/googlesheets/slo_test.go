@@ -0,0 +1,29 @@
+package googlesheets
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
+)
+
+func Test_errorBudgetRow(t *testing.T) {
+	slo := nodeping.SLO{Label: "IdP *", Target: 99.9, Window: nodeping.SLOWindowMonth}
+	window, _ := nodeping.GetWindowPeriod(nodeping.SLOWindowMonth, time.Date(2024, 4, 10, 0, 0, 0, 0, time.UTC))
+	budget := nodeping.ComputeErrorBudget(slo, "IdP Web", window, nodeping.UptimeResponse{Enabled: 777600000, Down: 3000000})
+
+	want := []any{"IdP Web", 99.9, "month", "2024-04-01", "2024-04-30", 99.614, 43.2, 50.0, -6.8, 115.7, SLOExhaustedLabel}
+	assert.Equal(t, want, errorBudgetRow(budget, 3))
+}
+
+func Test_sloReferenceTime(t *testing.T) {
+	now := time.Date(2024, 4, 10, 0, 0, 0, 0, time.UTC)
+
+	lastMonth := nodeping.GetLastMonthPeriod(now)
+	assert.Equal(t, lastMonth.To, sloReferenceTime(lastMonth, now))
+
+	thisYear := nodeping.GetThisYearPeriod(now)
+	assert.Equal(t, now, sloReferenceTime(thisYear, now))
+}
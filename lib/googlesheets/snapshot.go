@@ -0,0 +1,313 @@
+package googlesheets
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/sheets/v4"
+)
+
+const (
+	DefaultSnapshotsKept = 10
+
+	snapshotTimeLayout = "20060102T150405Z"
+	snapshotCellFields = "userEnteredValue,userEnteredFormat,note"
+)
+
+// snapshotMetadataKeys are the developer metadata that tie rows and columns to checks or mark them,
+// which move when rows and columns are inserted, so they are put back by RestoreSnapshot
+var snapshotMetadataKeys = []string{CheckIDMetadataKey, RetiredMetadataKey, ProvisionalMetadataKey}
+
+var snapshotDimensions = []struct{ locationType, dimension string }{
+	{"ROW", "ROWS"},
+	{"COLUMN", "COLUMNS"},
+}
+
+// Snapshot is a copy of a tab, with enough of its structure to undo the rows and columns that a run
+// inserts: its size, the value, format and note of each cell, and the developer metadata of its
+// rows and columns
+type Snapshot struct {
+	SpreadsheetID string             `json:"spreadsheetId"`
+	SheetName     string             `json:"sheetName"`
+	TakenAt       time.Time          `json:"takenAt"`
+	RowCount      int64              `json:"rowCount"`
+	ColumnCount   int64              `json:"columnCount"`
+	Rows          []*sheets.RowData  `json:"rows"`
+	Metadata      []SnapshotMetadata `json:"metadata"`
+}
+
+// SnapshotMetadata is the developer metadata of one row or column of a snapshot
+type SnapshotMetadata struct {
+	Key       string `json:"key"`
+	Value     string `json:"value"`
+	Dimension string `json:"dimension"` // "ROWS" or "COLUMNS"
+	Index     int    `json:"index"`     // 0-based
+}
+
+// TakeSnapshot copies the tab, or returns nil if there is no such tab
+func TakeSnapshot(sheetName string, now time.Time, sheetsData SheetsData) (*Snapshot, error) {
+	exists, sheetID, err := GetSheetIDFromTitle(sheetName, sheetsData)
+	if err != nil || !exists {
+		return nil, err
+	}
+	sheetsData.SheetID = sheetID
+
+	resp, err := sheetsData.Service.Spreadsheets.Get(sheetsData.SpreadsheetID).
+		Ranges(SheetRange(sheetName, "")).
+		IncludeGridData(true).
+		Fields("sheets(properties(sheetId,gridProperties(rowCount,columnCount)),data(rowData(values(" + snapshotCellFields + "))))").
+		Do()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s for a snapshot: %w", sheetName, err)
+	}
+	if len(resp.Sheets) == 0 || resp.Sheets[0].Properties == nil {
+		return nil, fmt.Errorf("unable to read %s for a snapshot", sheetName)
+	}
+
+	sheet := resp.Sheets[0]
+	snapshot := &Snapshot{
+		SpreadsheetID: sheetsData.SpreadsheetID,
+		SheetName:     sheetName,
+		TakenAt:       now,
+	}
+	if sheet.Properties.GridProperties != nil {
+		snapshot.RowCount = sheet.Properties.GridProperties.RowCount
+		snapshot.ColumnCount = sheet.Properties.GridProperties.ColumnCount
+	}
+	if len(sheet.Data) > 0 {
+		snapshot.Rows = sheet.Data[0].RowData
+	}
+
+	for _, key := range snapshotMetadataKeys {
+		for _, d := range snapshotDimensions {
+			metadata, err := getDimensionMetadata(key, d.locationType, sheetsData)
+			if err != nil {
+				return nil, err
+			}
+			for index, m := range metadata {
+				snapshot.Metadata = append(snapshot.Metadata, SnapshotMetadata{
+					Key:       key,
+					Value:     m.MetadataValue,
+					Dimension: d.dimension,
+					Index:     index,
+				})
+			}
+		}
+	}
+
+	return snapshot, nil
+}
+
+// SaveSnapshot saves the snapshot to the store, and deletes the oldest snapshots of the same tab,
+// so that only the last keep of them are kept. It returns the name of the snapshot in the store.
+func SaveSnapshot(snapshot *Snapshot, keep int, store Store) (string, error) {
+	if keep < 1 {
+		keep = DefaultSnapshotsKept
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return "", fmt.Errorf("unable to encode the snapshot of %s: %w", snapshot.SheetName, err)
+	}
+
+	prefix := snapshotPrefix(snapshot.SpreadsheetID, snapshot.SheetName)
+	name := prefix + snapshot.TakenAt.UTC().Format(snapshotTimeLayout) + ".json"
+	if err := store.Save(name, data); err != nil {
+		return "", err
+	}
+
+	names, err := store.List(prefix)
+	if err != nil {
+		return "", err
+	}
+	for _, old := range oldSnapshots(names, keep) {
+		if err := store.Delete(old); err != nil {
+			return "", err
+		}
+	}
+
+	return name, nil
+}
+
+// ListSnapshots returns the names of the snapshots of the tab in the store, oldest first
+func ListSnapshots(spreadsheetID, sheetName string, store Store) ([]string, error) {
+	return store.List(snapshotPrefix(spreadsheetID, sheetName))
+}
+
+// LoadSnapshot reads a snapshot from the store. If no name is given, it is the latest snapshot of the tab.
+func LoadSnapshot(name, spreadsheetID, sheetName string, store Store) (*Snapshot, error) {
+	if name == "" {
+		names, err := ListSnapshots(spreadsheetID, sheetName, store)
+		if err != nil {
+			return nil, err
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("there are no snapshots of %s", sheetName)
+		}
+		name = names[len(names)-1]
+	}
+
+	data, err := store.Load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("unable to decode snapshot %s: %w", name, err)
+	}
+	if snapshot.SpreadsheetID != spreadsheetID || snapshot.SheetName != sheetName {
+		return nil, fmt.Errorf("snapshot %s is of %s in spreadsheet %s", name, snapshot.SheetName, snapshot.SpreadsheetID)
+	}
+
+	return &snapshot, nil
+}
+
+// RestoreSnapshot puts the tab back the way it was when the snapshot was taken: its size, the value,
+// format and note of each cell, and the developer metadata of its rows and columns (see
+// snapshotMetadataKeys). The protected month columns (see ProtectMonthColumn) are moved back to the
+// columns of their months, and those of months that the snapshot doesn't have are removed. Any other
+// protected ranges, developer metadata, charts and conditional formatting are left as they are.
+func RestoreSnapshot(snapshot *Snapshot, sheetsData SheetsData) error {
+	if err := writeSnapshot(snapshot, sheetsData); err != nil {
+		return err
+	}
+
+	if headings := monthHeadings(snapshot.Rows); headings != nil {
+		if err := moveProtectedMonths(headings, sheetsData); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("restored snapshot", "sheet", snapshot.SheetName, "takenAt", snapshot.TakenAt)
+	return nil
+}
+
+// writeSnapshot replaces the tab's size, cells and metadata with those of the snapshot
+func writeSnapshot(snapshot *Snapshot, sheetsData SheetsData) error {
+	sheetName := snapshot.SheetName
+	exists, sheetID, err := GetSheetIDFromTitle(sheetName, sheetsData)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("there is no sheet %s in spreadsheet %s", sheetName, sheetsData.SpreadsheetID)
+	}
+	sheetsData.SheetID = sheetID
+
+	// The metadata is deleted first, in case its rows or columns are about to be removed
+	var requests []*sheets.Request
+	for _, key := range snapshotMetadataKeys {
+		for _, d := range snapshotDimensions {
+			metadata, err := getDimensionMetadata(key, d.locationType, sheetsData)
+			if err != nil {
+				return err
+			}
+			for _, m := range metadata {
+				requests = append(requests, newDeleteMetadataRequest(m.MetadataId))
+			}
+		}
+	}
+
+	requests = append(requests,
+		&sheets.Request{
+			UpdateSheetProperties: &sheets.UpdateSheetPropertiesRequest{
+				Properties: &sheets.SheetProperties{
+					SheetId: sheetID,
+					GridProperties: &sheets.GridProperties{
+						RowCount:    snapshot.RowCount,
+						ColumnCount: snapshot.ColumnCount,
+					},
+				},
+				Fields: "gridProperties.rowCount,gridProperties.columnCount",
+			},
+		},
+		&sheets.Request{
+			// The cells that aren't in the snapshot's rows are cleared
+			UpdateCells: &sheets.UpdateCellsRequest{
+				Range:  &sheets.GridRange{SheetId: sheetID},
+				Rows:   snapshot.Rows,
+				Fields: snapshotCellFields,
+			},
+		},
+	)
+
+	for _, m := range snapshot.Metadata {
+		requests = append(requests, newDimensionMetadataRequest(m.Dimension, m.Index, m.Key, m.Value, sheetID))
+	}
+
+	rbb := &sheets.BatchUpdateSpreadsheetRequest{Requests: requests}
+	_, err = sheetsData.Service.Spreadsheets.BatchUpdate(sheetsData.SpreadsheetID, rbb).Context(context.Background()).Do()
+	if err != nil {
+		return fmt.Errorf("unable to write %s: %w", sheetName, err)
+	}
+	return nil
+}
+
+// snapshotSheets saves a snapshot of each of the tabs that exist, before a run changes them
+func snapshotSheets(sheetNames []string, keep int, store Store, sheetsData SheetsData) error {
+	for _, sheetName := range sheetNames {
+		snapshot, err := TakeSnapshot(sheetName, time.Now().UTC(), sheetsData)
+		if err != nil {
+			return err
+		}
+		if snapshot == nil {
+			continue
+		}
+
+		name, err := SaveSnapshot(snapshot, keep, store)
+		if err != nil {
+			return fmt.Errorf("unable to save the snapshot of %s: %w", sheetName, err)
+		}
+		slog.Info("saved snapshot", "sheet", sheetName, "snapshot", name)
+	}
+
+	return nil
+}
+
+// monthHeadings returns the text of the cells of the month heading row, from column A, or nil if
+// there is no such row
+func monthHeadings(rows []*sheets.RowData) []any {
+	if len(rows) < MonthHeaderRow || rows[MonthHeaderRow-1] == nil {
+		return nil
+	}
+
+	var headings []any
+	for _, cell := range rows[MonthHeaderRow-1].Values {
+		headings = append(headings, cellText(cell))
+	}
+	return headings
+}
+
+// snapshotPrefix returns the start of the names of the snapshots of the tab. Different tabs always have
+// different prefixes, and no prefix is the start of another.
+func snapshotPrefix(spreadsheetID, sheetName string) string {
+	return escapeSnapshotName(spreadsheetID) + "." + escapeSnapshotName(sheetName) + "."
+}
+
+// escapeSnapshotName makes a name safe for a file name, and reversibly so, by writing each byte other
+// than a letter, digit or hyphen as "_" and its hex code, e.g. "Ops 2024" as "Ops_202024"
+func escapeSnapshotName(name string) string {
+	var b strings.Builder
+	for _, c := range []byte(name) {
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "_%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// oldSnapshots returns the names of the snapshots to delete, given all the names, oldest first, so
+// that only the last keep of them are kept
+func oldSnapshots(names []string, keep int) []string {
+	if len(names) <= keep {
+		return nil
+	}
+	return names[:len(names)-keep]
+}
@@ -0,0 +1,74 @@
+package googlesheets
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/sheets/v4"
+)
+
+func TestSaveSnapshot(t *testing.T) {
+	store := LocalStore{Dir: t.TempDir()}
+	start := time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC)
+
+	var value float64 = 99.5
+	for i := range 4 {
+		snapshot := &Snapshot{
+			SpreadsheetID: "abc",
+			SheetName:     "Ops 2024",
+			TakenAt:       start.AddDate(0, 0, i),
+			RowCount:      int64(10 + i),
+			Rows: []*sheets.RowData{{Values: []*sheets.CellData{
+				{UserEnteredValue: &sheets.ExtendedValue{NumberValue: &value}},
+			}}},
+			Metadata: []SnapshotMetadata{{Key: CheckIDMetadataKey, Value: "id1", Dimension: "ROWS", Index: 2}},
+		}
+		_, err := SaveSnapshot(snapshot, 2, store)
+		assert.NoError(t, err)
+	}
+
+	_, err := SaveSnapshot(&Snapshot{SpreadsheetID: "abc", SheetName: "Ops 2024 daily", TakenAt: start}, 2, store)
+	assert.NoError(t, err)
+
+	names, err := ListSnapshots("abc", "Ops 2024", store)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"abc.Ops_202024.20240303T060000Z.json", "abc.Ops_202024.20240304T060000Z.json"}, names,
+		"only the last two snapshots of the tab should be kept")
+
+	latest, err := LoadSnapshot("", "abc", "Ops 2024", store)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(13), latest.RowCount)
+	assert.Equal(t, 99.5, *latest.Rows[0].Values[0].UserEnteredValue.NumberValue)
+	assert.Equal(t, "id1", latest.Metadata[0].Value)
+
+	_, err = LoadSnapshot(names[0], "abc", "2024", store)
+	assert.Error(t, err, "a snapshot of another tab shouldn't be restored")
+
+	_, err = LoadSnapshot("", "abc", "2023", store)
+	assert.Error(t, err)
+}
+
+func Test_snapshotPrefix(t *testing.T) {
+	assert.Equal(t, "abc_5F-def.Ops_202024.", snapshotPrefix("abc_-def", "Ops 2024"))
+
+	prefixes := map[string]string{}
+	for _, sheetName := range []string{"2024 A", "2024_A", "2024.A", "2024", "2024 daily", "Ops/2024", "Ops_2F2024"} {
+		prefix := snapshotPrefix("abc", sheetName)
+		assert.NotContains(t, prefixes, prefix, "%q and %q should have different prefixes", sheetName, prefixes[prefix])
+		prefixes[prefix] = sheetName
+
+		for other := range prefixes {
+			if other != prefix {
+				assert.False(t, strings.HasPrefix(prefix, other), "%q shouldn't start with %q", prefix, other)
+			}
+		}
+	}
+}
+
+func Test_oldSnapshots(t *testing.T) {
+	names := []string{"a", "b", "c"}
+	assert.Equal(t, []string{"a"}, oldSnapshots(names, 2))
+	assert.Empty(t, oldSnapshots(names, 3))
+}
@@ -0,0 +1,100 @@
+package googlesheets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Store keeps named files for later runs, such as snapshots of tabs. LocalStore keeps them in a
+// local directory, and S3Store in an S3 bucket (see OpenStore).
+type Store interface {
+	Save(name string, data []byte) error
+	Load(name string) ([]byte, error)
+	List(prefix string) ([]string, error) // The names that start with the prefix, sorted
+	Delete(name string) error
+}
+
+// OpenStore returns the Store at the location, which is either an S3 bucket and prefix, like
+// "s3://my-bucket/snapshots", or a local directory
+func OpenStore(location string) (Store, error) {
+	path, ok := strings.CutPrefix(location, "s3://")
+	if !ok {
+		return LocalStore{Dir: location}, nil
+	}
+
+	bucket, prefix, _ := strings.Cut(path, "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("%q has no bucket name", location)
+	}
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		prefix += "/"
+	}
+	return NewS3Store(bucket, prefix)
+}
+
+// IsDurable reports whether the store keeps its files beyond the life of the process's own file
+// system, which a Lambda function's doesn't
+func IsDurable(store Store) bool {
+	_, ok := store.(S3Store)
+	return ok
+}
+
+// LocalStore is a Store that keeps each file in a directory, which is created if it doesn't exist
+type LocalStore struct {
+	Dir string
+}
+
+func (s LocalStore) Save(name string, data []byte) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return fmt.Errorf("unable to create directory %s: %w", s.Dir, err)
+	}
+
+	// Write to a temporary file first, so that a failed write doesn't leave half a file behind
+	path := filepath.Join(s.Dir, name)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return fmt.Errorf("unable to write %s: %w", path, err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("unable to write %s: %w", path, err)
+	}
+	return nil
+}
+
+func (s LocalStore) Load(name string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.Dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s from %s: %w", name, s.Dir, err)
+	}
+	return data, nil
+}
+
+func (s LocalStore) List(prefix string) ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to list %s: %w", s.Dir, err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, prefix) && !strings.HasSuffix(name, ".tmp") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s LocalStore) Delete(name string) error {
+	if err := os.Remove(filepath.Join(s.Dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unable to delete %s from %s: %w", name, s.Dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package googlesheets
+
+import (
+	"fmt"
+	"slices"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/sheets/v4"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
+)
+
+// SummaryTotalsLabel is the check name cell of the row that summarizes all the checks
+const SummaryTotalsLabel = "All checks"
+
+// SummaryHeaders are the headings of the summary columns, which follow the month columns
+var SummaryHeaders = []string{"Year avg", "Worst month", "Months below SLO"}
+
+// isSummaryHeader reports whether a month heading cell is actually one of the summary columns
+func isSummaryHeader(header string) bool {
+	return slices.Contains(SummaryHeaders, header)
+}
+
+// isSectionLabel reports whether a check name cell heads a section rather than naming a check
+func isSectionLabel(label string) bool {
+	return label == SummaryTotalsLabel || label == RetiredSectionLabel
+}
+
+// findChecksEnd returns the index of the row after the current checks, i.e. the totals row or the
+// heading of the retired section, whichever comes first. If there are neither, it's the number of rows.
+func findChecksEnd(rows [][]any) int {
+	for i := range rows {
+		if isSectionLabel(cellLabel(rows, i)) {
+			return i
+		}
+	}
+	return len(rows)
+}
+
+// findSummaryColumns returns the (0-based) columns of the last month and of the first summary
+// heading, given the month heading row from column B and how the month headings are written. The last
+// month is -1 if there are no months. If there are no summary columns yet, they go after the last month.
+func findSummaryColumns(headers []any, format MonthFormat) (int, int) {
+	lastMonthColumn, summaryColumn := -1, -1
+	for i, value := range headers {
+		header := fmt.Sprintf("%v", value)
+		if isSummaryHeader(header) {
+			if summaryColumn < 0 {
+				summaryColumn = i + 1
+			}
+		} else if _, err := format.Parse(header); err == nil {
+			lastMonthColumn = i + 1
+		}
+	}
+
+	if summaryColumn < 0 && lastMonthColumn >= 0 {
+		summaryColumn = lastMonthColumn + 1
+	}
+	return lastMonthColumn, summaryColumn
+}
+
+// UpdateSummary rebuilds the summary block of the year tab: a "Year avg", "Worst month" and
+// "Months below SLO" column for each check, after the last month column, and a totals row after the
+// last current check. The formulas are rewritten each time, so they always cover all the month columns.
+// A check's SLO target comes from the first SLO that matches it, or else it is the defaultTarget.
+func UpdateSummary(year string, slos []nodeping.SLO, defaultTarget float64, precision int, sheetsData SheetsData) error {
+	sheetName := sheetsData.GetSheetName(year)
+	srv := sheetsData.Service
+	spreadsheetID := sheetsData.SpreadsheetID
+
+	properties, err := GetGridProperties(sheetName, sheetsData)
+	if err != nil {
+		return err
+	}
+
+	headers, err := getMonthHeaders(sheetName, properties, sheetsData)
+	if err != nil {
+		return err
+	}
+
+	lastMonthColumn, summaryColumn := findSummaryColumns(headers, sheetsData.MonthFormat)
+	if lastMonthColumn < 0 {
+		return nil
+	}
+	if summaryColumn < lastMonthColumn {
+		return fmt.Errorf("the summary columns of %s must come after all the month columns", sheetName)
+	}
+
+	err = EnsureColumnCount(int64(summaryColumn+len(SummaryHeaders)), properties, spreadsheetID, srv)
+	if err != nil {
+		return err
+	}
+
+	rows, err := getCheckNames(sheetName, properties, sheetsData)
+	if err != nil {
+		return err
+	}
+
+	totalsIndex := slices.IndexFunc(rows, func(row []any) bool {
+		return len(row) > 0 && fmt.Sprintf("%v", row[0]) == SummaryTotalsLabel
+	})
+	if totalsIndex < 0 {
+		totalsIndex = findChecksEnd(rows)
+		if totalsIndex == 0 {
+			return nil
+		}
+
+		if totalsIndex < len(rows) {
+			rbb := &sheets.BatchUpdateSpreadsheetRequest{Requests: newInsertRowRequests(FirstCheckRow+totalsIndex, sheetsData.SheetID)}
+			if _, err := srv.Spreadsheets.BatchUpdate(spreadsheetID, rbb).Context(context.Background()).Do(); err != nil {
+				return fmt.Errorf("error inserting totals row in Google sheets: %w", err)
+			}
+		} else if err := EnsureRowCount(int64(FirstCheckRow+totalsIndex), properties, spreadsheetID, srv); err != nil {
+			return err
+		}
+		rows = slices.Insert(rows, totalsIndex, []any{SummaryTotalsLabel})
+	}
+
+	targetFor := func(label string) float64 {
+		if slo, ok := nodeping.MatchSLO(slos, label); ok {
+			return slo.Target
+		}
+		return defaultTarget
+	}
+
+	data := buildSummaryData(sheetName, rows, totalsIndex, lastMonthColumn, summaryColumn, targetFor)
+	update := &sheets.BatchUpdateValuesRequest{Data: data, ValueInputOption: "USER_ENTERED"}
+	if _, err := srv.Spreadsheets.Values.BatchUpdate(spreadsheetID, update).Do(); err != nil {
+		return fmt.Errorf("unable to write summary formulas to %s: %w", sheetName, err)
+	}
+
+	formats := &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: summaryFormatRequests(sheetsData.SheetID, len(rows), totalsIndex, lastMonthColumn, summaryColumn, precision),
+	}
+	if _, err := srv.Spreadsheets.BatchUpdate(spreadsheetID, formats).Context(context.Background()).Do(); err != nil {
+		return fmt.Errorf("unable to format summary of %s: %w", sheetName, err)
+	}
+
+	return nil
+}
+
+// buildSummaryData returns the summary headings and formulas for the check rows (starting at
+// FirstCheckRow) and the totals row, which is at totalsIndex among the rows. The columns are 0-based.
+func buildSummaryData(
+	sheetName string,
+	rows [][]any,
+	totalsIndex, lastMonthColumn, summaryColumn int,
+	targetFor func(label string) float64,
+) []*sheets.ValueRange {
+	firstMonthLetter, _ := ConvertColumnIndexToLetter(1)
+	lastMonthLetter, _ := ConvertColumnIndexToLetter(int64(lastMonthColumn))
+	summaryLetter, _ := ConvertColumnIndexToLetter(int64(summaryColumn))
+
+	headers := make([]any, len(SummaryHeaders))
+	for i, header := range SummaryHeaders {
+		headers[i] = header
+	}
+	data := []*sheets.ValueRange{{
+		Range:  SheetRange(sheetName, fmt.Sprintf("%s%d", summaryLetter, MonthHeaderRow)),
+		Values: [][]any{headers},
+	}}
+
+	for i := range rows {
+		label := cellLabel(rows, i)
+		if label == "" || isSectionLabel(label) {
+			continue
+		}
+
+		row := FirstCheckRow + i
+		months := fmt.Sprintf("%s%d:%s%d", firstMonthLetter, row, lastMonthLetter, row)
+		data = append(data, &sheets.ValueRange{
+			Range: SheetRange(sheetName, fmt.Sprintf("%s%d", summaryLetter, row)),
+			Values: [][]any{{
+				fmt.Sprintf(`=IFERROR(AVERAGE(%s), "")`, months),
+				fmt.Sprintf(`=IF(COUNT(%s)=0, "", MIN(%s))`, months, months),
+				fmt.Sprintf(`=COUNTIF(%s, "<%v")`, months, targetFor(label)),
+			}},
+		})
+	}
+
+	// The totals row covers the current checks, which are all above it
+	totalsRow := FirstCheckRow + totalsIndex
+	totals := []any{SummaryTotalsLabel}
+	for column := 1; column < summaryColumn+len(SummaryHeaders); column++ {
+		letter, _ := ConvertColumnIndexToLetter(int64(column))
+		cells := fmt.Sprintf("%s%d:%s%d", letter, FirstCheckRow, letter, totalsRow-1)
+
+		switch {
+		case column <= lastMonthColumn || column == summaryColumn:
+			totals = append(totals, fmt.Sprintf(`=IFERROR(AVERAGE(%s), "")`, cells))
+		case column == summaryColumn+1:
+			totals = append(totals, fmt.Sprintf(`=IF(COUNT(%s)=0, "", MIN(%s))`, cells, cells))
+		case column == summaryColumn+2:
+			totals = append(totals, fmt.Sprintf(`=SUM(%s)`, cells))
+		default:
+			totals = append(totals, "")
+		}
+	}
+	data = append(data, &sheets.ValueRange{
+		Range:  SheetRange(sheetName, fmt.Sprintf("A%d", totalsRow)),
+		Values: [][]any{totals},
+	})
+
+	return data
+}
+
+// summaryFormatRequests returns the requests that give the summary columns and the totals row their
+// number formats, and make the totals row bold. The columns are 0-based.
+func summaryFormatRequests(sheetID int64, rowCount, totalsIndex, lastMonthColumn, summaryColumn, precision int) []*sheets.Request {
+	uptimeFormat := &sheets.CellFormat{NumberFormat: UptimeNumberFormat(precision)}
+	countFormat := &sheets.CellFormat{NumberFormat: &sheets.NumberFormat{Type: "NUMBER", Pattern: "0"}}
+	totalsRow := int64(FirstCheckRow - 1 + totalsIndex)
+
+	repeat := func(gridRange *sheets.GridRange, format *sheets.CellFormat, fields string) *sheets.Request {
+		gridRange.SheetId = sheetID
+		return &sheets.Request{
+			RepeatCell: &sheets.RepeatCellRequest{
+				Range:  gridRange,
+				Cell:   &sheets.CellData{UserEnteredFormat: format},
+				Fields: fields,
+			},
+		}
+	}
+
+	return []*sheets.Request{
+		repeat(&sheets.GridRange{
+			StartRowIndex:    FirstCheckRow - 1,
+			EndRowIndex:      int64(FirstCheckRow - 1 + rowCount),
+			StartColumnIndex: int64(summaryColumn),
+			EndColumnIndex:   int64(summaryColumn + 2),
+		}, uptimeFormat, "userEnteredFormat.numberFormat"),
+		repeat(&sheets.GridRange{
+			StartRowIndex:    FirstCheckRow - 1,
+			EndRowIndex:      int64(FirstCheckRow - 1 + rowCount),
+			StartColumnIndex: int64(summaryColumn + 2),
+			EndColumnIndex:   int64(summaryColumn + 3),
+		}, countFormat, "userEnteredFormat.numberFormat"),
+		repeat(&sheets.GridRange{
+			StartRowIndex:    totalsRow,
+			EndRowIndex:      totalsRow + 1,
+			StartColumnIndex: 1,
+			EndColumnIndex:   int64(lastMonthColumn + 1),
+		}, uptimeFormat, "userEnteredFormat.numberFormat"),
+		repeat(&sheets.GridRange{
+			StartRowIndex:    totalsRow,
+			EndRowIndex:      totalsRow + 1,
+			StartColumnIndex: 0,
+			EndColumnIndex:   int64(summaryColumn + len(SummaryHeaders)),
+		}, &sheets.CellFormat{TextFormat: &sheets.TextFormat{Bold: true}}, "userEnteredFormat.textFormat.bold"),
+	}
+}
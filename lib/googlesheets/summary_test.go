@@ -0,0 +1,50 @@
+package googlesheets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_findChecksEnd(t *testing.T) {
+	assert.Equal(t, 2, findChecksEnd([][]any{{"Alpha"}, {"Beta"}}))
+	assert.Equal(t, 1, findChecksEnd([][]any{{"Alpha"}, {SummaryTotalsLabel}, {RetiredSectionLabel}, {"Beta"}}))
+	assert.Equal(t, 1, findChecksEnd([][]any{{"Alpha"}, {RetiredSectionLabel}, {"Beta"}}))
+}
+
+func Test_buildSummaryData(t *testing.T) {
+	rows := [][]any{{"Alpha"}, {"Beta"}, {SummaryTotalsLabel}, {RetiredSectionLabel}, {"Gamma"}}
+	targetFor := func(label string) float64 {
+		if label == "Beta" {
+			return 99.5
+		}
+		return 99.9
+	}
+
+	// Months in B to D, and the summary block starts at E
+	data := buildSummaryData("2024", rows, 2, 3, 4, targetFor)
+	assert.Len(t, data, 5)
+
+	assert.Equal(t, "'2024'!E2", data[0].Range)
+	assert.Equal(t, [][]any{{"Year avg", "Worst month", "Months below SLO"}}, data[0].Values)
+
+	assert.Equal(t, "'2024'!E4", data[2].Range)
+	assert.Equal(t, [][]any{{
+		`=IFERROR(AVERAGE(B4:D4), "")`,
+		`=IF(COUNT(B4:D4)=0, "", MIN(B4:D4))`,
+		`=COUNTIF(B4:D4, "<99.5")`,
+	}}, data[2].Values)
+
+	assert.Equal(t, "'2024'!E7", data[3].Range, "retired checks should have a summary too")
+
+	assert.Equal(t, "'2024'!A5", data[4].Range)
+	assert.Equal(t, [][]any{{
+		SummaryTotalsLabel,
+		`=IFERROR(AVERAGE(B3:B4), "")`,
+		`=IFERROR(AVERAGE(C3:C4), "")`,
+		`=IFERROR(AVERAGE(D3:D4), "")`,
+		`=IFERROR(AVERAGE(E3:E4), "")`,
+		`=IF(COUNT(F3:F4)=0, "", MIN(F3:F4))`,
+		`=SUM(G3:G4)`,
+	}}, data[4].Values)
+}
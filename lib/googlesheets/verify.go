@@ -0,0 +1,311 @@
+package googlesheets
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/sheets/v4"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
+)
+
+const (
+	DiscrepancyMissing   = "missing"   // NodePing has an uptime for the check, but its cell is empty or it has no row
+	DiscrepancyDifferent = "different" // The cell's uptime differs from NodePing's by more than the tolerance
+	DiscrepancyUnknown   = "unknown"   // The cell has an uptime, but the row's check isn't in the contact group
+
+	// DefaultVerifyTolerance is the largest difference (in percentage points) allowed between an
+	// archived uptime and NodePing's
+	DefaultVerifyTolerance = 0.001
+)
+
+// Discrepancy is a cell of a year tab that doesn't match NodePing
+type Discrepancy struct {
+	Kind     string  `json:"kind"` // One of DiscrepancyMissing etc.
+	Month    string  `json:"month"`
+	Label    string  `json:"label"`
+	CheckID  string  `json:"checkId,omitempty"`
+	Row      int     `json:"row,omitempty"` // The check's row (1-based), if it has one
+	Column   string  `json:"column"`        // The letter of the month's column
+	Value    string  `json:"value,omitempty"`
+	Expected float64 `json:"expected,omitempty"` // NodePing's uptime, rounded, unless the check is unknown
+
+	column int // The month's (0-based) column
+}
+
+// Verification is the result of comparing a year tab with NodePing
+type Verification struct {
+	Sheet         string        `json:"sheet"`
+	Months        []string      `json:"months"` // The months that were compared
+	Discrepancies []Discrepancy `json:"discrepancies"`
+}
+
+// VerifyYearTab reads back the uptimes in a year tab of the wide layout, gets the uptimes of the
+// contact group's checks for the same months from NodePing, and returns the cells that are missing,
+// that differ by more than the tolerance from NodePing's uptime rounded to the precision, or that belong
+// to checks that aren't in the contact group. Retired checks aren't compared, whether their rows were
+// moved below the totals row (see UpdateSummary) or marked where they are (see MarkRetiredChecks).
+func VerifyYearTab(group string, npConfig nodeping.ClientConfig, precision int, tolerance float64, sheetsData SheetsData) (Verification, error) {
+	sheetName := sheetsData.SheetName
+	verification := Verification{Sheet: sheetName}
+
+	resp, err := sheetsData.Service.Spreadsheets.Values.Get(sheetsData.SpreadsheetID, SheetRange(sheetName, "")).
+		ValueRenderOption("UNFORMATTED_VALUE").
+		Do()
+	if err != nil {
+		return verification, fmt.Errorf("error getting the values of %s: %w", sheetName, err)
+	}
+
+	checkRows, err := GetCheckRowsFromMetadata(sheetsData)
+	if err != nil {
+		return verification, err
+	}
+
+	retiredRows, err := GetRowMetadata(RetiredMetadataKey, sheetsData)
+	if err != nil {
+		return verification, err
+	}
+
+	period, err := archivedPeriod(resp.Values, sheetsData.MonthFormat)
+	if err != nil {
+		return verification, fmt.Errorf("unable to verify %s: %w", sheetName, err)
+	}
+
+	results, err := nodeping.GetUptimesForContactGroup(npConfig, group, period)
+	if err != nil {
+		return verification, fmt.Errorf("error getting NodePing results: %w", err)
+	}
+	if len(results.Errors) > 0 {
+		return verification, fetchError(results.Errors)
+	}
+
+	months := GetMonthUptimes(results)
+	for _, month := range months {
+		verification.Months = append(verification.Months, month.Month+" "+month.Year)
+	}
+	verification.Discrepancies = compareYearTab(resp.Values, checkRows, retiredRows, results.Checks, months, precision, tolerance, sheetsData.MonthFormat)
+
+	return verification, nil
+}
+
+// archivedPeriod returns the period of whole months from the first to the last month heading of a
+// year tab, given its values from A1 and how the month headings are written
+func archivedPeriod(cells [][]any, format MonthFormat) (nodeping.Period, error) {
+	var first, last time.Time
+	for _, column := range monthColumns(cells, format) {
+		if first.IsZero() || column.month.Before(first) {
+			first = column.month
+		}
+		if column.month.After(last) {
+			last = column.month
+		}
+	}
+	if first.IsZero() {
+		return nodeping.Period{}, errors.New("there are no month columns")
+	}
+
+	return nodeping.GetMonthRangePeriod(first.Format(nodeping.MonthLayout) + ":" + last.Format(nodeping.MonthLayout))
+}
+
+// monthColumn is a month's (0-based) column in a year tab
+type monthColumn struct {
+	index int
+	month time.Time
+}
+
+// monthColumns returns the month columns of a year tab, given its values from A1 and how the month
+// headings are written
+func monthColumns(cells [][]any, format MonthFormat) []monthColumn {
+	if len(cells) < MonthHeaderRow {
+		return nil
+	}
+
+	var columns []monthColumn
+	for i, header := range cells[MonthHeaderRow-1] {
+		if i == 0 {
+			continue
+		}
+		if month, err := format.Parse(fmt.Sprintf("%v", header)); err == nil {
+			columns = append(columns, monthColumn{index: i, month: month})
+		}
+	}
+	return columns
+}
+
+// compareYearTab returns the discrepancies between a year tab, given its values from A1, the check
+// IDs of its rows and its retired rows, and NodePing's checks and uptimes. Checks are matched with rows
+// by their IDs, and then by their names, like EnsureCheckRowExists. Retired rows are left out, like the
+// rows below the totals.
+func compareYearTab(
+	cells [][]any,
+	checkRows map[int]string,
+	retiredRows map[int]*sheets.DeveloperMetadata,
+	checks []nodeping.Check,
+	months []MonthUptimes,
+	precision int,
+	tolerance float64,
+	format MonthFormat,
+) []Discrepancy {
+	var labels [][]any
+	for i := FirstCheckRow - 1; i < len(cells); i++ {
+		labels = append(labels, cells[i][:min(len(cells[i]), 1)])
+	}
+	checksEnd := FirstCheckRow + findChecksEnd(labels)
+
+	cell := func(row, column int) string {
+		if row < 1 || row > len(cells) || column >= len(cells[row-1]) {
+			return ""
+		}
+		return strings.TrimSpace(fmt.Sprintf("%v", cells[row-1][column]))
+	}
+
+	isCheckRow := func(row int) bool {
+		return row >= FirstCheckRow && row < checksEnd && retiredRows[row] == nil
+	}
+
+	// The row of each check, and the rows that belong to a check in the contact group
+	rowsByCheck := map[string]int{}
+	known := map[int]bool{}
+	for _, check := range checks {
+		row := 0
+		for r, id := range checkRows {
+			if id == check.ID && isCheckRow(r) {
+				row = r
+			}
+		}
+		for r := FirstCheckRow; row == 0 && r < checksEnd; r++ {
+			if isCheckRow(r) && strings.EqualFold(cell(r, 0), check.Label) && (checkRows[r] == "" || checkRows[r] == check.ID) {
+				row = r
+			}
+		}
+		if row > 0 {
+			rowsByCheck[check.ID] = row
+			known[row] = true
+		}
+	}
+
+	columns := map[string]int{}
+	for _, column := range monthColumns(cells, format) {
+		columns[column.month.Format(DefaultMonthLayout)] = column.index
+	}
+
+	var discrepancies []Discrepancy
+	for _, month := range months {
+		monthHeader := month.Month + " " + month.Year
+		column, ok := columns[monthHeader]
+		if !ok {
+			continue
+		}
+		letter, _ := ConvertColumnIndexToLetter(int64(column))
+
+		for _, uptime := range month.Uptimes {
+			d := Discrepancy{
+				Month:    monthHeader,
+				Label:    uptime.Label,
+				CheckID:  uptime.CheckID,
+				Row:      rowsByCheck[uptime.CheckID],
+				Column:   letter,
+				Expected: roundToPrecision(uptime.Uptime, precision),
+				column:   column,
+			}
+			d.Value = cell(d.Row, column)
+
+			value, isNumber := parseUptimeCell(d.Value)
+			switch {
+			case d.Row == 0 || d.Value == "":
+				d.Kind = DiscrepancyMissing
+			case !isNumber || math.Abs(value-d.Expected) > tolerance:
+				d.Kind = DiscrepancyDifferent
+			default:
+				continue
+			}
+			discrepancies = append(discrepancies, d)
+		}
+
+		for row := FirstCheckRow; row < checksEnd; row++ {
+			if known[row] || !isCheckRow(row) || cell(row, 0) == "" || cell(row, column) == "" {
+				continue
+			}
+			discrepancies = append(discrepancies, Discrepancy{
+				Kind:    DiscrepancyUnknown,
+				Month:   monthHeader,
+				Label:   cell(row, 0),
+				CheckID: checkRows[row],
+				Row:     row,
+				Column:  letter,
+				Value:   cell(row, column),
+				column:  column,
+			})
+		}
+	}
+
+	return discrepancies
+}
+
+// parseUptimeCell returns the uptime in a cell, which is a number, or was written as text like
+// "99.95%" by earlier versions
+func parseUptimeCell(value string) (float64, bool) {
+	uptime, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+	return uptime, err == nil
+}
+
+// String returns the verification as text, with a line for each discrepancy
+func (v Verification) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Compared %d months of %q with NodePing\n", len(v.Months), v.Sheet)
+
+	for _, d := range v.Discrepancies {
+		cell := fmt.Sprintf("%s%d", d.Column, d.Row)
+		if d.Row == 0 {
+			cell = d.Column + "?"
+		}
+
+		switch d.Kind {
+		case DiscrepancyMissing:
+			fmt.Fprintf(&b, "missing   %-6s %s (%s): NodePing has %s\n", cell, d.Label, d.Month, formatExpected(d.Expected))
+		case DiscrepancyDifferent:
+			fmt.Fprintf(&b, "different %-6s %s (%s): %s, but NodePing has %s\n", cell, d.Label, d.Month, d.Value, formatExpected(d.Expected))
+		case DiscrepancyUnknown:
+			fmt.Fprintf(&b, "unknown   %-6s %s (%s): %s, but the check isn't in the contact group\n", cell, d.Label, d.Month, d.Value)
+		}
+	}
+
+	fmt.Fprintf(&b, "%d discrepancies\n", len(v.Discrepancies))
+	return b.String()
+}
+
+// formatExpected formats NodePing's (rounded) uptime without trailing zeros, like the cell values
+func formatExpected(uptime float64) string {
+	return strconv.FormatFloat(uptime, 'f', -1, 64)
+}
+
+// FixDiscrepancies writes NodePing's uptime to each of the cells that differ from it, and to the
+// empty cells of checks that have a row, all in one request. Checks without a row, and unknown checks,
+// are left alone, since a run adds the missing rows, and only a person can tell what the unknown ones
+// are. It returns the number of cells written.
+func FixDiscrepancies(discrepancies []Discrepancy, precision int, sheetsData SheetsData) (int, error) {
+	var requests []*sheets.Request
+	for _, d := range discrepancies {
+		if d.Kind == DiscrepancyUnknown || d.Row == 0 {
+			continue
+		}
+		requests = append(requests, newUptimeCellRequest(int64(d.Row), int64(d.column), d.Expected, precision, sheetsData.SheetID))
+		slog.Info("fixing uptime", "cell", fmt.Sprintf("%s%d", d.Column, d.Row), "check", d.Label, "month", d.Month, "was", d.Value)
+	}
+	if len(requests) == 0 {
+		return 0, nil
+	}
+
+	rbb := &sheets.BatchUpdateSpreadsheetRequest{Requests: requests}
+	_, err := sheetsData.Service.Spreadsheets.BatchUpdate(sheetsData.SpreadsheetID, rbb).Context(context.Background()).Do()
+	if err != nil {
+		return 0, fmt.Errorf("unable to fix the uptimes in %s: %w", sheetsData.SheetName, err)
+	}
+	return len(requests), nil
+}
@@ -0,0 +1,114 @@
+package googlesheets
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/sheets/v4"
+
+	"github.com/sil-org/app-monitoring-archiver/lib/nodeping"
+)
+
+func Test_archivedPeriod(t *testing.T) {
+	cells := [][]any{
+		{"", "Uptime Percent"},
+		{"Checks", "March 2024", "January 2024", "February 2024", "Year avg"},
+	}
+	period, err := archivedPeriod(cells, MonthFormat{})
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), period.From)
+	assert.Equal(t, time.March, period.To.Month())
+
+	_, err = archivedPeriod([][]any{{"", "Uptime Percent"}, {"Checks"}}, MonthFormat{})
+	assert.EqualError(t, err, "there are no month columns")
+}
+
+func Test_compareYearTab(t *testing.T) {
+	cells := [][]any{
+		{"", "Uptime Percent"},
+		{"Checks", "January 2024", "February 2024"},
+		{"API", 99.5, 100.0},
+		{"Old check", 98.0},
+		{"Renamed", 97.0, 97.0},
+		{"Website", "99.9%", ""},
+		{SummaryTotalsLabel, 98.6, 99.0},
+		{RetiredSectionLabel},
+		{"Retired check", 90.0},
+	}
+	checkRows := map[int]string{5: "id3"}
+	checks := []nodeping.Check{{ID: "id1", Label: "API"}, {ID: "id2", Label: "Website"}, {ID: "id3", Label: "New name"}}
+	months := []MonthUptimes{
+		{Month: "January", Year: "2024", Uptimes: []CheckUptime{
+			{CheckID: "id1", Label: "API", Uptime: 99.5004},
+			{CheckID: "id3", Label: "New name", Uptime: 97},
+			{CheckID: "id2", Label: "Website", Uptime: 99.9},
+		}},
+		{Month: "February", Year: "2024", Uptimes: []CheckUptime{
+			{CheckID: "id1", Label: "API", Uptime: 99.8},
+			{CheckID: "id2", Label: "Website", Uptime: 99.0},
+			{CheckID: "id4", Label: "Brand new", Uptime: 100},
+		}},
+		{Month: "March", Year: "2024", Uptimes: []CheckUptime{{CheckID: "id1", Label: "API", Uptime: 1}}},
+	}
+
+	discrepancies := compareYearTab(cells, checkRows, nil, checks, months, 3, DefaultVerifyTolerance, MonthFormat{})
+	assert.Equal(t, []Discrepancy{
+		{Kind: DiscrepancyUnknown, Month: "January 2024", Label: "Old check", Row: 4, Column: "B", Value: "98", column: 1},
+		{Kind: DiscrepancyDifferent, Month: "February 2024", Label: "API", CheckID: "id1", Row: 3, Column: "C", Value: "100", Expected: 99.8, column: 2},
+		{Kind: DiscrepancyMissing, Month: "February 2024", Label: "Website", CheckID: "id2", Row: 6, Column: "C", Expected: 99, column: 2},
+		{Kind: DiscrepancyMissing, Month: "February 2024", Label: "Brand new", CheckID: "id4", Column: "C", Expected: 100, column: 2},
+	}, discrepancies, "rows should be matched by ID and then by name, and the rows below the totals ignored")
+}
+
+func Test_compareYearTab_retiredRows(t *testing.T) {
+	cells := [][]any{
+		{"", "Uptime Percent"},
+		{"Checks", "January 2024"},
+		{"Website", 95.0},
+		{"API", 99.5},
+		{"Gone", 98.0},
+		{"Website", 99.9},
+		{SummaryTotalsLabel, 98.1},
+	}
+	checkRows := map[int]string{3: "old-id", 5: "gone-id"}
+	retiredRows := map[int]*sheets.DeveloperMetadata{
+		3: {MetadataKey: RetiredMetadataKey, MetadataValue: "2024-02-01"},
+		5: {MetadataKey: RetiredMetadataKey, MetadataValue: "2024-02-01"},
+	}
+	checks := []nodeping.Check{{ID: "id1", Label: "API"}, {ID: "id2", Label: "Website"}}
+	months := []MonthUptimes{{Month: "January", Year: "2024", Uptimes: []CheckUptime{
+		{CheckID: "id1", Label: "API", Uptime: 99.5},
+		{CheckID: "id2", Label: "Website", Uptime: 99.9},
+	}}}
+
+	discrepancies := compareYearTab(cells, checkRows, retiredRows, checks, months, 3, DefaultVerifyTolerance, MonthFormat{})
+	assert.Empty(t, discrepancies,
+		"rows retired in place should be neither unknown nor matched by name with a check in the contact group")
+
+	discrepancies = compareYearTab(cells, checkRows, nil, checks, months, 3, DefaultVerifyTolerance, MonthFormat{})
+	assert.Equal(t, []Discrepancy{
+		{Kind: DiscrepancyUnknown, Month: "January 2024", Label: "Website", CheckID: "old-id", Row: 3, Column: "B", Value: "95", column: 1},
+		{Kind: DiscrepancyUnknown, Month: "January 2024", Label: "Gone", CheckID: "gone-id", Row: 5, Column: "B", Value: "98", column: 1},
+	}, discrepancies, "without the retired metadata, the same rows are unknown")
+}
+
+func TestVerification_String(t *testing.T) {
+	verification := Verification{
+		Sheet:  "2024",
+		Months: []string{"January 2024", "February 2024"},
+		Discrepancies: []Discrepancy{
+			{Kind: DiscrepancyMissing, Month: "February 2024", Label: "Brand new", Column: "C", Expected: 100},
+			{Kind: DiscrepancyDifferent, Month: "February 2024", Label: "API", Row: 3, Column: "C", Value: "100", Expected: 99.8},
+			{Kind: DiscrepancyUnknown, Month: "January 2024", Label: "Old check", Row: 4, Column: "B", Value: "98"},
+		},
+	}
+
+	want := `Compared 2 months of "2024" with NodePing
+missing   C?     Brand new (February 2024): NodePing has 100
+different C3     API (February 2024): 100, but NodePing has 99.8
+unknown   B4     Old check (January 2024): 98, but the check isn't in the contact group
+3 discrepancies
+`
+	assert.Equal(t, want, verification.String())
+}
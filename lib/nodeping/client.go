@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
+	"math"
 	"net/http"
 	"net/url"
 	"sort"
@@ -14,12 +16,33 @@ import (
 const (
 	DefaultBaseURL = "https://api.nodeping.com/api/1"
 	Version        = "0.0.1"
+
+	// IntervalDays requests one uptime entry per day instead of the default one per month
+	IntervalDays = "days"
+
+	// EventTypeDown is the type of event that marks the start (and end) of an outage
+	EventTypeDown = "down"
+
+	// EventsPageSize is the number of events asked for in each GetEvents request. NodePing only
+	// returns a limited number of events per request, so a check with more is paged through.
+	EventsPageSize = 500
+
+	// TotalKey is the key of the uptime entry that covers the whole period
+	TotalKey = "total"
+
+	// DefaultUptimeTolerance is the largest difference (in percentage points) allowed between
+	// NodePing's rounded uptime value and the one computed from the Enabled and Down values
+	DefaultUptimeTolerance = 0.001
 )
 
 // ClientConfig type includes configuration options for NodePing client.
 type ClientConfig struct {
 	BaseURL string
 	Token   string
+
+	// The allowed difference between NodePing's and the computed uptime (DefaultUptimeTolerance if
+	// nil, so that 0 can be set)
+	UptimeTolerance *float64
 }
 
 // Client holds config and provides methods for various api calls
@@ -42,6 +65,13 @@ func New(config ClientConfig) (*Client, error) {
 		client.Config.BaseURL = DefaultBaseURL
 	}
 
+	if config.UptimeTolerance == nil {
+		tolerance := DefaultUptimeTolerance
+		client.Config.UptimeTolerance = &tolerance
+	} else if *config.UptimeTolerance < 0 {
+		return nil, fmt.Errorf("the uptime tolerance can't be negative: %v", *config.UptimeTolerance)
+	}
+
 	client.httpClient = &http.Client{Timeout: time.Second * 30}
 
 	return &client, nil
@@ -92,7 +122,13 @@ func (c *Client) GetCheck(id string) (CheckResponse, error) {
 
 // GetUptime retrieves the uptime entries for a certain check within an optional date range (by Timestamp with microseconds)
 func (c *Client) GetUptime(id string, period Period) (map[string]UptimeResponse, error) {
-	path := GetUptimePath(id, period)
+	return c.GetUptimeWithInterval(id, period, "")
+}
+
+// GetUptimeWithInterval is like GetUptime, but with an optional interval (e.g. IntervalDays)
+// which determines the granularity of the entries other than "total"
+func (c *Client) GetUptimeWithInterval(id string, period Period, interval string) (map[string]UptimeResponse, error) {
+	path := GetUptimePathWithInterval(id, period, interval)
 
 	var listObj map[string]UptimeResponse
 
@@ -111,6 +147,73 @@ func (c *Client) GetUptime(id string, period Period) (map[string]UptimeResponse,
 	return listObj, nil
 }
 
+// GetEvents retrieves the up and down events for a certain check within an optional date range.
+// NodePing returns the newest events first, EventsPageSize at a time, so a full page is followed by
+// a request for the events that started before the oldest one in it. If a full page can't be paged
+// through that way, it fails rather than return some of the events.
+func (c *Client) GetEvents(id string, period Period) ([]EventResponse, error) {
+	var list []EventResponse
+
+	if c.MockResults != "" {
+		err := json.Unmarshal([]byte(c.MockResults), &list)
+		if err != nil {
+			return nil, err
+		}
+		return list, nil
+	}
+
+	seen := map[string]bool{}
+	page := period
+	for {
+		var events []EventResponse
+		if err := c.sendGetRequest(GetEventsPath(id, page, EventsPageSize), &events); err != nil {
+			return nil, err
+		}
+
+		added := false
+		for _, event := range events {
+			if !seen[event.ID] {
+				seen[event.ID] = true
+				list = append(list, event)
+				added = true
+			}
+		}
+
+		if len(events) < EventsPageSize {
+			return list, nil
+		}
+
+		newestFirst := sort.SliceIsSorted(events, func(i, j int) bool { return events[i].Start > events[j].Start })
+		if !added || !newestFirst {
+			return nil, fmt.Errorf("unable to get all the events of check %s: NodePing returned a full page of %d that can't be paged through", id, EventsPageSize)
+		}
+		page.To = time.UnixMilli(events[len(events)-1].Start).UTC()
+	}
+}
+
+// GetOutages retrieves the periods of time within the period that a certain check was down
+func (c *Client) GetOutages(id string, period Period) ([]Interval, error) {
+	events, err := c.GetEvents(id, period)
+	if err != nil {
+		return nil, err
+	}
+
+	var outages []Interval
+	for _, event := range events {
+		if event.Type != EventTypeDown {
+			continue
+		}
+
+		outage := Interval{Start: time.UnixMilli(event.Start).UTC(), End: period.To}
+		if event.End > 0 {
+			outage.End = time.UnixMilli(event.End).UTC()
+		}
+		outages = append(outages, outage)
+	}
+
+	return outages, nil
+}
+
 // ListContactGroups retrieves the list of Contact Groups
 func (c *Client) ListContactGroups() (map[string]ContactGroupResponse, error) {
 	var listObj map[string]ContactGroupResponse
@@ -150,50 +253,120 @@ func (c *Client) GetContactGroupIDFromName(contactGroupName string) (string, err
 	return cgID, nil
 }
 
-func (c *Client) GetCheckIDsAndLabels(id string) ([]string, map[string]string, error) {
-	checkIDs := map[string]string{}
-	var checkLabels []string
+// GetChecksForContactGroup returns the checks that have a notification set to the contact group,
+// sorted by label (and then by ID, since labels don't have to be unique)
+func (c *Client) GetChecksForContactGroup(id string) ([]Check, error) {
+	var groupChecks []Check
 
 	checks, err := c.ListChecks()
 	if err != nil {
-		return checkLabels, checkIDs, err
+		return nil, err
 	}
 
 	for _, check := range checks {
 		// Notifications is a list of maps with the contactGroup ID as keys
 		for _, notification := range check.Notifications {
-			foundOne := false
-			for nKey := range notification {
-				if nKey == id {
-					checkIDs[check.Label] = check.ID
-					checkLabels = append(checkLabels, check.Label)
-					foundOne = true
-					break
-				}
-			}
-			if foundOne {
+			if _, ok := notification[id]; ok {
+				groupChecks = append(groupChecks, Check{
+					ID:       check.ID,
+					Label:    check.Label,
+					Type:     check.Type,
+					Target:   check.Parameters.Target,
+					Interval: check.Interval,
+				})
 				break
 			}
 		}
 	}
 
-	sort.Strings(checkLabels)
-	return checkLabels, checkIDs, nil
+	sort.Slice(groupChecks, func(i, j int) bool {
+		if groupChecks[i].Label != groupChecks[j].Label {
+			return groupChecks[i].Label < groupChecks[j].Label
+		}
+		return groupChecks[i].ID < groupChecks[j].ID
+	})
+	return groupChecks, nil
 }
 
-func (c *Client) GetUptimesForChecks(checkIDs map[string]string, period Period) map[string]float32 {
-	uptimes := map[string]float32{}
+// GetChecksForContactGroupName is like GetChecksForContactGroup, but takes the contact group's name
+func (c *Client) GetChecksForContactGroupName(group string) ([]Check, error) {
+	cgID, err := c.GetContactGroupIDFromName(group)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.GetChecksForContactGroup(cgID)
+}
+
+// GetUptimesForChecks retrieves the "total" uptime entry for each of the checks, keyed by check ID,
+// and the errors of the checks whose uptimes couldn't be retrieved, also keyed by check ID
+func (c *Client) GetUptimesForChecks(checkIDs map[string]string, period Period) (map[string]UptimeResponse, map[string]error) {
+	uptimes := map[string]UptimeResponse{}
+
+	entries, errs := c.GetMonthlyUptimesForChecks(checkIDs, period)
+	for checkID, checkEntries := range entries {
+		uptimes[checkID] = checkEntries[TotalKey]
+	}
+
+	return uptimes, errs
+}
+
+// GetMonthlyUptimesForChecks retrieves all the uptime entries for each of the checks, keyed by check ID
+// and then by month (e.g. "2024-01") or TotalKey, and the errors of the checks whose uptimes couldn't
+// be retrieved, keyed by check ID
+func (c *Client) GetMonthlyUptimesForChecks(checkIDs map[string]string, period Period) (map[string]map[string]UptimeResponse, map[string]error) {
+	uptimes, errs := c.getUptimeEntriesForChecks(checkIDs, period, "")
+
+	for checkID, entries := range uptimes {
+		c.CheckUptimeConsistency(checkID, entries[TotalKey])
+	}
+
+	return uptimes, errs
+}
+
+// GetDailyUptimesForChecks retrieves the uptime for each day of the period for each of the checks,
+// keyed by check ID and then by day (e.g. "2024-01-31"), and the errors of the checks whose uptimes
+// couldn't be retrieved, keyed by check ID
+func (c *Client) GetDailyUptimesForChecks(checkIDs map[string]string, period Period) (map[string]map[string]UptimeResponse, map[string]error) {
+	uptimes, errs := c.getUptimeEntriesForChecks(checkIDs, period, IntervalDays)
+
+	for _, entries := range uptimes {
+		delete(entries, TotalKey)
+	}
+
+	return uptimes, errs
+}
+
+func (c *Client) getUptimeEntriesForChecks(checkIDs map[string]string, period Period, interval string) (map[string]map[string]UptimeResponse, map[string]error) {
+	uptimes := map[string]map[string]UptimeResponse{}
+	errs := map[string]error{}
 
 	for _, checkID := range checkIDs {
-		nextUptime, err := c.GetUptime(checkID, period)
+		nextUptime, err := c.GetUptimeWithInterval(checkID, period, interval)
 		if err != nil {
-			fmt.Printf("Error getting uptime for check ID %s.\n%s\n", checkID, err.Error())
+			slog.Error("unable to get the uptime of a NodePing check", "checkID", checkID, "error", err)
+			errs[checkID] = fmt.Errorf("unable to get the uptime of check %s from NodePing: %w", checkID, err)
 			continue
 		}
-		uptimes[checkID] = nextUptime["total"].Uptime
+		uptimes[checkID] = nextUptime
 	}
 
-	return uptimes
+	return uptimes, errs
+}
+
+// CheckUptimeConsistency logs a warning if NodePing's uptime value differs from the one computed
+// from the Enabled and Down values by more than the configured tolerance. It returns the difference.
+func (c *Client) CheckUptimeConsistency(checkID string, uptime UptimeResponse) float64 {
+	diff := math.Abs(uptime.ComputedUptime() - float64(uptime.Uptime))
+	if diff > *c.Config.UptimeTolerance {
+		slog.Warn("NodePing uptime differs from computed uptime",
+			"checkID", checkID,
+			"nodeping", uptime.Uptime,
+			"computed", uptime.ComputedUptime(),
+			"tolerance", *c.Config.UptimeTolerance,
+		)
+	}
+	return diff
 }
 
 func (c *Client) sendGetRequest(path string, v any) error {
@@ -227,42 +400,129 @@ func (c *Client) sendGetRequest(path string, v any) error {
 	return nil
 }
 
-func GetUptimesForContactGroup(token, group string, period Period) (UptimeResults, error) {
+func GetUptimesForContactGroup(config ClientConfig, group string, period Period) (UptimeResults, error) {
 	var emptyResults UptimeResults
-	npClient, err := New(ClientConfig{Token: token})
+	npClient, err := New(config)
 	if err != nil {
 		return emptyResults, fmt.Errorf("error initializing cli: %w", err)
 	}
 
-	cgID, err := npClient.GetContactGroupIDFromName(group)
+	checks, err := npClient.GetChecksForContactGroupName(group)
 	if err != nil {
 		return emptyResults, err
 	}
 
-	checkLabels, checkIDs, err := npClient.GetCheckIDsAndLabels(cgID)
+	uptimes, errs := npClient.GetMonthlyUptimesForChecks(checkIDsByID(checks), period)
+	uptimesByID := map[string]float64{}
+	responsesByID := map[string]UptimeResponse{}
+	monthlyResponsesByID := map[string]map[string]UptimeResponse{}
+
+	for _, check := range checks {
+		entries, ok := uptimes[check.ID]
+		if !ok {
+			continue
+		}
+		total := entries[TotalKey]
+		uptimesByID[check.ID] = total.ComputedUptime()
+		responsesByID[check.ID] = total
+
+		months := map[string]UptimeResponse{}
+		for month, uptime := range entries {
+			if month != TotalKey {
+				months[month] = uptime
+			}
+		}
+		monthlyResponsesByID[check.ID] = months
+	}
+
+	results := UptimeResults{
+		Checks:           checks,
+		Uptimes:          uptimesByID,
+		Responses:        responsesByID,
+		MonthlyResponses: monthlyResponsesByID,
+		Errors:           errs,
+		StartTime:        period.From.Unix(),
+		EndTime:          period.To.Unix(),
+	}
+
+	return results, nil
+}
+
+// GetDailyUptimesForContactGroup retrieves the uptime for each day of the period for each check
+// associated with the contact group
+func GetDailyUptimesForContactGroup(config ClientConfig, group string, period Period) (DailyUptimeResults, error) {
+	var emptyResults DailyUptimeResults
+	npClient, err := New(config)
+	if err != nil {
+		return emptyResults, fmt.Errorf("error initializing cli: %w", err)
+	}
+
+	checks, err := npClient.GetChecksForContactGroupName(group)
 	if err != nil {
 		return emptyResults, err
 	}
 
-	uptimes := npClient.GetUptimesForChecks(checkIDs, period)
-	uptimesByLabel := map[string]float32{}
+	uptimes, errs := npClient.GetDailyUptimesForChecks(checkIDsByID(checks), period)
+	uptimesByID := map[string]map[string]float64{}
 
-	for _, label := range checkLabels {
-		uptimesByLabel[label] = uptimes[checkIDs[label]]
+	for _, check := range checks {
+		if _, ok := uptimes[check.ID]; !ok {
+			continue
+		}
+		days := map[string]float64{}
+		for day, uptime := range uptimes[check.ID] {
+			days[day] = uptime.ComputedUptime()
+		}
+		uptimesByID[check.ID] = days
 	}
 
-	results := UptimeResults{
-		CheckLabels: checkLabels,
-		Uptimes:     uptimesByLabel,
-		StartTime:   period.From.Unix(),
-		EndTime:     period.To.Unix(),
+	results := DailyUptimeResults{
+		Checks:    checks,
+		Uptimes:   uptimesByID,
+		Errors:    errs,
+		StartTime: period.From.Unix(),
+		EndTime:   period.To.Unix(),
 	}
 
 	return results, nil
 }
 
+// checkIDsByID converts a list of checks into the map form used by GetUptimesForChecks and the like,
+// keyed by ID rather than by label so that checks with the same label are kept apart
+func checkIDsByID(checks []Check) map[string]string {
+	checkIDs := map[string]string{}
+	for _, check := range checks {
+		checkIDs[check.ID] = check.ID
+	}
+	return checkIDs
+}
+
+// GetEventsPath assembles the path to use for a GetEvents request for up to limit events.
+func GetEventsPath(id string, period Period, limit int) string {
+	q := periodQuery(period)
+	q.Set("limit", strconv.Itoa(limit))
+
+	return fmt.Sprintf("/results/events/%s?%s", id, q.Encode())
+}
+
 // GetUptimePath assembles the path to use for a GetUptime request.
 func GetUptimePath(id string, period Period) string {
+	return GetUptimePathWithInterval(id, period, "")
+}
+
+// GetUptimePathWithInterval assembles the path to use for a GetUptimeWithInterval request.
+func GetUptimePathWithInterval(id string, period Period, interval string) string {
+	q := periodQuery(period)
+
+	if interval != "" {
+		q.Set("interval", interval)
+	}
+
+	return fmt.Sprintf("/results/uptime/%s?%s", id, q.Encode())
+}
+
+// periodQuery returns the start and end query parameters (in milliseconds) for the period
+func periodQuery(period Period) url.Values {
 	q := url.Values{}
 
 	if !period.From.IsZero() {
@@ -273,5 +533,5 @@ func GetUptimePath(id string, period Period) string {
 		q.Set("end", strconv.FormatInt(period.To.Unix()*1000, 10))
 	}
 
-	return fmt.Sprintf("/results/uptime/%s?%s", id, q.Encode())
+	return q
 }
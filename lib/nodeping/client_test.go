@@ -1,7 +1,11 @@
 package nodeping
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -355,80 +359,6 @@ func TestGetContactGroupIDFromName(t *testing.T) {
 	}
 }
 
-func TestGetCheckIDsAndLabels(t *testing.T) {
-	npClient, _ := New(ClientConfig{Token: "mock"})
-
-	npClient.MockResults = `
-{
-  "2018090614528ABCD-MNOPQRST":
-  {"_id":"2018090614528ABCD-MNOPQRST","customer_id":"2018090614528ABCD","label":"Example1","interval":5,
-    "notifications":[
-      {"AAAA5":
-        {"schedule":"All","delay":5}}         
-    ], 
-    "runlocations":false,"type":"HTTP","status":"assigned","modified":1543260813141,"enable":"active","public":false,"dep":false,
-    "parameters":
-      {"target":"https://example1.org/","ipv6":false,"follow":false,"threshold":30,"sens":2},
-    "created":1539715596937,"queue":"aaaaaaaa10","uuid":"aaaaaaa8-aaa4-aaa4-aaa4-aaaaaaaaaa11","firstdown":0,"state":1
-  },
-  "2018090614528ABCD-NOPQRSTU":
-  {"_id":"2018090614528ABCD-NOPQRSTU","customer_id":"2018090614528ABCD","label":"Example2","interval":3,
-    "notifications":[
-      {"2018090614528ABCD-B-BBBB5":
-        {"schedule":"All","delay":10}}
-    ],
-    "runlocations":false,"type":"HTTP","status":"assigned","modified":1543937160541,"enable":"active","public":false,"dep":false,
-    "parameters":
-      {"target":"https://example2.org/","ipv6":false,"follow":false,"threshold":30,"sens":2},
-     "created":1539715552868,"queue":"bbbbbbbb10","uuid":"bbbbbbb8-bbb4-bbb4-bbb4-bbbbbbbbbb11","firstdown":0,"state":1
-  },
-  "2018090614528ABCD-OPQRSTUV":
-  {"_id":"2018090614528ABCD-OPQRSTUV","customer_id":"2018090614528ABCD","label":"Example3","interval":1,
-    "notifications":[
-      {"2018090614528ABCD-C-CCCCC6":
-        {"schedule":"All","delay":5}}
-    ],
-    "runlocations":false,"type":"HTTP","status":"assigned","modified":1539715504508,"enable":"active","public":false,"dep":false,
-    "parameters":
-      {"target":"https://example3.org/home","ipv6":false,"follow":false,"threshold":30,"sens":2},
-    "created":1539715504508,"queue":"cccccccc10","uuid":"ccccccc8-ccc4-ccc4-ccc4-cccccccccc11","firstdown":0,"state":1
-  },
-  "2018090614528ABCD-PQRSTUVW":
-  {"_id":"2018090614528ABCD-PQRSTUVW","customer_id":"2018090614528ABCD","label":"Example4","interval":1,
-    "notifications":[
-      {"2018090614528ABCD-B-BBBB5":
-        {"schedule":"All","delay":5}},
-      {"EEEE5":
-        {"schedule":"All","delay":5}}
-    ],
-    "runlocations":false,"type":"HTTP","status":"assigned","modified":1543260719724,"enable":"active","public":false,"dep":false,
-    "parameters":
-       {"target":"https://example4.org/check","ipv6":false,"follow":false,"threshold":30,"sens":2},
-    "created":1539715451787,"queue":"dddddddd10","uuid":"ddddddd8-ddd4-ddd4-ddd4-dddddddddd11","firstdown":0,"state":1
-  }
-}
-`
-	cgID := "2018090614528ABCD-B-BBBB5"
-
-	checkLabels, checkIDs, _ := npClient.GetCheckIDsAndLabels(cgID)
-
-	expectedLabels := []string{"Example2", "Example4"}
-	if len(expectedLabels) != len(checkLabels) ||
-		expectedLabels[0] != checkLabels[0] ||
-		expectedLabels[1] != checkLabels[1] {
-
-		t.Errorf("Got wrong list of check labels. \nExpected %v\n  But got %v", expectedLabels, checkLabels)
-		return
-	}
-
-	resultsID := checkIDs[expectedLabels[1]]
-	expectedID := "2018090614528ABCD-PQRSTUVW"
-
-	if resultsID != expectedID {
-		t.Errorf("Got wrong ID for %s. Expected %s, but got %s", expectedLabels[1], expectedID, resultsID)
-	}
-}
-
 func TestGetUptimesForChecks(t *testing.T) {
 	npClient, _ := New(ClientConfig{Token: "mock"})
 
@@ -444,10 +374,11 @@ func TestGetUptimesForChecks(t *testing.T) {
 		"check1": "c1ID",
 		"check2": "c2ID",
 	}
-	uptimes := npClient.GetUptimesForChecks(checkIDs, Period{})
-	expected := map[string]float32{
-		"c1ID": 99.011,
-		"c2ID": 99.011,
+	uptimes, errs := npClient.GetUptimesForChecks(checkIDs, Period{})
+	assert.Empty(t, errs)
+	expected := map[string]UptimeResponse{
+		"c1ID": {Enabled: 4744902919, Down: 253073, Uptime: 99.011},
+		"c2ID": {Enabled: 4744902919, Down: 253073, Uptime: 99.011},
 	}
 
 	if len(uptimes) != 2 || uptimes["c1ID"] != expected["c1ID"] || uptimes["c2ID"] != expected["c2ID"] {
@@ -455,6 +386,60 @@ func TestGetUptimesForChecks(t *testing.T) {
 	}
 }
 
+func TestComputedUptime(t *testing.T) {
+	tests := []struct {
+		name   string
+		uptime UptimeResponse
+		want   float64
+	}{
+		{
+			name:   "no downtime",
+			uptime: UptimeResponse{Enabled: 2592000000, Down: 0, Uptime: 100},
+			want:   100,
+		},
+		{
+			name:   "five nines",
+			uptime: UptimeResponse{Enabled: 2592000000, Down: 25920, Uptime: 99.999},
+			want:   99.999,
+		},
+		{
+			name:   "beyond NodePing's rounding",
+			uptime: UptimeResponse{Enabled: 2592000000, Down: 2592, Uptime: 100},
+			want:   99.9999,
+		},
+		{
+			name:   "nothing enabled",
+			uptime: UptimeResponse{Enabled: 0, Down: 0, Uptime: 0},
+			want:   0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, tt.uptime.ComputedUptime(), 1e-9)
+		})
+	}
+}
+
+func TestCheckUptimeConsistency(t *testing.T) {
+	npClient, _ := New(ClientConfig{Token: "mock"})
+	assert.Equal(t, DefaultUptimeTolerance, *npClient.Config.UptimeTolerance)
+
+	consistent := UptimeResponse{Enabled: 2592000000, Down: 2592, Uptime: 100}
+	assert.LessOrEqual(t, npClient.CheckUptimeConsistency("c1ID", consistent), *npClient.Config.UptimeTolerance)
+
+	inconsistent := UptimeResponse{Enabled: 4744902919, Down: 253073, Uptime: 99.011}
+	assert.Greater(t, npClient.CheckUptimeConsistency("c2ID", inconsistent), *npClient.Config.UptimeTolerance)
+
+	zero := 0.0
+	npClient, err := New(ClientConfig{Token: "mock", UptimeTolerance: &zero})
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, *npClient.Config.UptimeTolerance, "a tolerance of 0 should be kept")
+
+	negative := -1.0
+	_, err = New(ClientConfig{Token: "mock", UptimeTolerance: &negative})
+	assert.Error(t, err)
+}
+
 func TestGetUptimePath(t *testing.T) {
 	jan1 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
 	jan31 := time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC)
@@ -491,3 +476,177 @@ func TestGetUptimePath(t *testing.T) {
 		})
 	}
 }
+
+func TestGetUptimePathWithInterval(t *testing.T) {
+	jan1 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	jan31 := time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	got := GetUptimePathWithInterval("1", Period{From: jan1, To: jan31}, IntervalDays)
+	assert.Equal(t, "/results/uptime/1?end=1580428800000&interval=days&start=1577836800000", got)
+}
+
+func TestGetEventsPath(t *testing.T) {
+	jan1 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	jan31 := time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	got := GetEventsPath("1", Period{From: jan1, To: jan31}, 500)
+	assert.Equal(t, "/results/events/1?end=1580428800000&limit=500&start=1577836800000", got)
+}
+
+func TestGetEvents_paging(t *testing.T) {
+	// 600 events, a minute apart, newest first
+	var all []EventResponse
+	for i := 600; i > 0; i-- {
+		all = append(all, EventResponse{ID: strconv.Itoa(i), Type: EventTypeDown, Start: int64(i) * 60000, End: int64(i)*60000 + 1000})
+	}
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RawQuery)
+		end, _ := strconv.ParseInt(r.URL.Query().Get("end"), 10, 64)
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+		page := []EventResponse{}
+		for _, event := range all {
+			if event.Start <= end && len(page) < limit {
+				page = append(page, event)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(page)
+	}))
+	defer server.Close()
+
+	npClient, _ := New(ClientConfig{Token: "abc123", BaseURL: server.URL})
+	events, err := npClient.GetEvents("c1ID", Period{From: time.UnixMilli(0), To: time.UnixMilli(601 * 60000)})
+	assert.NoError(t, err)
+	assert.Len(t, events, 600, "the events on the second page should be included, once each")
+	assert.Len(t, requests, 2)
+	assert.Contains(t, requests[1], "end=6060000", "the second page should end at the oldest event of the first")
+
+	// A full page that can't be paged through is an error
+	all = all[:1]
+	for i := 1; i < EventsPageSize; i++ {
+		all = append(all, EventResponse{ID: "same" + strconv.Itoa(i), Start: all[0].Start})
+	}
+	_, err = npClient.GetEvents("c1ID", Period{From: time.UnixMilli(0), To: time.UnixMilli(601 * 60000)})
+	assert.Error(t, err)
+}
+
+func TestGetDailyUptimesForChecks(t *testing.T) {
+	npClient, _ := New(ClientConfig{Token: "mock"})
+
+	npClient.MockResults = `
+{
+  "2018-11-01":{"enabled":86400000,"down":0,"uptime":100},
+  "2018-11-02":{"enabled":86400000,"down":864000,"uptime":99},
+  "total":{"enabled":172800000,"down":864000,"uptime":99.5}
+}
+`
+
+	uptimes, errs := npClient.GetDailyUptimesForChecks(map[string]string{"check1": "c1ID"}, Period{})
+
+	assert.Empty(t, errs)
+	assert.Len(t, uptimes["c1ID"], 2)
+	assert.Equal(t, int64(864000), uptimes["c1ID"]["2018-11-02"].Down)
+	assert.NotContains(t, uptimes["c1ID"], "total")
+}
+
+func TestGetMonthlyUptimesForChecks_error(t *testing.T) {
+	npClient, _ := New(ClientConfig{Token: "mock"})
+	npClient.MockResults = `{"total":`
+
+	uptimes, errs := npClient.GetMonthlyUptimesForChecks(map[string]string{"check1": "c1ID"}, Period{})
+
+	assert.Empty(t, uptimes, "a check whose uptime couldn't be retrieved should have no entries, not zeros")
+	if assert.Contains(t, errs, "c1ID") {
+		assert.ErrorContains(t, errs["c1ID"], "unable to get the uptime of check c1ID from NodePing")
+	}
+}
+
+func TestGetChecksForContactGroup(t *testing.T) {
+	npClient, _ := New(ClientConfig{Token: "mock"})
+
+	npClient.MockResults = `
+{
+  "ID-2": {"_id":"ID-2","label":"Website","notifications":[{"GROUP":{"schedule":"All","delay":5}}]},
+  "ID-1": {"_id":"ID-1","label":"Website","notifications":[{"GROUP":{"schedule":"All","delay":5}}]},
+  "ID-3": {"_id":"ID-3","label":"API","type":"HTTP","interval":5,"parameters":{"target":"https://api.example.org"},"notifications":[{"GROUP":{"schedule":"All","delay":5}}]},
+  "ID-4": {"_id":"ID-4","label":"Other","notifications":[{"OTHER":{"schedule":"All","delay":5}}]}
+}
+`
+
+	checks, err := npClient.GetChecksForContactGroup("GROUP")
+	assert.NoError(t, err)
+	assert.Equal(t, []Check{
+		{ID: "ID-3", Label: "API", Type: "HTTP", Target: "https://api.example.org", Interval: 5},
+		{ID: "ID-1", Label: "Website"},
+		{ID: "ID-2", Label: "Website"},
+	}, checks, "checks with the same label should each be listed")
+}
+
+func TestGetChecksForContactGroup_notifications(t *testing.T) {
+	npClient, _ := New(ClientConfig{Token: "mock"})
+
+	npClient.MockResults = `
+{
+  "2018090614528ABCD-MNOPQRST":
+  {"_id":"2018090614528ABCD-MNOPQRST","customer_id":"2018090614528ABCD","label":"Example1","interval":5,
+    "notifications":[
+      {"AAAA5":
+        {"schedule":"All","delay":5}}         
+    ], 
+    "runlocations":false,"type":"HTTP","status":"assigned","modified":1543260813141,"enable":"active","public":false,"dep":false,
+    "parameters":
+      {"target":"https://example1.org/","ipv6":false,"follow":false,"threshold":30,"sens":2},
+    "created":1539715596937,"queue":"aaaaaaaa10","uuid":"aaaaaaa8-aaa4-aaa4-aaa4-aaaaaaaaaa11","firstdown":0,"state":1
+  },
+  "2018090614528ABCD-NOPQRSTU":
+  {"_id":"2018090614528ABCD-NOPQRSTU","customer_id":"2018090614528ABCD","label":"Example2","interval":3,
+    "notifications":[
+      {"2018090614528ABCD-B-BBBB5":
+        {"schedule":"All","delay":10}}
+    ],
+    "runlocations":false,"type":"HTTP","status":"assigned","modified":1543937160541,"enable":"active","public":false,"dep":false,
+    "parameters":
+      {"target":"https://example2.org/","ipv6":false,"follow":false,"threshold":30,"sens":2},
+     "created":1539715552868,"queue":"bbbbbbbb10","uuid":"bbbbbbb8-bbb4-bbb4-bbb4-bbbbbbbbbb11","firstdown":0,"state":1
+  },
+  "2018090614528ABCD-OPQRSTUV":
+  {"_id":"2018090614528ABCD-OPQRSTUV","customer_id":"2018090614528ABCD","label":"Example3","interval":1,
+    "notifications":[
+      {"2018090614528ABCD-C-CCCCC6":
+        {"schedule":"All","delay":5}}
+    ],
+    "runlocations":false,"type":"HTTP","status":"assigned","modified":1539715504508,"enable":"active","public":false,"dep":false,
+    "parameters":
+      {"target":"https://example3.org/home","ipv6":false,"follow":false,"threshold":30,"sens":2},
+    "created":1539715504508,"queue":"cccccccc10","uuid":"ccccccc8-ccc4-ccc4-ccc4-cccccccccc11","firstdown":0,"state":1
+  },
+  "2018090614528ABCD-PQRSTUVW":
+  {"_id":"2018090614528ABCD-PQRSTUVW","customer_id":"2018090614528ABCD","label":"Example4","interval":1,
+    "notifications":[
+      {"2018090614528ABCD-B-BBBB5":
+        {"schedule":"All","delay":5}},
+      {"EEEE5":
+        {"schedule":"All","delay":5}}
+    ],
+    "runlocations":false,"type":"HTTP","status":"assigned","modified":1543260719724,"enable":"active","public":false,"dep":false,
+    "parameters":
+       {"target":"https://example4.org/check","ipv6":false,"follow":false,"threshold":30,"sens":2},
+    "created":1539715451787,"queue":"dddddddd10","uuid":"ddddddd8-ddd4-ddd4-ddd4-dddddddddd11","firstdown":0,"state":1
+  }
+}
+`
+	checks, err := npClient.GetChecksForContactGroup("2018090614528ABCD-B-BBBB5")
+	assert.NoError(t, err)
+
+	var labels []string
+	for _, check := range checks {
+		labels = append(labels, check.Label)
+	}
+	assert.Equal(t, []string{"Example2", "Example4"}, labels,
+		"checks should be listed if any of their notifications is for the contact group, and not otherwise")
+	assert.Equal(t, "2018090614528ABCD-PQRSTUVW", checks[1].ID,
+		"a check with notifications for several contact groups should have its own ID")
+	assert.Equal(t, "https://example4.org/check", checks[1].Target)
+}
@@ -7,6 +7,9 @@ import (
 	"time"
 )
 
+// MonthLayout is the format of the monthly keys in NodePing's uptime results
+const MonthLayout = "2006-01"
+
 // Period - Hold From and To timestamps for a given period
 type Period struct {
 	From time.Time
@@ -39,16 +42,22 @@ func (p *Period) String() string {
 
 // Set is used by Cobra to set the variable. This checks against the valid periods
 // and outputs an error message if invalid, otherwise it sets the period to the
-// corresponding valid reference.
+// corresponding valid reference. A custom range of whole months can also be given
+// in the form "2024-01:2024-06".
 func (p *Period) Set(v string) error {
 	f, ok := validPeriods[v]
 	if !ok {
+		if monthRange, err := GetMonthRangePeriod(v); err == nil {
+			*p = monthRange
+			return nil
+		}
+
 		keys := make([]string, 0, len(validPeriods))
 		for k := range validPeriods {
 			keys = append(keys, k)
 		}
 		sort.Strings(keys)
-		return fmt.Errorf(`must be one of "%s"`, strings.Join(keys, `", "`))
+		return fmt.Errorf(`must be one of "%s" or a range of months like "2024-01:2024-06"`, strings.Join(keys, `", "`))
 	}
 
 	*p = f(time.Now().UTC())
@@ -109,3 +118,31 @@ func GetLastYearPeriod(now time.Time) Period {
 		name: "LastYear",
 	}
 }
+
+// GetMonthRangePeriod - Get Period for a custom range of whole months, e.g. "2024-01:2024-06"
+func GetMonthRangePeriod(v string) (Period, error) {
+	first, last, ok := strings.Cut(v, ":")
+	if !ok {
+		return Period{}, fmt.Errorf(`month range %q must be in the form "2024-01:2024-06"`, v)
+	}
+
+	fromTime, err := time.Parse(MonthLayout, first)
+	if err != nil {
+		return Period{}, fmt.Errorf("invalid start of month range %q: %w", v, err)
+	}
+
+	lastMonth, err := time.Parse(MonthLayout, last)
+	if err != nil {
+		return Period{}, fmt.Errorf("invalid end of month range %q: %w", v, err)
+	}
+
+	if lastMonth.Before(fromTime) {
+		return Period{}, fmt.Errorf("month range %q ends before it starts", v)
+	}
+
+	return Period{
+		From: fromTime,
+		To:   lastMonth.AddDate(0, 1, 0).Add(-time.Second),
+		name: v,
+	}, nil
+}
@@ -60,3 +60,25 @@ func TestGetPeriod(t *testing.T) {
 		})
 	}
 }
+
+func TestGetMonthRangePeriod(t *testing.T) {
+	got, err := GetPeriod("2023-11:2024-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantFrom := time.Date(2023, 11, 1, 0, 0, 0, 0, time.UTC)
+	wantTo := time.Date(2024, 2, 29, 23, 59, 59, 0, time.UTC)
+	if !got.From.Equal(wantFrom) {
+		t.Errorf("Period 'From' time not correct. Expected %s, got %s", wantFrom, got.From)
+	}
+	if !got.To.Equal(wantTo) {
+		t.Errorf("Period 'To' time not correct. Expected %s, got %s", wantTo, got.To)
+	}
+
+	for _, invalid := range []string{"2024-01", "2024-13:2024-14", "2024-03:2024-01", "NextMonth"} {
+		if _, err := GetPeriod(invalid); err == nil {
+			t.Errorf("Expected an error for period %q", invalid)
+		}
+	}
+}
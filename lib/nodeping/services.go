@@ -0,0 +1,203 @@
+package nodeping
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// ServiceRuleAnd means a service is up only while all of its checks are up
+	ServiceRuleAnd = "AND"
+
+	// ServiceRuleOr means a service is up while any of its checks is up
+	ServiceRuleOr = "OR"
+)
+
+// Service is a group of checks whose combined uptime is reported under one name
+type Service struct {
+	Name   string   `json:"name"`
+	Rule   string   `json:"rule"`
+	Checks []string `json:"checks"` // The labels or IDs of the checks
+}
+
+// Interval is a span of time, e.g. an outage
+type Interval struct {
+	Start time.Time
+	End   time.Time
+}
+
+// LoadServices reads and validates the service definitions in a JSON file
+func LoadServices(path string) ([]Service, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading service definitions: %w", err)
+	}
+
+	return ParseServices(data)
+}
+
+// ParseServices parses and validates service definitions, which are a JSON list like
+//
+//	[{"name": "Identity", "rule": "AND", "checks": ["IdP Web", "IdP API"]}]
+func ParseServices(data []byte) ([]Service, error) {
+	var services []Service
+	if err := json.Unmarshal(data, &services); err != nil {
+		return nil, fmt.Errorf("invalid service definitions: %w", err)
+	}
+
+	for i, service := range services {
+		if service.Name == "" {
+			return nil, fmt.Errorf("service definition %d has no name", i+1)
+		}
+
+		services[i].Rule = strings.ToUpper(service.Rule)
+		if services[i].Rule == "" {
+			services[i].Rule = ServiceRuleAnd
+		}
+		if services[i].Rule != ServiceRuleAnd && services[i].Rule != ServiceRuleOr {
+			return nil, fmt.Errorf(`service %q has rule %q, but it must be "%s" or "%s"`,
+				service.Name, service.Rule, ServiceRuleAnd, ServiceRuleOr)
+		}
+
+		if len(service.Checks) == 0 {
+			return nil, fmt.Errorf("service %q has no checks", service.Name)
+		}
+	}
+
+	return services, nil
+}
+
+// Uptime computes the service's uptime percentage between from and to, given the outages of each
+// of its checks (keyed by the label or ID used in the service definition).
+// With ServiceRuleAnd the service is down while any check is down.
+// With ServiceRuleOr the service is down only while all the checks are down.
+func (s Service) Uptime(outages map[string][]Interval, from, to time.Time) float64 {
+	total := to.Sub(from)
+	if total <= 0 {
+		return 100
+	}
+
+	var down []Interval
+	for i, check := range s.Checks {
+		checkDown := clipIntervals(outages[check], from, to)
+		switch {
+		case i == 0:
+			down = checkDown
+		case s.Rule == ServiceRuleOr:
+			down = intersectIntervals(down, checkDown)
+		default:
+			down = clipIntervals(append(down, checkDown...), from, to)
+		}
+	}
+
+	var downtime time.Duration
+	for _, interval := range down {
+		downtime += interval.End.Sub(interval.Start)
+	}
+
+	return 100 * float64(total-downtime) / float64(total)
+}
+
+// clipIntervals limits the intervals to between from and to, and merges any that overlap.
+// The results are sorted by start time.
+func clipIntervals(intervals []Interval, from, to time.Time) []Interval {
+	var clipped []Interval
+	for _, interval := range intervals {
+		if interval.Start.Before(from) {
+			interval.Start = from
+		}
+		if interval.End.After(to) {
+			interval.End = to
+		}
+		if interval.End.After(interval.Start) {
+			clipped = append(clipped, interval)
+		}
+	}
+
+	sort.Slice(clipped, func(i, j int) bool { return clipped[i].Start.Before(clipped[j].Start) })
+
+	var merged []Interval
+	for _, interval := range clipped {
+		last := len(merged) - 1
+		if last >= 0 && !interval.Start.After(merged[last].End) {
+			if interval.End.After(merged[last].End) {
+				merged[last].End = interval.End
+			}
+			continue
+		}
+		merged = append(merged, interval)
+	}
+
+	return merged
+}
+
+// intersectIntervals returns the spans of time covered by both lists of sorted, non-overlapping intervals
+func intersectIntervals(a, b []Interval) []Interval {
+	var overlaps []Interval
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		start := a[i].Start
+		if b[j].Start.After(start) {
+			start = b[j].Start
+		}
+		end := a[i].End
+		if b[j].End.Before(end) {
+			end = b[j].End
+		}
+		if end.After(start) {
+			overlaps = append(overlaps, Interval{Start: start, End: end})
+		}
+
+		if a[i].End.Before(b[j].End) {
+			i++
+		} else {
+			j++
+		}
+	}
+	return overlaps
+}
+
+// GetOutagesForServices retrieves the outages within the period of every check used by the services,
+// keyed by the label or ID used in the service definitions
+func GetOutagesForServices(config ClientConfig, services []Service, period Period) (map[string][]Interval, error) {
+	npClient, err := New(config)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing cli: %w", err)
+	}
+
+	checks, err := npClient.ListChecks()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving checks: %w", err)
+	}
+
+	checkIDs := map[string]string{}
+	for _, check := range checks {
+		checkIDs[check.ID] = check.ID
+		checkIDs[check.Label] = check.ID
+	}
+
+	outages := map[string][]Interval{}
+	for _, service := range services {
+		for _, check := range service.Checks {
+			if _, ok := outages[check]; ok {
+				continue
+			}
+
+			checkID, ok := checkIDs[check]
+			if !ok {
+				return nil, fmt.Errorf("check %q of service %q not found", check, service.Name)
+			}
+
+			checkOutages, err := npClient.GetOutages(checkID, period)
+			if err != nil {
+				return nil, fmt.Errorf("error getting outages for check %q: %w", check, err)
+			}
+			outages[check] = checkOutages
+		}
+	}
+
+	return outages, nil
+}
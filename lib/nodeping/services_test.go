@@ -0,0 +1,99 @@
+package nodeping
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseServices(t *testing.T) {
+	services, err := ParseServices([]byte(`[
+		{"name": "Identity", "rule": "or", "checks": ["IdP Web", "IdP API"]},
+		{"name": "Website", "checks": ["2018090614528ABCD-MNOPQRST"]}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, ServiceRuleOr, services[0].Rule)
+	assert.Equal(t, ServiceRuleAnd, services[1].Rule)
+
+	invalid := []string{
+		`{"name": "Not a list"}`,
+		`[{"rule": "AND", "checks": ["IdP Web"]}]`,
+		`[{"name": "Identity", "rule": "XOR", "checks": ["IdP Web"]}]`,
+		`[{"name": "Identity", "rule": "AND", "checks": []}]`,
+	}
+	for _, data := range invalid {
+		_, err := ParseServices([]byte(data))
+		assert.Error(t, err, "expected an error for %s", data)
+	}
+}
+
+func TestServiceUptime(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(100 * time.Hour)
+	at := func(hours int) time.Time { return from.Add(time.Duration(hours) * time.Hour) }
+
+	outages := map[string][]Interval{
+		"web":   {{Start: at(10), End: at(20)}, {Start: at(-5), End: at(2)}},
+		"api":   {{Start: at(15), End: at(25)}, {Start: at(95), End: at(110)}},
+		"login": nil,
+	}
+
+	tests := []struct {
+		name    string
+		service Service
+		want    float64
+	}{
+		{
+			name:    "AND of overlapping outages",
+			service: Service{Rule: ServiceRuleAnd, Checks: []string{"web", "api"}},
+			want:    78, // down 0-2, 10-25 and 95-100
+		},
+		{
+			name:    "OR of overlapping outages",
+			service: Service{Rule: ServiceRuleOr, Checks: []string{"web", "api"}},
+			want:    95, // down 15-20
+		},
+		{
+			name:    "OR with a check that never went down",
+			service: Service{Rule: ServiceRuleOr, Checks: []string{"web", "login"}},
+			want:    100,
+		},
+		{
+			name:    "single check",
+			service: Service{Rule: ServiceRuleAnd, Checks: []string{"web"}},
+			want:    88,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, tt.service.Uptime(outages, from, to), 1e-9)
+		})
+	}
+}
+
+func TestGetOutagesMock(t *testing.T) {
+	npClient, _ := New(ClientConfig{Token: "mock"})
+
+	npClient.MockResults = `
+[
+  {"_id":"e1","type":"down","start":1704067200000,"end":1704070800000,"message":"Error: connect ETIMEDOUT"},
+  {"_id":"e2","type":"up","start":1704070800000,"end":0,"message":"Success"},
+  {"_id":"e3","type":"down","start":1704153600000,"end":0,"message":"Error: 500"}
+]
+`
+
+	period := Period{To: time.Date(2024, 1, 31, 23, 59, 59, 0, time.UTC)}
+	outages, err := npClient.GetOutages("c1ID", period)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []Interval{
+		{Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)},
+		{Start: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), End: period.To},
+	}, outages)
+}
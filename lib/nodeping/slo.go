@@ -0,0 +1,195 @@
+package nodeping
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	SLOWindowMonth   = "month"
+	SLOWindowQuarter = "quarter"
+	SLOWindowYear    = "year"
+)
+
+// SLO is an uptime target for the checks whose label matches Label, which may be an exact label or a
+// pattern like "Website *" (see path.Match). The error budget is tracked over the Window, which is one
+// of SLOWindowMonth, SLOWindowQuarter or SLOWindowYear.
+type SLO struct {
+	Label  string  `json:"label"`
+	Target float64 `json:"target"` // e.g. 99.9
+	Window string  `json:"window"`
+}
+
+// ErrorBudget is the state of a check's error budget within its SLO window
+type ErrorBudget struct {
+	Label     string
+	SLO       SLO
+	Window    Period
+	Uptime    float64       // The computed uptime so far within the window
+	Budget    time.Duration // The downtime allowed over the whole window
+	Consumed  time.Duration // The downtime so far within the window
+	Remaining time.Duration // Negative once the budget has been overspent
+}
+
+// Exhausted reports whether the check has used up its error budget
+func (b ErrorBudget) Exhausted() bool {
+	return b.Consumed > 0 && b.Consumed >= b.Budget
+}
+
+// ConsumedPercent returns the percentage of the error budget that has been used
+func (b ErrorBudget) ConsumedPercent() float64 {
+	if b.Budget <= 0 {
+		if b.Consumed > 0 {
+			return 100
+		}
+		return 0
+	}
+	return 100 * float64(b.Consumed) / float64(b.Budget)
+}
+
+// LoadSLOs reads and validates the SLO definitions in a JSON file
+func LoadSLOs(path string) ([]SLO, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading SLO definitions: %w", err)
+	}
+
+	return ParseSLOs(data)
+}
+
+// ParseSLOs parses and validates SLO definitions, which are a JSON list like
+//
+//	[{"label": "IdP *", "target": 99.9, "window": "quarter"}]
+func ParseSLOs(data []byte) ([]SLO, error) {
+	var slos []SLO
+	if err := json.Unmarshal(data, &slos); err != nil {
+		return nil, fmt.Errorf("invalid SLO definitions: %w", err)
+	}
+
+	for i, slo := range slos {
+		if slo.Label == "" {
+			return nil, fmt.Errorf("SLO definition %d has no label", i+1)
+		}
+
+		if _, err := path.Match(slo.Label, ""); err != nil {
+			return nil, fmt.Errorf("SLO %q has an invalid label pattern: %w", slo.Label, err)
+		}
+
+		if slo.Target <= 0 || slo.Target > 100 {
+			return nil, fmt.Errorf("SLO %q has target %v, but it must be more than 0 and at most 100", slo.Label, slo.Target)
+		}
+
+		slos[i].Window = strings.ToLower(slo.Window)
+		if slos[i].Window == "" {
+			slos[i].Window = SLOWindowMonth
+		}
+		if _, err := GetWindowPeriod(slos[i].Window, time.Now()); err != nil {
+			return nil, fmt.Errorf("SLO %q: %w", slo.Label, err)
+		}
+	}
+
+	return slos, nil
+}
+
+// MatchSLO returns the first SLO whose label or pattern matches the check label
+func MatchSLO(slos []SLO, label string) (SLO, bool) {
+	for _, slo := range slos {
+		if matched, _ := path.Match(slo.Label, label); matched {
+			return slo, true
+		}
+	}
+	return SLO{}, false
+}
+
+// GetWindowPeriod returns the whole month, quarter or year that contains t
+func GetWindowPeriod(window string, t time.Time) (Period, error) {
+	t = t.UTC()
+
+	var from time.Time
+	var months int
+	switch window {
+	case SLOWindowMonth:
+		from = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		months = 1
+	case SLOWindowQuarter:
+		firstMonth := time.Month((int(t.Month())-1)/3*3 + 1)
+		from = time.Date(t.Year(), firstMonth, 1, 0, 0, 0, 0, time.UTC)
+		months = 3
+	case SLOWindowYear:
+		from = time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+		months = 12
+	default:
+		return Period{}, fmt.Errorf(`window %q must be one of "%s", "%s" or "%s"`,
+			window, SLOWindowMonth, SLOWindowQuarter, SLOWindowYear)
+	}
+
+	return Period{
+		From: from,
+		To:   from.AddDate(0, months, 0).Add(-time.Second),
+		name: window,
+	}, nil
+}
+
+// ComputeErrorBudget works out how much of the SLO's error budget has been used, given the check's
+// uptime so far within the window. The budget is the downtime that the target allows over the
+// whole window, and the consumed part is NodePing's Down time.
+func ComputeErrorBudget(slo SLO, label string, window Period, uptime UptimeResponse) ErrorBudget {
+	windowLength := window.To.Sub(window.From) + time.Second
+	budget := time.Duration((100 - slo.Target) / 100 * float64(windowLength)).Round(time.Millisecond)
+	consumed := time.Duration(uptime.Down) * time.Millisecond
+
+	return ErrorBudget{
+		Label:     label,
+		SLO:       slo,
+		Window:    window,
+		Uptime:    uptime.ComputedUptime(),
+		Budget:    budget,
+		Consumed:  consumed,
+		Remaining: budget - consumed,
+	}
+}
+
+// GetErrorBudgetsForContactGroup computes the error budget of each check in the contact group that
+// has an SLO, for the SLO window that contains t. The results are in check label order.
+func GetErrorBudgetsForContactGroup(config ClientConfig, group string, slos []SLO, t time.Time) ([]ErrorBudget, error) {
+	uptimesByWindow := map[string]UptimeResults{}
+	for _, slo := range slos {
+		if _, ok := uptimesByWindow[slo.Window]; ok {
+			continue
+		}
+
+		window, err := GetWindowPeriod(slo.Window, t)
+		if err != nil {
+			return nil, err
+		}
+
+		uptimes, err := GetUptimesForContactGroup(config, group, window)
+		if err != nil {
+			return nil, fmt.Errorf("error getting uptimes for the %s window: %w", slo.Window, err)
+		}
+		uptimesByWindow[slo.Window] = uptimes
+	}
+
+	var budgets []ErrorBudget
+	for _, slo := range slos {
+		uptimes := uptimesByWindow[slo.Window]
+		for _, check := range uptimes.Checks {
+			if matched, _ := MatchSLO(slos, check.Label); matched != slo {
+				continue
+			}
+
+			window, _ := GetWindowPeriod(slo.Window, t)
+			budgets = append(budgets, ComputeErrorBudget(slo, check.Label, window, uptimes.Responses[check.ID]))
+		}
+	}
+
+	sort.Slice(budgets, func(i, j int) bool {
+		return strings.ToLower(budgets[i].Label) < strings.ToLower(budgets[j].Label)
+	})
+	return budgets, nil
+}
@@ -0,0 +1,93 @@
+package nodeping
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSLOs(t *testing.T) {
+	slos, err := ParseSLOs([]byte(`[
+		{"label": "IdP *", "target": 99.9, "window": "Quarter"},
+		{"label": "Website", "target": 99.5}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, SLOWindowQuarter, slos[0].Window)
+	assert.Equal(t, SLOWindowMonth, slos[1].Window)
+
+	invalid := []string{
+		`[{"target": 99.9}]`,
+		`[{"label": "[", "target": 99.9}]`,
+		`[{"label": "Website", "target": 0}]`,
+		`[{"label": "Website", "target": 101}]`,
+		`[{"label": "Website", "target": 99.9, "window": "week"}]`,
+	}
+	for _, data := range invalid {
+		_, err := ParseSLOs([]byte(data))
+		assert.Error(t, err, "expected an error for %s", data)
+	}
+}
+
+func TestMatchSLO(t *testing.T) {
+	slos := []SLO{
+		{Label: "IdP Web", Target: 99.95, Window: SLOWindowMonth},
+		{Label: "IdP *", Target: 99.9, Window: SLOWindowQuarter},
+	}
+
+	slo, ok := MatchSLO(slos, "IdP Web")
+	assert.True(t, ok)
+	assert.Equal(t, 99.95, slo.Target)
+
+	slo, ok = MatchSLO(slos, "IdP API")
+	assert.True(t, ok)
+	assert.Equal(t, 99.9, slo.Target)
+
+	_, ok = MatchSLO(slos, "Website")
+	assert.False(t, ok)
+}
+
+func TestGetWindowPeriod(t *testing.T) {
+	aug17 := time.Date(2017, 8, 17, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		window   string
+		wantFrom time.Time
+		wantTo   time.Time
+	}{
+		{SLOWindowMonth, time.Date(2017, 8, 1, 0, 0, 0, 0, time.UTC), time.Date(2017, 8, 31, 23, 59, 59, 0, time.UTC)},
+		{SLOWindowQuarter, time.Date(2017, 7, 1, 0, 0, 0, 0, time.UTC), time.Date(2017, 9, 30, 23, 59, 59, 0, time.UTC)},
+		{SLOWindowYear, time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2017, 12, 31, 23, 59, 59, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.window, func(t *testing.T) {
+			got, err := GetWindowPeriod(tt.window, aug17)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantFrom, got.From)
+			assert.Equal(t, tt.wantTo, got.To)
+		})
+	}
+
+	_, err := GetWindowPeriod("week", aug17)
+	assert.Error(t, err)
+}
+
+func TestComputeErrorBudget(t *testing.T) {
+	slo := SLO{Label: "Website", Target: 99.9, Window: SLOWindowMonth}
+	window, _ := GetWindowPeriod(SLOWindowMonth, time.Date(2024, 4, 10, 0, 0, 0, 0, time.UTC))
+
+	// 30 days at 99.9% allows 43.2 minutes of downtime
+	budget := ComputeErrorBudget(slo, "Website", window, UptimeResponse{Enabled: 777600000, Down: 1200000})
+	assert.Equal(t, 43*time.Minute+12*time.Second, budget.Budget)
+	assert.Equal(t, 20*time.Minute, budget.Consumed)
+	assert.Equal(t, 23*time.Minute+12*time.Second, budget.Remaining)
+	assert.False(t, budget.Exhausted())
+	assert.InDelta(t, 46.296, budget.ConsumedPercent(), 0.001)
+
+	budget = ComputeErrorBudget(slo, "Website", window, UptimeResponse{Enabled: 777600000, Down: 3000000})
+	assert.True(t, budget.Exhausted())
+	assert.Less(t, budget.Remaining, time.Duration(0))
+}
@@ -61,6 +61,24 @@ type UptimeResponse struct {
 	Uptime  float32 `json:"uptime"`
 }
 
+// ComputedUptime calculates the uptime percentage from the Enabled and Down milliseconds.
+// NodePing's own Uptime value is rounded to three decimals, which hides the difference
+// between e.g. 99.9991 and 99.9999. If no time was enabled, NodePing's value is returned.
+func (u UptimeResponse) ComputedUptime() float64 {
+	if u.Enabled <= 0 {
+		return float64(u.Uptime)
+	}
+	return 100 * float64(u.Enabled-u.Down) / float64(u.Enabled)
+}
+
+type EventResponse struct {
+	ID      string `json:"_id"`
+	Type    string `json:"type"`
+	Start   int64  `json:"start"`
+	End     int64  `json:"end"`
+	Message string `json:"message"`
+}
+
 type ContactGroupResponse struct {
 	Type       string `json:"type"`
 	CustomerID string `json:"customer_id"`
@@ -68,9 +86,29 @@ type ContactGroupResponse struct {
 	Members    []any  `json:"members"`
 }
 
+// Check identifies one NodePing check. More than one check can have the same label.
+type Check struct {
+	ID       string
+	Label    string
+	Type     string // e.g. "HTTP"
+	Target   string // e.g. the URL or host that is checked
+	Interval int    // Minutes between checks
+}
+
 type UptimeResults struct {
-	CheckLabels []string
-	Uptimes     map[string]float32
-	StartTime   int64
-	EndTime     int64
+	Checks           []Check                              // Sorted by label
+	Uptimes          map[string]float64                   // Computed uptime percentages keyed by check ID
+	Responses        map[string]UptimeResponse            // Raw NodePing totals keyed by check ID
+	MonthlyResponses map[string]map[string]UptimeResponse // Raw NodePing entries keyed by check ID and month
+	Errors           map[string]error                     // Why a check's uptimes couldn't be retrieved, keyed by check ID
+	StartTime        int64
+	EndTime          int64
+}
+
+type DailyUptimeResults struct {
+	Checks    []Check                       // Sorted by label
+	Uptimes   map[string]map[string]float64 // Computed uptime percentages keyed by check ID and day
+	Errors    map[string]error              // Why a check's uptimes couldn't be retrieved, keyed by check ID
+	StartTime int64
+	EndTime   int64
 }